@@ -0,0 +1,32 @@
+package app
+
+import (
+	"errors"
+	"github.com/streadway/amqp"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+)
+
+func init() {
+	error_formats.Register("amqp", translateAmqpError)
+}
+
+// translateAmqpError tells closed connections and soft errors, which are
+// worth retrying, from the broker refusing what was asked.
+func translateAmqpError(err error) error_utils.MessageErr {
+	var amqpErr *amqp.Error
+	if !errors.As(err, &amqpErr) {
+		return nil
+	}
+	if amqpErr == amqp.ErrClosed || amqpErr.Recover {
+		return error_utils.NewServiceUnavailableError("broker unavailable").WithCode(error_utils.CodeBrokerUnavailable)
+	}
+	return error_utils.NewInternalServerError("broker error").WithCode(error_utils.CodeBrokerError)
+}
+
+// describeAmqpError is err translated for a log line: its classification
+// followed by the operation and the broker's own words.
+func describeAmqpError(err error, operation string) string {
+	translated := error_formats.Translate(err, operation)
+	return translated.Message() + " (" + translated.Code() + "): " + translated.Detail()
+}
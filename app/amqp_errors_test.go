@@ -0,0 +1,22 @@
+package app
+
+import (
+	"errors"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+)
+
+func TestTranslateAmqpError(t *testing.T) {
+	closed := error_formats.Translate(amqp.ErrClosed, "amqp consume")
+	soft := error_formats.Translate(&amqp.Error{Code: amqp.ResourceLocked, Reason: "locked", Recover: true}, "amqp consume")
+	hard := error_formats.Translate(amqp.ErrCredentials, "amqp dial")
+
+	assert.True(t, errors.Is(closed, error_utils.ErrUnavailable))
+	assert.Equal(t, error_utils.CodeBrokerUnavailable, soft.Code())
+	assert.True(t, errors.Is(hard, error_utils.ErrInternal))
+	assert.Equal(t, error_utils.CodeBrokerError, hard.Code())
+	assert.Equal(t, "broker error (BROKER_ERROR): amqp dial: Exception (403) Reason: \"username or password not allowed\"", describeAmqpError(amqp.ErrCredentials, "amqp dial"))
+}
@@ -3,14 +3,23 @@ package app
 import (
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 	"github.com/joho/godotenv"
 	"log"
 	"os"
+	"strconv"
 	"testing-project/domain"
+	"testing-project/middlewares"
+	"testing-project/openapi"
+	"testing-project/services"
+	"time"
 )
 
 var (
-	router = gin.Default()
+	router = newRouter()
+
+	// softDelete makes "deleted" events mark messages instead of removing them.
+	softDelete bool
 )
 
 func init() {
@@ -19,18 +28,141 @@ func init() {
 	}
 }
 
+// connectRedis connects the message repository to REDIS_ADDR and, in
+// migration mode, mirrors it to MIGRATION_REDIS_ADDR. It returns the primary
+// client, which everything else keeps using.
+// newRouter is gin.Default with middlewares.Recovery, which lets a handler
+// abort the connection of a response it cannot finish.
+func newRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(gin.Logger(), middlewares.Recovery())
+	return r
+}
+
+func connectRedis() *redis.Client {
+	migrationRedisAddr := os.Getenv("MIGRATION_REDIS_ADDR")
+	migrationReadFrom := os.Getenv("MIGRATION_READ_FROM")
+	if migrationReadFrom == "" {
+		migrationReadFrom = domain.MigrationReadPrimary
+	}
+
+	redisClient := domain.MessageRepo.Initialize(os.Getenv("REDIS_ADDR"), os.Getenv("REDIS_PASSWORD"), os.Getenv("REDIS_DB"))
+	fmt.Println("Redis успішно ініціалізовано")
+	if migrationRedisAddr != "" {
+		secondaryClient := domain.NewRedisClient(migrationRedisAddr, os.Getenv("MIGRATION_REDIS_PASSWORD"), os.Getenv("MIGRATION_REDIS_DB"))
+		if err := domain.Migration.Initialize(redisClient, secondaryClient, migrationReadFrom); err != nil {
+			log.Fatalf("Invalid migration configuration: %s", err)
+		}
+		domain.MessageRepo = domain.Migration.Repository()
+		log.Printf("Migration mode: writing messages to %s as well", migrationRedisAddr)
+	}
+	return redisClient
+}
+
+// initializeChanges sets up what applying an event writes to besides the
+// messages: cache invalidations, the change feed, history and webhooks, and
+// reads SOFT_DELETE, which decides what "deleted" events do.
+func initializeChanges(redisClient *redis.Client) {
+	changeFeedMaxLen, _ := strconv.ParseInt(os.Getenv("CHANGE_FEED_MAXLEN"), 10, 64)
+	historyMaxVersions, _ := strconv.ParseInt(os.Getenv("MESSAGE_HISTORY_MAX_VERSIONS"), 10, 64)
+	historyMaxAge, _ := time.ParseDuration(os.Getenv("MESSAGE_HISTORY_MAX_AGE"))
+	softDelete, _ = strconv.ParseBool(os.Getenv("SOFT_DELETE"))
+	webhookAllowInternal, _ := strconv.ParseBool(os.Getenv("WEBHOOK_ALLOW_INTERNAL_URLS"))
+
+	domain.ChangeFeed.Initialize(redisClient, changeFeedMaxLen)
+	domain.CacheInvalidations.Initialize(redisClient)
+	domain.HistoryRepo.Initialize(redisClient, historyMaxVersions, historyMaxAge)
+	domain.WebhookRepo.Initialize(redisClient)
+	services.WebhooksService.Initialize(webhookAllowInternal)
+}
+
 func StartApp() {
 	brokerAddr := os.Getenv("RABBITMQ_URL")
-	redisAddr := os.Getenv("REDIS_ADDR")
-	redisPassword := os.Getenv("REDIS_PASSWORD")
-	redisDB := os.Getenv("REDIS_DB")
+	apiKeys := os.Getenv("API_KEYS")
+	jwtSecret := os.Getenv("JWT_SECRET")
+	jwksFile := os.Getenv("JWT_JWKS_FILE")
+	rateLimits := os.Getenv("RATE_LIMITS")
+	trustedProxies := os.Getenv("TRUSTED_PROXIES")
+	tenantQuotas := os.Getenv("TENANT_QUOTAS")
+	grpcPort := os.Getenv("GRPC_PORT")
+	if grpcPort == "" {
+		grpcPort = "9090"
+	}
+	upstreamUrl := os.Getenv("UPSTREAM_URL")
+	upstreamTimeout, err := time.ParseDuration(os.Getenv("UPSTREAM_TIMEOUT"))
+	if err != nil {
+		upstreamTimeout = 2 * time.Second
+	}
+	upstreamNegativeTtl, err := time.ParseDuration(os.Getenv("UPSTREAM_NEGATIVE_TTL"))
+	if err != nil {
+		upstreamNegativeTtl = 30 * time.Second
+	}
+	cacheSize, _ := strconv.Atoi(os.Getenv("MESSAGE_CACHE_SIZE"))
+	cacheTtl, err := time.ParseDuration(os.Getenv("MESSAGE_CACHE_TTL"))
+	if err != nil {
+		cacheTtl = time.Minute
+	}
+	compressionMinSize, err := strconv.Atoi(os.Getenv("COMPRESSION_MIN_SIZE"))
+	if err != nil {
+		compressionMinSize = 1024
+	}
+	openapiValidation, _ := strconv.ParseBool(os.Getenv("OPENAPI_VALIDATION"))
+	retention, err := time.ParseDuration(os.Getenv("SOFT_DELETE_RETENTION"))
+	if err != nil {
+		retention = 30 * 24 * time.Hour
+	}
+	dedupWindow, _ := time.ParseDuration(os.Getenv("EVENT_DEDUP_WINDOW"))
+	dedupBodies, _ := strconv.ParseBool(os.Getenv("EVENT_DEDUP_BODY_HASH"))
 
-	domain.MessageRepo.Initialize(redisAddr, redisPassword, redisDB)
-	fmt.Println("Redis успішно ініціалізовано")
+	if err := middlewares.InitializeAuth(apiKeys, jwtSecret, jwksFile); err != nil {
+		log.Fatalf("Invalid auth configuration: %s", err)
+	}
+	if err := middlewares.InitializeRateLimits(rateLimits); err != nil {
+		log.Fatalf("Invalid rate limit configuration: %s", err)
+	}
+	if err := middlewares.InitializeTrustedProxies(trustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxy configuration: %s", err)
+	}
+	if err := domain.InitializeQuotas(tenantQuotas); err != nil {
+		log.Fatalf("Invalid tenant quota configuration: %s", err)
+	}
 
-	go startRabbitListener(brokerAddr)
+	redisClient := connectRedis()
+	initializeChanges(redisClient)
+	domain.Upstream.Initialize(redisClient, upstreamUrl, upstreamTimeout, upstreamNegativeTtl)
+	services.MessageCache.Initialize(cacheSize, cacheTtl)
+	domain.StatsRepo.Initialize(redisClient)
+	domain.RateLimitRepo.Initialize(redisClient)
+	domain.ProcessedEvents.Initialize(redisClient, dedupWindow)
+
+	consumer := newEventConsumer(brokerAddr, dedupBodies)
+	services.ConsumerService = consumer
+	go consumer.run()
+	go startGrpcServer(grpcPort)
+	go services.MessageCache.Listen()
+	go startPurgeJob(retention)
+	go startWebhookRetryJob()
+	go reindexMessages()
+	if domain.Migration.Enabled() {
+		domain.Migration.StartBackfill()
+	}
 
-	routes()
+	doc, err := openapi.Load()
+	if err != nil {
+		log.Fatalf("Invalid OpenAPI document: %s", err)
+	}
+	router.Use(middlewares.RequestId(), middlewares.Compress(compressionMinSize))
+	if openapiValidation {
+		validator, err := openapi.NewValidator(doc)
+		if err != nil {
+			log.Fatalf("Invalid OpenAPI document: %s", err)
+		}
+		router.Use(validator.Middleware())
+	}
+	routes(doc)
+	if err := openapi.CheckRoutes(doc, router.Routes()); err != nil {
+		log.Fatalf("OpenAPI document out of date: %s", err)
+	}
 
 	router.Run(":8090")
 }
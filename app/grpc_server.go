@@ -0,0 +1,18 @@
+package app
+
+import (
+	"log"
+	"net"
+	"testing-project/grpc_api"
+)
+
+func startGrpcServer(port string) {
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		log.Fatalf("Failed to listen for gRPC: %s", err)
+	}
+	log.Printf("gRPC server listening on :%s", port)
+	if err := grpc_api.NewServer().Serve(listener); err != nil {
+		log.Fatalf("gRPC server stopped: %s", err)
+	}
+}
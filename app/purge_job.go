@@ -0,0 +1,43 @@
+package app
+
+import (
+	"log"
+	"testing-project/domain"
+	"testing-project/services"
+	"time"
+)
+
+var (
+	purgeInterval = time.Hour
+)
+
+// startPurgeJob hard-deletes soft-deleted messages once they are older than
+// retention. It runs even with soft delete switched off so that messages
+// deleted while it was on are still cleaned up.
+func startPurgeJob(retention time.Duration) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for {
+		purgeDeleted(retention)
+		<-ticker.C
+	}
+}
+
+// purgeDeleted runs one purge and drops the purged messages from the cache
+// of every replica, as the delete handler does.
+func purgeDeleted(retention time.Duration) {
+	purged, err := domain.MessageRepo.PurgeDeleted(time.Now().Add(-retention))
+	count := 0
+	for tenant, ids := range purged {
+		for _, id := range ids {
+			services.MessageCache.Invalidate(tenant, id)
+		}
+		count += len(ids)
+	}
+	if err != nil {
+		log.Printf("Failed to purge deleted messages: %s", err.Message())
+	}
+	if count > 0 {
+		log.Printf("Purged %d deleted messages", count)
+	}
+}
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing-project/domain"
+	"testing-project/services"
+	"time"
+)
+
+func TestPurgeDeleted_Invalidates_Cache(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	repo, invalidations, cache := domain.MessageRepo, domain.CacheInvalidations, services.MessageCache
+	t.Cleanup(func() {
+		domain.MessageRepo, domain.CacheInvalidations, services.MessageCache = repo, invalidations, cache
+	})
+	domain.MessageRepo = domain.NewMessageRepository(client)
+	domain.CacheInvalidations = domain.NewCacheInvalidations(client)
+	services.MessageCache = services.NewMessageCache(10, time.Minute)
+	tenantRepo := domain.MessageRepo.ForTenant("team-a")
+	assert.Nil(t, tenantRepo.Save(&domain.Message{Id: 1, Title: "first"}))
+	assert.Nil(t, tenantRepo.SoftDelete(1))
+	deleted, _ := tenantRepo.Get(1)
+	services.MessageCache.Set("team-a", 1, deleted, services.MessageCache.Epoch())
+
+	purgeDeleted(-time.Minute)
+
+	_, cached := services.MessageCache.Get("team-a", 1)
+	assert.False(t, cached)
+	assert.False(t, server.Exists("tenant:team-a:message:1"))
+}
@@ -1,79 +1,688 @@
 package app
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"github.com/streadway/amqp"
 	"log"
+	"sync"
 	"testing-project/domain"
+	"testing-project/events"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"time"
 )
 
-func startRabbitListener(brokerAddr string) {
-	conn, err := amqp.Dial(brokerAddr)
+const (
+	eventQueue      = "my_queue"
+	deadLetterQueue = eventQueue + ".dead-letter"
+	consumerTag     = "read-model"
+
+	// consumerPrefetch is how many unacknowledged events the broker hands
+	// the consumer at a time.
+	consumerPrefetch = 1
+
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+
+	// retryDelay is how long the consumer stops consuming after putting an
+	// event that failed for a passing reason back on the queue.
+	retryDelay = time.Second
+)
+
+// Headers of dead-lettered events.
+const (
+	headerDeadLetterReason  = "x-dead-letter-reason"
+	headerDeadLetteredAt    = "x-dead-lettered-at"
+	headerOriginalMessageId = "x-original-message-id"
+)
+
+// rabbitConsumer applies the events of eventQueue to the read model. An
+// event is acked once applied. One that failed for a passing reason, such as
+// Redis being unavailable, is redelivered; one that can never be applied is
+// published to deadLetterQueue, where it waits to be requeued by hand.
+type rabbitConsumer struct {
+	url        string
+	dispatcher *events.Dispatcher
+
+	mu          sync.Mutex
+	conn        *amqp.Connection
+	ch          *amqp.Channel
+	consuming   bool
+	paused      bool
+	reconnects  int64
+	connectedAt *time.Time
+	lastEventAt *time.Time
+	lastError   string
+	events      map[string]*domain.EventCounters
+
+	// resume wakes the session to consume again, wake the reconnect loop
+	// out of its backoff.
+	resume chan struct{}
+	wake   chan struct{}
+}
+
+// newRabbitConsumer makes a consumer handing events to dispatcher, which it
+// adds its metrics to.
+func newRabbitConsumer(url string, dispatcher *events.Dispatcher) *rabbitConsumer {
+	c := &rabbitConsumer{
+		url:        url,
+		dispatcher: dispatcher,
+		events:     make(map[string]*domain.EventCounters),
+		resume:     make(chan struct{}, 1),
+		wake:       make(chan struct{}, 1),
+	}
+	dispatcher.Use(c.metrics())
+	return c
+}
+
+// newEventConsumer makes the consumer applying the events of brokerAddr to
+// the read model. With hashBodies, events published without a message id
+// are deduplicated by their body.
+func newEventConsumer(brokerAddr string, hashBodies bool) *rabbitConsumer {
+	dispatcher := events.NewDispatcher()
+	dispatcher.Use(events.Logging())
+	events.RegisterMessageHandlers(dispatcher, softDelete)
+	consumer := newRabbitConsumer(brokerAddr, dispatcher)
+	dispatcher.Use(events.Dedup(domain.ProcessedEvents, hashBodies))
+	return consumer
+}
+
+// run consumes events for as long as the app runs, reconnecting whenever the
+// connection is lost.
+func (c *rabbitConsumer) run() {
+	delay := minReconnectDelay
+	for {
+		established, err := c.session()
+		c.disconnected(err)
+		if established {
+			delay = minReconnectDelay
+		}
+		if err == nil {
+			log.Println("Reconnecting to RabbitMQ...")
+			continue
+		}
+		log.Printf("RabbitMQ consumer disconnected: %s (%s): %s; reconnecting in %s", err.Message(), err.Code(), err.Detail(), delay)
+		select {
+		case <-time.After(delay):
+		case <-c.wake:
+		}
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// session consumes events over one connection until it is lost, which is
+// reported as an error, or closed by Reconnect. established tells whether
+// the connection got as far as consuming.
+func (c *rabbitConsumer) session() (established bool, sessionErr error_utils.MessageErr) {
+	conn, err := amqp.Dial(c.url)
 	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %s", err)
+		return false, error_formats.Translate(err, "amqp dial")
 	}
 	defer conn.Close()
 
 	ch, err := conn.Channel()
 	if err != nil {
-		log.Fatalf("Failed to open a channel: %s", err)
+		return false, error_formats.Translate(err, "amqp channel")
 	}
-	defer ch.Close()
+	if _, err := ch.QueueDeclare(eventQueue, true, false, false, false, nil); err != nil {
+		return false, error_formats.Translate(err, "amqp queue declare")
+	}
+	if _, err := ch.QueueDeclare(deadLetterQueue, true, false, false, false, nil); err != nil {
+		return false, error_formats.Translate(err, "amqp dead-letter queue declare")
+	}
+	if err := ch.Qos(consumerPrefetch, 0, false); err != nil {
+		return false, error_formats.Translate(err, "amqp qos")
+	}
+	if err := ch.Confirm(false); err != nil {
+		return false, error_formats.Translate(err, "amqp confirm")
+	}
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+	connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	chClosed := ch.NotifyClose(make(chan *amqp.Error, 1))
 
-	_, err = ch.QueueDeclare(
-		"my_queue",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
-	if err != nil {
-		log.Fatalf("Failed to declare queue: %s", err)
-	}
-
-	msgs, err := ch.Consume(
-		"my_queue",
-		"",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+	c.connected(conn, ch)
+	deliveries, consumeErr := c.consume(ch)
+	if consumeErr != nil {
+		return false, consumeErr
+	}
+	log.Println("Listening for events on RabbitMQ...")
+
+	// backoff fires when consuming should resume after an event was put
+	// back on the queue.
+	var backoff <-chan time.Time
+	for {
+		select {
+		case msg, ok := <-deliveries:
+			if ok {
+				if c.handle(ch, confirms, msg) {
+					deliveries, backoff = nil, time.After(retryDelay)
+				}
+				continue
+			}
+			// Pause canceled the consumer; a Resume may have come since.
+			if deliveries, consumeErr = c.consume(ch); consumeErr != nil {
+				return true, consumeErr
+			}
+		case <-c.resume:
+			if deliveries != nil || backoff != nil {
+				continue
+			}
+			if deliveries, consumeErr = c.consume(ch); consumeErr != nil {
+				return true, consumeErr
+			}
+		case <-backoff:
+			backoff = nil
+			if deliveries, consumeErr = c.consume(ch); consumeErr != nil {
+				return true, consumeErr
+			}
+		case amqpErr := <-connClosed:
+			if amqpErr == nil {
+				return true, nil
+			}
+			return true, error_formats.Translate(amqpErr, "amqp connection")
+		case amqpErr := <-chClosed:
+			if amqpErr == nil {
+				return true, nil
+			}
+			return true, error_formats.Translate(amqpErr, "amqp channel")
+		}
+	}
+}
+
+// consume starts consuming from eventQueue unless the consumer is paused,
+// in which case the deliveries are nil.
+func (c *rabbitConsumer) consume(ch *amqp.Channel) (<-chan amqp.Delivery, error_utils.MessageErr) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused || c.consuming {
+		return nil, nil
+	}
+	deliveries, err := ch.Consume(eventQueue, consumerTag, false, false, false, false, nil)
 	if err != nil {
-		log.Fatalf("Failed to register consumer: %s", err)
+		return nil, error_formats.Translate(err, "amqp consume")
 	}
+	c.consuming = true
+	return deliveries, nil
+}
 
-	log.Println("Listening for events on RabbitMQ...")
+func (c *rabbitConsumer) connected(conn *amqp.Connection, ch *amqp.Channel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now().UTC()
+	c.conn = conn
+	c.ch = ch
+	c.consuming = false
+	c.connectedAt = &now
+	c.lastError = ""
+}
 
-	for msg := range msgs {
-		log.Printf("Received: %s", msg.Body)
+func (c *rabbitConsumer) disconnected(err error_utils.MessageErr) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn = nil
+	c.ch = nil
+	c.consuming = false
+	c.connectedAt = nil
+	c.reconnects++
+	if err != nil {
+		c.lastError = err.Message() + ": " + err.Detail()
+	}
+}
 
-		var payload struct {
-			Event string          `json:"event"`
-			Data  *domain.Message `json:"data"`
+// handle applies one event, or a batch of them, and settles the delivery
+// with the broker. requeued tells that the delivery went back on the queue
+// and consuming stopped, for the session to resume after retryDelay.
+func (c *rabbitConsumer) handle(ch *amqp.Channel, confirms <-chan amqp.Confirmation, msg amqp.Delivery) (requeued bool) {
+	c.received()
+	if items, batch := events.DecodeBatch(msg.Body, msg.Headers, msg.MessageId); batch {
+		return c.handleBatch(ch, confirms, msg, items)
+	}
+
+	event, _ := events.Decode(msg.Body, msg.Headers, msg.MessageId)
+	err := c.dispatcher.Dispatch(event)
+	switch {
+	case err == nil:
+		msg.Ack(false)
+	case retryable(err):
+		log.Printf("Retrying event in %s", retryDelay)
+		c.requeue(ch, msg)
+		return true
+	default:
+		if dlErr := publishConfirmed(ch, confirms, deadLetterQueue, deadLetterPublishing(msg, err, time.Now().UTC())); dlErr != nil {
+			log.Printf("Failed to dead-letter event, retrying in %s: %s: %s", retryDelay, dlErr.Message(), dlErr.Detail())
+			c.requeue(ch, msg)
+			return true
 		}
-		if err := json.Unmarshal(msg.Body, &payload); err != nil {
-			log.Printf("Failed to unmarshal message: %s", err)
+		log.Printf("Event dead-lettered")
+		msg.Ack(false)
+	}
+	return false
+}
+
+// requeue puts a delivery back on the queue once the consumer is canceled,
+// so the broker does not hand it straight back.
+func (c *rabbitConsumer) requeue(ch *amqp.Channel, msg amqp.Delivery) {
+	if err := c.stopConsuming(ch); err != nil {
+		log.Printf("Failed to stop consuming before a retry: %s: %s", err.Message(), err.Detail())
+	}
+	msg.Nack(false, true)
+}
+
+// stopConsuming cancels the consumer on ch, if it is consuming.
+func (c *rabbitConsumer) stopConsuming(ch *amqp.Channel) error_utils.MessageErr {
+	c.mu.Lock()
+	consuming := c.consuming
+	c.consuming = false
+	c.mu.Unlock()
+	if !consuming {
+		return nil
+	}
+	if err := ch.Cancel(consumerTag, false); err != nil {
+		return error_formats.Translate(err, "amqp cancel")
+	}
+	return nil
+}
+
+// handleBatch applies the events of a batch delivery in one transaction and
+// acks the delivery once all of them are settled: applied, skipped as
+// duplicates or, when they failed, dead-lettered one by one. The whole batch
+// is redelivered only when the transaction did not run for a passing reason,
+// so nothing was written. Once it ran, redelivering would apply its events
+// again, so an event that failed, even for a passing reason such as a write
+// to the migration secondary, is dead-lettered to be requeued by hand, and
+// one that cannot be dead-lettered either is logged and dropped. The outcome
+// of every event goes to the delivery's reply-to queue, if it has one.
+func (c *rabbitConsumer) handleBatch(ch *amqp.Channel, confirms <-chan amqp.Confirmation, msg amqp.Delivery, items []*events.Event) (requeued bool) {
+	outcomes, err := c.dispatcher.DispatchBatch(items)
+	if err != nil && retryable(err) {
+		log.Printf("Retrying batch of %d events in %s: %s: %s", len(items), retryDelay, err.Message(), err.Detail())
+		c.requeue(ch, msg)
+		return true
+	}
+
+	now := time.Now().UTC()
+	dropped := make([]bool, len(items))
+	for i, outcome := range outcomes {
+		if outcome == nil {
 			continue
 		}
+		if dlErr := publishConfirmed(ch, confirms, deadLetterQueue, deadLetterPublishing(batchItemDelivery(msg, items[i]), outcome, now)); dlErr != nil {
+			dropped[i] = true
+			log.Printf("Failed to dead-letter event %d of batch, dropping it: %s: %s; the event failed with %s: %s",
+				i, dlErr.Message(), dlErr.Detail(), outcome.Message(), items[i].Body)
+		}
+	}
 
-		switch payload.Event {
-		case "created", "updated":
-			if err := domain.MessageRepo.Save(payload.Data); err != nil {
-				log.Printf("Failed to save/update message: %s", err)
-			} else {
-				log.Printf("Message %s successfully in Redis", payload.Event)
-			}
-		case "deleted":
-			if err := domain.MessageRepo.Delete(payload.Data.Id); err != nil {
-				log.Printf("Failed to delete message: %s", err)
-			} else {
-				log.Printf("Message deleted from Redis")
-			}
+	report := newBatchReport(msg.MessageId, items, outcomes, dropped)
+	log.Printf("Batch of %d events settled: %d applied, %d duplicates, %d dead-lettered, %d dropped",
+		len(items), report.Applied, report.Duplicates, report.DeadLettered, report.Dropped)
+	if msg.ReplyTo != "" {
+		if replyErr := publishReport(ch, confirms, msg, report); replyErr != nil {
+			log.Printf("Failed to send batch report to %s: %s: %s", msg.ReplyTo, replyErr.Message(), replyErr.Detail())
+		}
+	}
+	msg.Ack(false)
+	return false
+}
+
+// batchItemDelivery is one event of a batch delivery as if it had been
+// delivered alone, so it is dead-lettered, and later requeued, by itself.
+func batchItemDelivery(msg amqp.Delivery, item *events.Event) amqp.Delivery {
+	return amqp.Delivery{
+		Headers:     msg.Headers,
+		ContentType: msg.ContentType,
+		MessageId:   item.MessageId,
+		Timestamp:   msg.Timestamp,
+		Body:        item.Body,
+	}
+}
+
+func newBatchReport(messageId string, items []*events.Event, outcomes []error_utils.MessageErr, dropped []bool) *domain.BatchReport {
+	report := &domain.BatchReport{MessageId: messageId, Items: make([]domain.BatchItemOutcome, len(items))}
+	for i, item := range items {
+		outcome := domain.BatchItemOutcome{Index: i, Event: item.Type}
+		if msg, err := item.Message(); err == nil {
+			outcome.MessageId = msg.Id
+		}
+		switch err := outcomes[i]; {
+		case err != nil && dropped[i]:
+			outcome.Status = domain.BatchItemDropped
+			outcome.Code = err.Code()
+			outcome.Error = err.Message()
+			report.Dropped++
+		case err != nil:
+			outcome.Status = domain.BatchItemDeadLettered
+			outcome.Code = err.Code()
+			outcome.Error = err.Message()
+			report.DeadLettered++
+		case item.Duplicate:
+			outcome.Status = domain.BatchItemDuplicate
+			report.Duplicates++
+		default:
+			outcome.Status = domain.BatchItemApplied
+			report.Applied++
+		}
+		report.Items[i] = outcome
+	}
+	return report
+}
+
+// publishReport sends report to the reply-to queue of msg, correlated with
+// it.
+func publishReport(ch *amqp.Channel, confirms <-chan amqp.Confirmation, msg amqp.Delivery, report *domain.BatchReport) error_utils.MessageErr {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return error_formats.Translate(err, "json marshal")
+	}
+	return publishConfirmed(ch, confirms, msg.ReplyTo, amqp.Publishing{
+		ContentType:   "application/json",
+		CorrelationId: msg.CorrelationId,
+		Body:          body,
+	})
+}
+
+// metrics counts the events by type and outcome.
+func (c *rabbitConsumer) metrics() events.Middleware {
+	return func(next events.HandlerFunc) events.HandlerFunc {
+		return func(event *events.Event) error_utils.MessageErr {
+			err := next(event)
+			c.count(c.dispatcher.Label(event), event.Duplicate, err)
+			return err
+		}
+	}
+}
+
+func (c *rabbitConsumer) received() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now().UTC()
+	c.lastEventAt = &now
+}
+
+// count records that an event of the given type was received and what
+// became of it: applied, skipped as a duplicate, failed and about to be
+// retried, or dead-lettered.
+func (c *rabbitConsumer) count(event string, duplicate bool, err error_utils.MessageErr) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counters, ok := c.events[event]
+	if !ok {
+		counters = &domain.EventCounters{}
+		c.events[event] = counters
+	}
+	counters.Received++
+	switch {
+	case duplicate:
+		counters.Duplicates++
+	case err == nil:
+		counters.Applied++
+	case retryable(err):
+		counters.Failed++
+	default:
+		counters.DeadLettered++
+	}
+}
+
+// retryable tells errors that may go away by themselves from those that
+// would fail the event again.
+func retryable(err error) bool {
+	return errors.Is(err, error_utils.ErrUnavailable) || errors.Is(err, error_utils.ErrTimeout)
+}
+
+// publishConfirmed publishes to queue through the default exchange and waits
+// for the broker to confirm it. ch must be in confirm mode, with confirms
+// its publish notifications.
+func publishConfirmed(ch *amqp.Channel, confirms <-chan amqp.Confirmation, queue string, publishing amqp.Publishing) error_utils.MessageErr {
+	if err := ch.Publish("", queue, false, false, publishing); err != nil {
+		return error_formats.Translate(err, "amqp publish to "+queue)
+	}
+	if confirm, ok := <-confirms; !ok || !confirm.Ack {
+		return error_utils.NewServiceUnavailableError("broker unavailable").
+			WithCode(error_utils.CodeBrokerUnavailable).
+			WithDetail("the broker did not confirm the publish to " + queue)
+	}
+	return nil
+}
+
+// deadLetterPublishing is msg as it goes to the dead-letter queue: under a
+// new id, which admins select it by, with the reason it failed.
+func deadLetterPublishing(msg amqp.Delivery, reason error_utils.MessageErr, at time.Time) amqp.Publishing {
+	headers := amqp.Table{}
+	for name, value := range msg.Headers {
+		headers[name] = value
+	}
+	description := reason.Message()
+	if detail := reason.Detail(); detail != "" {
+		description += ": " + detail
+	}
+	headers[headerDeadLetterReason] = description
+	headers[headerDeadLetteredAt] = at.Format(time.RFC3339Nano)
+	if msg.MessageId != "" {
+		headers[headerOriginalMessageId] = msg.MessageId
+	}
+	return amqp.Publishing{
+		Headers:      headers,
+		ContentType:  msg.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    newDeadLetterId(),
+		Timestamp:    msg.Timestamp,
+		Body:         msg.Body,
+	}
+}
+
+// requeuePublishing is a dead-lettered msg as it was first published.
+func requeuePublishing(msg amqp.Delivery) amqp.Publishing {
+	headers := amqp.Table{}
+	for name, value := range msg.Headers {
+		switch name {
+		case headerDeadLetterReason, headerDeadLetteredAt, headerOriginalMessageId:
 		default:
-			log.Printf("Unknown event type: %s", payload.Event)
+			headers[name] = value
 		}
 	}
+	originalId, _ := msg.Headers[headerOriginalMessageId].(string)
+	return amqp.Publishing{
+		Headers:      headers,
+		ContentType:  msg.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    originalId,
+		Timestamp:    msg.Timestamp,
+		Body:         msg.Body,
+	}
+}
+
+// toDeadLetter describes a dead-lettered msg, taking the event type and
+// tenant from its body where it can be read.
+func toDeadLetter(msg amqp.Delivery) domain.DeadLetter {
+	event, _ := events.Decode(msg.Body, msg.Headers, msg.MessageId)
+	letter := domain.DeadLetter{
+		Id:     msg.MessageId,
+		Event:  event.Type,
+		Tenant: event.Tenant,
+		Body:   string(msg.Body),
+	}
+	letter.Reason, _ = msg.Headers[headerDeadLetterReason].(string)
+	if value, ok := msg.Headers[headerDeadLetteredAt].(string); ok {
+		if at, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			letter.DeadLetteredAt = &at
+		}
+	}
+	return letter
+}
+
+func newDeadLetterId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().UTC().Format("20060102T150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+func (c *rabbitConsumer) GetState() domain.ConsumerState {
+	c.mu.Lock()
+	state := domain.ConsumerState{
+		Connected:   c.conn != nil,
+		Paused:      c.paused,
+		Queue:       eventQueue,
+		Reconnects:  c.reconnects,
+		ConnectedAt: c.connectedAt,
+		LastEventAt: c.lastEventAt,
+		LastError:   c.lastError,
+		Events:      make(map[string]*domain.EventCounters, len(c.events)),
+	}
+	for event, counters := range c.events {
+		copied := *counters
+		state.Events[event] = &copied
+	}
+	c.mu.Unlock()
+
+	if !state.Connected {
+		return state
+	}
+	ch, err := c.channel()
+	if err != nil {
+		return state
+	}
+	defer ch.Close()
+	if queue, err := ch.QueueInspect(eventQueue); err == nil {
+		state.Lag = &queue.Messages
+	}
+	if queue, err := ch.QueueInspect(deadLetterQueue); err == nil {
+		state.DeadLetters = &queue.Messages
+	}
+	return state
+}
+
+// Pause stops consuming; events wait in the queue until Resume. The events
+// the broker has already handed over are still applied.
+func (c *rabbitConsumer) Pause() (domain.ConsumerState, error_utils.MessageErr) {
+	c.mu.Lock()
+	c.paused = true
+	ch := c.ch
+	c.mu.Unlock()
+	if ch != nil {
+		if err := c.stopConsuming(ch); err != nil {
+			return domain.ConsumerState{}, err
+		}
+	}
+	return c.GetState(), nil
+}
+
+func (c *rabbitConsumer) Resume() (domain.ConsumerState, error_utils.MessageErr) {
+	c.mu.Lock()
+	c.paused = false
+	c.mu.Unlock()
+	select {
+	case c.resume <- struct{}{}:
+	default:
+	}
+	return c.GetState(), nil
+}
+
+// Reconnect closes the connection, or cuts the wait before the next attempt
+// when there is none; the consumer then connects again.
+func (c *rabbitConsumer) Reconnect() (domain.ConsumerState, error_utils.MessageErr) {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		select {
+		case c.wake <- struct{}{}:
+		default:
+		}
+	} else if err := conn.Close(); err != nil && err != amqp.ErrClosed {
+		return domain.ConsumerState{}, error_formats.Translate(err, "amqp close")
+	}
+	return c.GetState(), nil
+}
+
+// GetDeadLetters lists up to limit dead-lettered events, oldest first. The
+// events are fetched without acknowledging them, so they go back to the
+// dead-letter queue when the channel closes.
+func (c *rabbitConsumer) GetDeadLetters(limit int) ([]domain.DeadLetter, error_utils.MessageErr) {
+	ch, chErr := c.channel()
+	if chErr != nil {
+		return nil, chErr
+	}
+	defer ch.Close()
+
+	letters := make([]domain.DeadLetter, 0)
+	for len(letters) < limit {
+		msg, ok, err := ch.Get(deadLetterQueue, false)
+		if err != nil {
+			return nil, error_formats.Translate(err, "amqp get")
+		}
+		if !ok {
+			break
+		}
+		letters = append(letters, toDeadLetter(msg))
+	}
+	return letters, nil
+}
+
+// Requeue puts the dead-lettered events with the given ids back on the
+// event queue. It goes through the whole dead-letter queue; the events it
+// does not take go back to it when the channel closes.
+func (c *rabbitConsumer) Requeue(ids []string) (*domain.RequeueResult, error_utils.MessageErr) {
+	ch, chErr := c.channel()
+	if chErr != nil {
+		return nil, chErr
+	}
+	defer ch.Close()
+	if err := ch.Confirm(false); err != nil {
+		return nil, error_formats.Translate(err, "amqp confirm")
+	}
+	confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = false
+	}
+	result := &domain.RequeueResult{Requeued: make([]string, 0), NotFound: make([]string, 0)}
+	for {
+		msg, ok, err := ch.Get(deadLetterQueue, false)
+		if err != nil {
+			return result, error_formats.Translate(err, "amqp get")
+		}
+		if !ok {
+			break
+		}
+		if _, ok := wanted[msg.MessageId]; !ok {
+			continue
+		}
+		if err := publishConfirmed(ch, confirms, eventQueue, requeuePublishing(msg)); err != nil {
+			return result, err
+		}
+		if err := msg.Ack(false); err != nil {
+			return result, error_formats.Translate(err, "amqp ack")
+		}
+		wanted[msg.MessageId] = true
+		result.Requeued = append(result.Requeued, msg.MessageId)
+	}
+	for _, id := range ids {
+		if !wanted[id] {
+			result.NotFound = append(result.NotFound, id)
+		}
+	}
+	return result, nil
+}
+
+// channel opens a channel on the consumer's connection for a short task.
+func (c *rabbitConsumer) channel() (*amqp.Channel, error_utils.MessageErr) {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return nil, error_utils.NewServiceUnavailableError("the consumer is not connected").WithCode(error_utils.CodeConsumerDisconnected)
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, error_formats.Translate(err, "amqp channel")
+	}
+	return ch, nil
 }
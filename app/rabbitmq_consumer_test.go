@@ -0,0 +1,159 @@
+package app
+
+import (
+	"errors"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing-project/domain"
+	"testing-project/events"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+type recordingHandler struct {
+	applied []*events.Event
+}
+
+func (h *recordingHandler) Validate(*events.Event) error_utils.MessageErr {
+	return nil
+}
+
+func (h *recordingHandler) Apply(event *events.Event) error_utils.MessageErr {
+	h.applied = append(h.applied, event)
+	return nil
+}
+
+func TestRabbitConsumer_Counts_Dispatched_Events(t *testing.T) {
+	handler := &recordingHandler{}
+	dispatcher := events.NewDispatcher()
+	dispatcher.Register("created", handler)
+	consumer := newRabbitConsumer("amqp://localhost", dispatcher)
+	event, _ := events.Decode([]byte(`{"event":"created","data":{"id":1}}`), amqp.Table{"tenant_id": "acme"}, "")
+
+	err := dispatcher.Dispatch(event)
+
+	assert.Nil(t, err)
+	assert.Len(t, handler.applied, 1)
+	assert.EqualValues(t, "acme", handler.applied[0].Tenant)
+	assert.EqualValues(t, &domain.EventCounters{Received: 1, Applied: 1}, consumer.GetState().Events["created"])
+}
+
+func TestRabbitConsumer_Counts_Invalid_Body(t *testing.T) {
+	dispatcher := events.NewDispatcher()
+	consumer := newRabbitConsumer("amqp://localhost", dispatcher)
+	event, _ := events.Decode([]byte("not json"), nil, "")
+
+	err := dispatcher.Dispatch(event)
+
+	assert.True(t, errors.Is(err, error_utils.ErrBadRequest))
+	assert.False(t, retryable(err))
+	assert.EqualValues(t, &domain.EventCounters{Received: 1, DeadLettered: 1}, consumer.GetState().Events[events.InvalidType])
+}
+
+func TestRabbitConsumer_Counts_Unknown_Event(t *testing.T) {
+	dispatcher := events.NewDispatcher()
+	consumer := newRabbitConsumer("amqp://localhost", dispatcher)
+	event, _ := events.Decode([]byte(`{"event":"archived","data":{"id":1}}`), nil, "")
+
+	err := dispatcher.Dispatch(event)
+
+	assert.EqualValues(t, error_utils.CodeUnknownEventType, err.Code())
+	assert.EqualValues(t, &domain.EventCounters{Received: 1, DeadLettered: 1}, consumer.GetState().Events[events.UnknownType])
+}
+
+func TestNewBatchReport(t *testing.T) {
+	items, batch := events.DecodeBatch([]byte(`{"events":[
+		{"event":"created","data":{"id":1}},
+		{"event":"created","data":{"id":2}},
+		{"event":"archived","data":{"id":3}},
+		{"event":"created","data":{"id":4}}
+	]}`), nil, "batch-1")
+	assert.True(t, batch)
+	items[1].Duplicate = true
+	unavailable := error_utils.NewServiceUnavailableError("migration secondary unavailable")
+
+	report := newBatchReport("batch-1", items, []error_utils.MessageErr{nil, nil, error_utils.NewBadRequestError("unknown event type").WithCode(error_utils.CodeUnknownEventType), unavailable}, []bool{false, false, false, true})
+
+	assert.EqualValues(t, 1, report.Applied)
+	assert.EqualValues(t, 1, report.Duplicates)
+	assert.EqualValues(t, 1, report.DeadLettered)
+	assert.EqualValues(t, 1, report.Dropped)
+	assert.EqualValues(t, domain.BatchItemDropped, report.Items[3].Status)
+	assert.EqualValues(t, domain.BatchItemOutcome{Index: 0, Event: "created", MessageId: 1, Status: domain.BatchItemApplied}, report.Items[0])
+	assert.EqualValues(t, domain.BatchItemDuplicate, report.Items[1].Status)
+	assert.EqualValues(t, domain.BatchItemOutcome{Index: 2, Event: "archived", MessageId: 3, Status: domain.BatchItemDeadLettered, Code: error_utils.CodeUnknownEventType, Error: "unknown event type"}, report.Items[2])
+}
+
+func TestBatchItemDelivery(t *testing.T) {
+	msg := amqp.Delivery{Headers: amqp.Table{"tenant_id": "acme"}, MessageId: "batch-1", Body: []byte(`{"events":[{"event":"created","data":{"id":1}}]}`)}
+	items, _ := events.DecodeBatch(msg.Body, msg.Headers, msg.MessageId)
+
+	item := batchItemDelivery(msg, items[0])
+
+	assert.EqualValues(t, "batch-1#0", item.MessageId)
+	assert.EqualValues(t, `{"event":"created","data":{"id":1}}`, string(item.Body))
+	assert.EqualValues(t, amqp.Table{"tenant_id": "acme"}, item.Headers)
+}
+
+func TestRetryable(t *testing.T) {
+	assert.True(t, retryable(error_utils.NewServiceUnavailableError("redis unavailable")))
+	assert.True(t, retryable(error_utils.NewGatewayTimeoutError("redis timeout")))
+	assert.False(t, retryable(error_utils.NewUnprocessibleEntityError("tenant message quota exceeded")))
+}
+
+func TestDeadLetter_Round_Trip(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	original := amqp.Delivery{
+		Headers:     amqp.Table{"tenant_id": "acme"},
+		ContentType: "application/json",
+		MessageId:   "event-1",
+		Body:        []byte(`{"event":"created","data":{"id":1}}`),
+	}
+
+	publishing := deadLetterPublishing(original, error_utils.NewBadRequestError("invalid message").WithDetail("title is required"), at)
+	dead := amqp.Delivery{Headers: publishing.Headers, ContentType: publishing.ContentType, MessageId: publishing.MessageId, Body: publishing.Body}
+	letter := toDeadLetter(dead)
+
+	assert.NotEmpty(t, letter.Id)
+	assert.NotEqual(t, "event-1", letter.Id)
+	assert.EqualValues(t, "created", letter.Event)
+	assert.EqualValues(t, "acme", letter.Tenant)
+	assert.EqualValues(t, "invalid message: title is required", letter.Reason)
+	assert.True(t, at.Equal(*letter.DeadLetteredAt))
+	assert.EqualValues(t, string(original.Body), letter.Body)
+
+	requeued := requeuePublishing(dead)
+	assert.EqualValues(t, "event-1", requeued.MessageId)
+	assert.EqualValues(t, amqp.Table{"tenant_id": "acme"}, requeued.Headers)
+	assert.EqualValues(t, original.Body, requeued.Body)
+}
+
+func TestRabbitConsumer_Counts_Events(t *testing.T) {
+	consumer := newRabbitConsumer("amqp://localhost", events.NewDispatcher())
+	consumer.count("created", false, nil)
+	consumer.count("created", true, nil)
+	consumer.count("created", false, error_utils.NewServiceUnavailableError("redis unavailable"))
+	consumer.count("created", false, error_utils.NewBadRequestError("invalid event data"))
+
+	state := consumer.GetState()
+	state.Events["created"].Received = 100
+
+	assert.False(t, state.Connected)
+	assert.EqualValues(t, &domain.EventCounters{Received: 4, Applied: 1, Duplicates: 1, Failed: 1, DeadLettered: 1}, consumer.GetState().Events["created"])
+}
+
+func TestRabbitConsumer_Pause_While_Disconnected(t *testing.T) {
+	consumer := newRabbitConsumer("amqp://localhost", events.NewDispatcher())
+
+	state, err := consumer.Pause()
+	assert.Nil(t, err)
+	assert.True(t, state.Paused)
+
+	state, err = consumer.Resume()
+	assert.Nil(t, err)
+	assert.False(t, state.Paused)
+
+	_, err = consumer.GetDeadLetters(10)
+	assert.EqualValues(t, error_utils.CodeConsumerDisconnected, err.Code())
+}
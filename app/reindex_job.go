@@ -0,0 +1,17 @@
+package app
+
+import (
+	"log"
+	"testing-project/domain"
+)
+
+// reindexMessages builds the filter indexes for messages stored before the
+// indexes existed. The consumer keeps them up to date from then on.
+func reindexMessages() {
+	indexed, err := domain.MessageRepo.Reindex()
+	if err != nil {
+		log.Printf("Failed to reindex messages: %s", err.Message())
+		return
+	}
+	log.Printf("Reindexed %d messages", indexed)
+}
@@ -1,14 +1,47 @@
 package app
 
 import (
+	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/gin-gonic/gin"
 	"testing-project/controllers"
+	"testing-project/graphql_api"
+	"testing-project/middlewares"
+	"testing-project/openapi"
 )
 
-func routes() {
-	router.GET("/messages/:message_id", controllers.GetMessage)
-	router.GET("/messages", controllers.GetAllMessages)
+// routes registers every route. Each one must be described in
+// openapi/openapi.yaml, which StartApp checks.
+func routes(doc *openapi3.T) {
+	read := router.Group("/", middlewares.RequireScopes(middlewares.ScopeMessagesRead), middlewares.ResolveTenant())
+	read.GET("/messages/:message_id", middlewares.RateLimit("messages.get"), controllers.GetMessage)
+	read.GET("/messages/:message_id/history", middlewares.RateLimit("messages.history"), controllers.GetMessageHistory)
+	read.GET("/messages/:message_id/versions/:n", middlewares.RateLimit("messages.history"), controllers.GetMessageVersion)
+	read.GET("/messages", middlewares.RateLimit("messages.list"), controllers.GetAllMessages)
+	read.GET("/messages/stats", middlewares.RateLimit("messages.stats"), controllers.GetMessageStats)
+	read.GET("/messages/stream", middlewares.RateLimit("messages.stream"), controllers.StreamMessages)
+	read.GET("/messages/ws", middlewares.RateLimit("messages.stream"), controllers.StreamMessagesWS)
+	read.GET("/graphql", middlewares.RateLimit("graphql"), graphql_api.Handler)
+	read.POST("/graphql", middlewares.RateLimit("graphql"), graphql_api.Handler)
+
+	admin := router.Group("/admin", middlewares.RequireScopes(middlewares.ScopeAdmin))
+	admin.GET("/webhooks", controllers.GetWebhooks)
+	admin.POST("/webhooks", controllers.CreateWebhook)
+	admin.DELETE("/webhooks/:webhook_id", controllers.DeleteWebhook)
+	admin.GET("/webhooks/:webhook_id/deliveries", controllers.GetWebhookDeliveries)
+	admin.GET("/cache/stats", controllers.GetCacheStats)
+	admin.GET("/migration", controllers.GetMigration)
+	admin.PUT("/migration/read-from", controllers.SetMigrationReadFrom)
+	admin.POST("/migration/backfill", controllers.StartMigrationBackfill)
+	admin.POST("/migration/verify", controllers.VerifyMigration)
+	admin.GET("/consumer", controllers.GetConsumer)
+	admin.POST("/consumer/pause", controllers.PauseConsumer)
+	admin.POST("/consumer/resume", controllers.ResumeConsumer)
+	admin.POST("/consumer/reconnect", controllers.ReconnectConsumer)
+	admin.GET("/consumer/dead-letters", controllers.GetDeadLetters)
+	admin.POST("/consumer/dead-letters/requeue", controllers.RequeueDeadLetters)
+
 	router.GET("/health", func(c *gin.Context) {
 		c.Status(200)
 	})
+	router.GET("/openapi.json", openapi.Handler(doc))
 }
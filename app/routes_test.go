@@ -0,0 +1,16 @@
+package app
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing-project/openapi"
+)
+
+func TestRoutes_Match_OpenAPI_Document(t *testing.T) {
+	doc, err := openapi.Load()
+	assert.Nil(t, err)
+
+	routes(doc)
+
+	assert.Nil(t, openapi.CheckRoutes(doc, router.Routes()))
+}
@@ -0,0 +1,229 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/streadway/amqp"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"testing-project/domain"
+	"testing-project/events"
+	"testing-project/services"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+// maxSnapshotLine bounds one message of a snapshot.
+const maxSnapshotLine = 16 << 20
+
+var nextLink = regexp.MustCompile(`<([^>]*)>\s*;[^,]*\brel="?next"?`)
+
+// RunVerify is the verify command: it reconciles the messages of one tenant
+// in Redis with an authoritative source, writes every difference to stdout
+// as a JSON line and returns the exit status: 0 when Redis matches (after
+// repairs), 1 when differences remain and 2 when the check failed. Repairs
+// go through the handlers of the event queue. Without --as-of they are
+// refused while the consumer runs, as an event it applies meanwhile would be
+// overwritten with what the source had before.
+func RunVerify(args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	snapshot := flags.String("snapshot", "", "JSONL file with one authoritative message per line")
+	upstream := flags.String("upstream", "", "URL of the first page of authoritative messages, a JSON array; later pages are followed through Link rel=\"next\"")
+	tenant := flags.String("tenant", domain.DefaultTenant, "tenant the messages belong to")
+	repair := flags.Bool("repair", false, "update missing and mismatched messages and delete extra ones")
+	asOf := flags.String("as-of", "", "RFC 3339 time the source was taken; messages Redis changed after it are left alone")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout of each upstream request")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if (*snapshot == "") == (*upstream == "") {
+		fmt.Fprintln(os.Stderr, "verify: give exactly one of --snapshot and --upstream")
+		return 2
+	}
+	normalized, tenantErr := domain.NormalizeTenant(*tenant)
+	if tenantErr != nil {
+		fmt.Fprintf(os.Stderr, "verify: %s\n", tenantErr.Message())
+		return 2
+	}
+	var cutoff time.Time
+	if *asOf != "" {
+		parsed, err := time.Parse(time.RFC3339, *asOf)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "verify: invalid --as-of: %s\n", err)
+			return 2
+		}
+		cutoff = parsed
+	}
+	if err := domain.InitializeQuotas(os.Getenv("TENANT_QUOTAS")); err != nil {
+		fmt.Fprintf(os.Stderr, "verify: invalid tenant quota configuration: %s\n", err)
+		return 2
+	}
+	var repairs services.RepairFunc
+	if *repair {
+		if cutoff.IsZero() {
+			running, err := consumerRunning(os.Getenv("RABBITMQ_URL"))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "verify: cannot tell whether the consumer is running, give --as-of to repair: %s: %s\n", err.Message(), err.Detail())
+				return 2
+			}
+			if running {
+				fmt.Fprintln(os.Stderr, "verify: the consumer is running, give --as-of to repair")
+				return 2
+			}
+		}
+		repairs = handlerRepairs()
+	}
+
+	source := snapshotSource(*snapshot)
+	if *upstream != "" {
+		source = upstreamSource(&http.Client{Timeout: *timeout}, *upstream, normalized)
+	}
+	initializeChanges(connectRedis())
+
+	output := json.NewEncoder(os.Stdout)
+	summary, err := services.ReconcileService.Reconcile(normalized, source, cutoff, repairs, func(drift domain.MessageDrift) {
+		output.Encode(drift)
+	})
+	services.WebhooksService.Wait()
+	log.Printf("Checked %d messages: %d missing, %d extra, %d mismatched, %d newer than the source, %d repaired, %d repairs failed",
+		summary.Checked, summary.Missing, summary.Extra, summary.Mismatched, summary.Newer, summary.Repaired, summary.RepairFailed)
+	if err != nil {
+		log.Printf("Verification failed: %s: %s", err.Message(), err.Detail())
+		return 2
+	}
+	if !summary.InSync() {
+		return 1
+	}
+	return 0
+}
+
+// consumerRunning asks the broker at url whether anything consumes the event
+// queue.
+func consumerRunning(url string) (bool, error_utils.MessageErr) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return false, error_formats.Translate(err, "amqp dial")
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return false, error_formats.Translate(err, "amqp channel")
+	}
+	queue, err := ch.QueueInspect(eventQueue)
+	var amqpErr *amqp.Error
+	if errors.As(err, &amqpErr) && amqpErr.Code == amqp.NotFound {
+		return false, nil
+	} else if err != nil {
+		return false, error_formats.Translate(err, "amqp queue inspect")
+	}
+	return queue.Consumers > 0, nil
+}
+
+// handlerRepairs applies repairs as events, with the handlers the consumer
+// uses, so they reach the cache, the change feed, history and webhooks.
+func handlerRepairs() services.RepairFunc {
+	dispatcher := events.NewDispatcher()
+	events.RegisterMessageHandlers(dispatcher, softDelete)
+	return func(tenant, event string, msg *domain.Message) error_utils.MessageErr {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return error_formats.Translate(err, "json marshal")
+		}
+		return dispatcher.Dispatch(&events.Event{Type: event, Tenant: tenant, Data: data})
+	}
+}
+
+// snapshotSource reads the messages of a JSONL file.
+func snapshotSource(path string) services.MessageSource {
+	return func(fn func(*domain.Message) error_utils.MessageErr) error_utils.MessageErr {
+		file, err := os.Open(path)
+		if err != nil {
+			return error_formats.Translate(err, "snapshot open")
+		}
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxSnapshotLine)
+		for line := 1; scanner.Scan(); line++ {
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			var msg domain.Message
+			if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+				return error_utils.NewBadRequestError(fmt.Sprintf("invalid message on line %d of the snapshot", line)).Wrap(err)
+			}
+			if err := fn(&msg); err != nil {
+				return err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return error_formats.Translate(err, "snapshot read")
+		}
+		return nil
+	}
+}
+
+// upstreamSource pages through an HTTP endpoint answering with JSON arrays
+// of messages, following the Link header's rel="next" URL until there is
+// none. The tenant is passed in X-Tenant-ID, as for read-through.
+func upstreamSource(client *http.Client, first, tenant string) services.MessageSource {
+	return func(fn func(*domain.Message) error_utils.MessageErr) error_utils.MessageErr {
+		for page := first; page != ""; {
+			messages, next, err := fetchPage(client, page, tenant)
+			if err != nil {
+				return err
+			}
+			for i := range messages {
+				if err := fn(&messages[i]); err != nil {
+					return err
+				}
+			}
+			page = next
+		}
+		return nil
+	}
+}
+
+func fetchPage(client *http.Client, page, tenant string) ([]domain.Message, string, error_utils.MessageErr) {
+	req, err := http.NewRequest(http.MethodGet, page, nil)
+	if err != nil {
+		return nil, "", error_utils.NewBadRequestError("invalid upstream url").Wrap(err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Tenant-ID", tenant)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", error_formats.Translate(err, "upstream page "+page)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, "", error_utils.NewServiceUnavailableError("upstream unavailable").
+			WithCode(error_utils.CodeUpstreamUnavailable).
+			WithDetail(fmt.Sprintf("upstream page %s answered %d", page, resp.StatusCode))
+	}
+	var messages []domain.Message
+	if err := json.NewDecoder(resp.Body).Decode(&messages); err != nil {
+		return nil, "", error_formats.Translate(err, "upstream page decode")
+	}
+
+	match := nextLink.FindStringSubmatch(resp.Header.Get("Link"))
+	if match == nil {
+		return messages, "", nil
+	}
+	next, err := url.Parse(match[1])
+	if err != nil {
+		return nil, "", error_utils.NewServiceUnavailableError("upstream unavailable").WithCode(error_utils.CodeUpstreamUnavailable).Wrap(err)
+	}
+	return messages, req.URL.ResolveReference(next).String(), nil
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+func collect(source services.MessageSource) ([]int64, error_utils.MessageErr) {
+	var ids []int64
+	err := source(func(msg *domain.Message) error_utils.MessageErr {
+		ids = append(ids, msg.Id)
+		return nil
+	})
+	return ids, err
+}
+
+func TestSnapshotSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.jsonl")
+	os.WriteFile(path, []byte("{\"id\":1,\"title\":\"a\",\"body\":\"b\"}\n\n{\"id\":2,\"title\":\"c\",\"body\":\"d\"}\n"), 0o600)
+
+	ids, err := collect(snapshotSource(path))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 2}, ids)
+}
+
+func TestSnapshotSource_Invalid_Line(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.jsonl")
+	os.WriteFile(path, []byte("{\"id\":1}\nnot json\n"), 0o600)
+
+	_, err := collect(snapshotSource(path))
+
+	assert.Equal(t, "invalid message on line 2 of the snapshot", err.Message())
+}
+
+func TestUpstreamSource_Follows_Next_Links(t *testing.T) {
+	var tenants []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenants = append(tenants, r.Header.Get("X-Tenant-ID"))
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", `</messages?page=2>; rel="next", </messages>; rel="first"`)
+			fmt.Fprint(w, `[{"id":1},{"id":2}]`)
+		case "2":
+			w.Header().Set("Link", `</messages>; rel="first"`)
+			fmt.Fprint(w, `[{"id":3}]`)
+		}
+	}))
+	defer server.Close()
+
+	ids, err := collect(upstreamSource(server.Client(), server.URL+"/messages", "team-a"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+	assert.Equal(t, []string{"team-a", "team-a"}, tenants)
+}
+
+func TestUpstreamSource_Failure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	_, err := collect(upstreamSource(server.Client(), server.URL, "default"))
+
+	assert.Equal(t, error_utils.CodeUpstreamUnavailable, err.Code())
+}
+
+func TestRunVerify_Usage(t *testing.T) {
+	assert.Equal(t, 2, RunVerify([]string{}))
+	assert.Equal(t, 2, RunVerify([]string{"--snapshot", "a.jsonl", "--upstream", "http://example.com"}))
+	assert.Equal(t, 2, RunVerify([]string{"--snapshot", "a.jsonl", "--tenant", "bad tenant!"}))
+	assert.Equal(t, 2, RunVerify([]string{"--snapshot", "a.jsonl", "--as-of", "yesterday"}))
+}
+
+func TestRunVerify_Repair_Needs_As_Of_Without_Broker(t *testing.T) {
+	t.Setenv("RABBITMQ_URL", "amqp://127.0.0.1:1/")
+
+	assert.Equal(t, 2, RunVerify([]string{"--snapshot", "a.jsonl", "--repair"}))
+}
+
+func TestHandlerRepairs(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	domain.MessageRepo = domain.NewMessageRepository(client)
+	domain.HistoryRepo = domain.NewHistoryRepository(client, 10, 0)
+	domain.ChangeFeed = domain.NewChangeFeed(client, 10)
+	domain.CacheInvalidations = domain.NewCacheInvalidations(client)
+	domain.WebhookRepo = domain.NewWebhookRepository(client)
+	repair := handlerRepairs()
+
+	err := repair("team-a", domain.EventUpdated, &domain.Message{Id: 1, Title: "title", Body: "body"})
+
+	assert.Nil(t, err)
+	stored, _ := domain.MessageRepo.ForTenant("team-a").Get(1)
+	assert.Equal(t, "title", stored.Title)
+	latest, _ := domain.HistoryRepo.Latest("team-a", 1)
+	assert.Equal(t, domain.EventUpdated, latest.Event)
+
+	assert.Nil(t, repair("team-a", domain.EventDeleted, stored))
+	assert.False(t, server.Exists("tenant:team-a:message:1"))
+}
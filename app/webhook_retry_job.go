@@ -0,0 +1,20 @@
+package app
+
+import (
+	"testing-project/services"
+	"time"
+)
+
+var (
+	webhookRetryInterval = time.Second
+)
+
+// startWebhookRetryJob attempts the webhook deliveries that are due to be
+// retried. Every replica runs it; each retry is claimed by one of them.
+func startWebhookRetryJob() {
+	ticker := time.NewTicker(webhookRetryInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		services.WebhooksService.RetryDue()
+	}
+}
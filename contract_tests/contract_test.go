@@ -20,18 +20,14 @@ func TestGetMessage(t *testing.T) {
 	baseURL := "http://localhost:8090"
 	messageID := 1
 
-	resp, err := http.Get(fmt.Sprintf("%s/messages/%d", baseURL, messageID))
-	if err != nil {
-		t.Fatalf("Помилка при надсиланні запиту: %v", err)
-	}
-	defer resp.Body.Close()
+	resp, body := getConforming(t, fmt.Sprintf("%s/messages/%d", baseURL, messageID), nil)
 
 	if resp.StatusCode != http.StatusOK {
 		t.Fatalf("Очікувався статус 200 OK, отримано %d", resp.StatusCode)
 	}
 
 	var msg Message
-	if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+	if err := json.Unmarshal(body, &msg); err != nil {
 		t.Fatalf("Помилка при декодуванні відповіді: %v", err)
 	}
 
@@ -43,18 +39,14 @@ func TestGetMessage(t *testing.T) {
 func TestGetAllMessages(t *testing.T) {
 	baseURL := "http://localhost:8090"
 
-	resp, err := http.Get(fmt.Sprintf("%s/messages", baseURL))
-	if err != nil {
-		t.Fatalf("Помилка при надсиланні запиту: %v", err)
-	}
-	defer resp.Body.Close()
+	resp, body := getConforming(t, fmt.Sprintf("%s/messages", baseURL), nil)
 
 	if resp.StatusCode != http.StatusOK {
 		t.Fatalf("Очікувався статус 200 OK, отримано %d", resp.StatusCode)
 	}
 
 	var messages []Message
-	if err := json.NewDecoder(resp.Body).Decode(&messages); err != nil {
+	if err := json.Unmarshal(body, &messages); err != nil {
 		t.Fatalf("Помилка при декодуванні списку повідомлень: %v", err)
 	}
 
@@ -67,11 +59,7 @@ func TestGetMessageNotFound(t *testing.T) {
 	baseURL := "http://localhost:8090"
 	messageID := 9999
 
-	resp, err := http.Get(fmt.Sprintf("%s/messages/%d", baseURL, messageID))
-	if err != nil {
-		t.Fatalf("Помилка при надсиланні запиту: %v", err)
-	}
-	defer resp.Body.Close()
+	resp, _ := getConforming(t, fmt.Sprintf("%s/messages/%d", baseURL, messageID), nil)
 
 	if resp.StatusCode != http.StatusNotFound {
 		t.Errorf("Очікувався статус 404 Not Found, отримано %d", resp.StatusCode)
@@ -107,6 +95,7 @@ func publishEvent(eventType string, message Message) error {
 		false,
 		amqp.Publishing{
 			ContentType: "text/plain",
+			MessageId:   fmt.Sprintf("%s-%d-%d", eventType, message.Id, time.Now().UnixNano()),
 			Body:        body,
 		},
 	)
@@ -131,18 +120,14 @@ func TestCreateMessageEvent(t *testing.T) {
 	}
 	time.Sleep(2 * time.Second)
 
-	resp, err := http.Get(fmt.Sprintf("%s/messages/%d", baseURL, message.Id))
-	if err != nil {
-		t.Fatalf("Request failed: %v", err)
-	}
-	defer resp.Body.Close()
+	resp, data := getConforming(t, fmt.Sprintf("%s/messages/%d", baseURL, message.Id), nil)
 
 	if resp.StatusCode != http.StatusOK {
 		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 
 	var received Message
-	if err := json.NewDecoder(resp.Body).Decode(&received); err != nil {
+	if err := json.Unmarshal(data, &received); err != nil {
 		t.Fatalf("Failed to decode response: %v", err)
 	}
 
@@ -181,18 +166,14 @@ func TestUpdateMessageEvent(t *testing.T) {
 	}
 	time.Sleep(2 * time.Second)
 
-	resp, err := http.Get(fmt.Sprintf("%s/messages/%d", baseURL, message.Id))
-	if err != nil {
-		t.Fatalf("Request failed: %v", err)
-	}
-	defer resp.Body.Close()
+	resp, data := getConforming(t, fmt.Sprintf("%s/messages/%d", baseURL, message.Id), nil)
 
 	if resp.StatusCode != http.StatusOK {
 		t.Fatalf("Expected status %d, got %d", http.StatusOK, resp.StatusCode)
 	}
 
 	var updated Message
-	if err := json.NewDecoder(resp.Body).Decode(&updated); err != nil {
+	if err := json.Unmarshal(data, &updated); err != nil {
 		t.Fatalf("Failed to decode response: %v", err)
 	}
 
@@ -229,11 +210,7 @@ func TestDeleteMessageEvent(t *testing.T) {
 	}
 	time.Sleep(2 * time.Second)
 
-	resp, err := http.Get(fmt.Sprintf("%s/messages/%d", baseURL, message.Id))
-	if err != nil {
-		t.Fatalf("Request failed: %v", err)
-	}
-	defer resp.Body.Close()
+	resp, _ := getConforming(t, fmt.Sprintf("%s/messages/%d", baseURL, message.Id), nil)
 
 	if resp.StatusCode != http.StatusNotFound {
 		t.Errorf("Expected status 404 Not Found, got %d", resp.StatusCode)
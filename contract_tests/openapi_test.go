@@ -0,0 +1,87 @@
+package contract_tests
+
+import (
+	"github.com/getkin/kin-openapi/openapi3"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"testing-project/openapi"
+)
+
+var (
+	specOnce      sync.Once
+	specValidator *openapi.Validator
+	specErr       error
+)
+
+// contractValidator loads the OpenAPI document the running service serves.
+func contractValidator(t *testing.T) *openapi.Validator {
+	specOnce.Do(func() {
+		resp, err := http.Get("http://localhost:8090/openapi.json")
+		if err != nil {
+			specErr = err
+			return
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			specErr = err
+			return
+		}
+		doc, err := openapi3.NewLoader().LoadFromData(data)
+		if err != nil {
+			specErr = err
+			return
+		}
+		specValidator, specErr = openapi.NewValidator(doc)
+	})
+	if specErr != nil {
+		t.Fatalf("Failed to load the OpenAPI document: %v", specErr)
+	}
+	return specValidator
+}
+
+// getConforming sends a GET request and fails the test unless the response
+// matches the OpenAPI document. It returns the response with its body read.
+func getConforming(t *testing.T, url string, header http.Header) (*http.Response, []byte) {
+	validator := contractValidator(t)
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if err := validator.CheckRequest(req); err != nil {
+		t.Fatalf("Request does not match the OpenAPI document: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	if err := validator.CheckResponse(req, resp.StatusCode, resp.Header, body); err != nil {
+		t.Errorf("Response does not match the OpenAPI document: %v", err)
+	}
+	return resp, body
+}
+
+func TestGetMessageStats(t *testing.T) {
+	resp, _ := getConforming(t, "http://localhost:8090/messages/stats?bucket=hour", nil)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200 OK, got %d", resp.StatusCode)
+	}
+}
+
+func TestGetAllMessagesFormats(t *testing.T) {
+	for _, accept := range []string{"application/x-ndjson", "text/csv", "application/msgpack"} {
+		resp, _ := getConforming(t, "http://localhost:8090/messages", http.Header{"Accept": {accept}})
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status 200 OK for %s, got %d", accept, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing-project/services"
+)
+
+func GetCacheStats(c *gin.Context) {
+	c.JSON(http.StatusOK, services.MessageCache.Stats())
+}
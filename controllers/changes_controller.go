@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"log"
+	"net/http"
+	"testing-project/domain"
+	"testing-project/middlewares"
+	"testing-project/services"
+	"time"
+)
+
+var (
+	keepAliveInterval = 15 * time.Second
+	upgrader          = websocket.Upgrader{}
+)
+
+// StreamMessages pushes message changes as Server-Sent Events. Clients resume
+// after a disconnect with the standard Last-Event-ID header.
+func StreamMessages(c *gin.Context) {
+	lastId := c.GetHeader("Last-Event-ID")
+	if lastId == "" {
+		lastId = c.Query("last_event_id")
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	streamChanges(c, c.Request.Context(), lastId,
+		func(change domain.MessageChange) error {
+			c.Render(http.StatusOK, sse.Event{Id: change.Id, Event: change.Event, Data: change})
+			c.Writer.Flush()
+			return nil
+		},
+		func() error {
+			c.Status(http.StatusOK)
+			if _, err := fmt.Fprint(c.Writer, ": keep-alive\n\n"); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+			return nil
+		},
+	)
+}
+
+// StreamMessagesWS pushes message changes as JSON text frames over a
+// WebSocket. Browsers cannot set headers on the handshake, so resumption
+// uses the last_event_id query parameter.
+func StreamMessagesWS(c *gin.Context) {
+	lastId := c.Query("last_event_id")
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade websocket: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	streamCtx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	streamChanges(c, streamCtx, lastId,
+		func(change domain.MessageChange) error {
+			return conn.WriteJSON(change)
+		},
+		func() error {
+			return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(keepAliveInterval))
+		},
+	)
+}
+
+// streamChanges replays missed changes after lastId and then forwards live
+// ones until the client goes away or the subscription is dropped. It
+// subscribes before replaying so nothing applied in between is lost.
+func streamChanges(c *gin.Context, streamCtx context.Context, lastId string, emit func(domain.MessageChange) error, ping func() error) {
+	tenant := middlewares.TenantFrom(c)
+	changes, unsubscribe := services.ChangesService.Subscribe(tenant)
+	defer unsubscribe()
+
+	missed, err := services.ChangesService.Replay(tenant, lastId)
+	if err != nil {
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Type")
+			middlewares.WriteError(c, err)
+		}
+		return
+	}
+	for _, change := range missed {
+		if emit(change) != nil {
+			return
+		}
+		lastId = change.Id
+	}
+	if ping() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(keepAliveInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-streamCtx.Done():
+			return
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+			if lastId != "" && domain.CompareChangeIds(change.Id, lastId) <= 0 {
+				continue
+			}
+			if emit(change) != nil {
+				return
+			}
+			lastId = change.Id
+		case <-heartbeat.C:
+			if ping() != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+var (
+	subscribeService func() (<-chan domain.MessageChange, func())
+	replayService    func(tenant, lastId string) ([]domain.MessageChange, error_utils.MessageErr)
+)
+
+type changesServiceMock struct{}
+
+func (cm *changesServiceMock) Subscribe(tenant string) (<-chan domain.MessageChange, func()) {
+	return subscribeService()
+}
+
+func (cm *changesServiceMock) Replay(tenant, lastId string) ([]domain.MessageChange, error_utils.MessageErr) {
+	return replayService(tenant, lastId)
+}
+
+func liveChanges(changes ...domain.MessageChange) func() (<-chan domain.MessageChange, func()) {
+	return func() (<-chan domain.MessageChange, func()) {
+		ch := make(chan domain.MessageChange, len(changes))
+		for _, change := range changes {
+			ch <- change
+		}
+		close(ch)
+		return ch, func() {}
+	}
+}
+
+// "StreamMessages" test cases
+
+func TestStreamMessages_Replay_And_Live(t *testing.T) {
+	services.ChangesService = &changesServiceMock{}
+	var requestedId, requestedTenant string
+	replayService = func(tenant, lastId string) ([]domain.MessageChange, error_utils.MessageErr) {
+		requestedId = lastId
+		requestedTenant = tenant
+		return []domain.MessageChange{
+			{Id: "5-0", Event: "updated", MessageId: 1},
+		}, nil
+	}
+	subscribeService = liveChanges(
+		domain.MessageChange{Id: "5-0", Event: "updated", MessageId: 1},
+		domain.MessageChange{Id: "6-0", Event: "deleted", MessageId: 2},
+	)
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/stream", nil)
+	req.Header.Set("Last-Event-ID", "4-0")
+	rr := httptest.NewRecorder()
+	r.GET("/messages/stream", StreamMessages)
+	r.ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "4-0", requestedId)
+	assert.EqualValues(t, domain.DefaultTenant, requestedTenant)
+	assert.EqualValues(t, "text/event-stream", rr.Header().Get("Content-Type"))
+	assert.EqualValues(t, 1, strings.Count(body, "id:5-0"))
+	assert.Contains(t, body, "event:updated")
+	assert.Contains(t, body, "id:6-0")
+	assert.Contains(t, body, "event:deleted")
+}
+
+func TestStreamMessages_Replay_Error(t *testing.T) {
+	services.ChangesService = &changesServiceMock{}
+	replayService = func(tenant, lastId string) ([]domain.MessageChange, error_utils.MessageErr) {
+		return nil, error_utils.NewInternalServerError("redis stream range error")
+	}
+	subscribeService = liveChanges()
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/stream?last_event_id=1-0", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/stream", StreamMessages)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusInternalServerError, apiErr.Status())
+	assert.EqualValues(t, "redis stream range error", apiErr.Message())
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"testing-project/middlewares"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+// defaultDeadLetterLimit is how many dead-lettered events a listing returns
+// without a limit.
+const defaultDeadLetterLimit = 50
+
+func GetConsumer(c *gin.Context) {
+	c.JSON(http.StatusOK, services.ConsumerService.GetState())
+}
+
+func PauseConsumer(c *gin.Context) {
+	state, pauseErr := services.ConsumerService.Pause()
+	if pauseErr != nil {
+		middlewares.WriteError(c, pauseErr)
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
+
+func ResumeConsumer(c *gin.Context) {
+	state, resumeErr := services.ConsumerService.Resume()
+	if resumeErr != nil {
+		middlewares.WriteError(c, resumeErr)
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
+
+func ReconnectConsumer(c *gin.Context) {
+	state, reconnectErr := services.ConsumerService.Reconnect()
+	if reconnectErr != nil {
+		middlewares.WriteError(c, reconnectErr)
+		return
+	}
+	c.JSON(http.StatusAccepted, state)
+}
+
+// GetDeadLetters lists the oldest dead-lettered events, up to the limit
+// query parameter.
+func GetDeadLetters(c *gin.Context) {
+	limit := defaultDeadLetterLimit
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			parsed = -1
+		}
+		limit = parsed
+	}
+	if limitErr := services.ValidateDeadLetterLimit(limit); limitErr != nil {
+		middlewares.WriteError(c, limitErr)
+		return
+	}
+	letters, getErr := services.ConsumerService.GetDeadLetters(limit)
+	if getErr != nil {
+		middlewares.WriteError(c, getErr)
+		return
+	}
+	c.JSON(http.StatusOK, letters)
+}
+
+// RequeueDeadLetters puts the dead-lettered events selected by a body of
+// {"ids": [...]} back on the event queue.
+func RequeueDeadLetters(c *gin.Context) {
+	var body struct {
+		Ids []string `json:"ids"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || len(body.Ids) == 0 {
+		theErr := error_utils.NewBadRequestError("invalid json body, ids are required")
+		middlewares.WriteError(c, theErr)
+		return
+	}
+	result, requeueErr := services.ConsumerService.Requeue(body.Ids)
+	if requeueErr != nil {
+		middlewares.WriteError(c, requeueErr)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+var (
+	deadLetterLimit int
+	requeuedIds     []string
+	reconnectErr    error_utils.MessageErr
+)
+
+type consumerServiceMock struct{}
+
+func (cm *consumerServiceMock) GetState() domain.ConsumerState {
+	return domain.ConsumerState{Connected: true, Queue: "my_queue", Events: map[string]*domain.EventCounters{"created": {Received: 1, Applied: 1}}}
+}
+func (cm *consumerServiceMock) Pause() (domain.ConsumerState, error_utils.MessageErr) {
+	return domain.ConsumerState{Connected: true, Paused: true}, nil
+}
+func (cm *consumerServiceMock) Resume() (domain.ConsumerState, error_utils.MessageErr) {
+	return domain.ConsumerState{Connected: true}, nil
+}
+func (cm *consumerServiceMock) Reconnect() (domain.ConsumerState, error_utils.MessageErr) {
+	return domain.ConsumerState{}, reconnectErr
+}
+func (cm *consumerServiceMock) GetDeadLetters(limit int) ([]domain.DeadLetter, error_utils.MessageErr) {
+	deadLetterLimit = limit
+	return []domain.DeadLetter{{Id: "a1", Event: "created", Reason: "invalid message title", Body: "{}"}}, nil
+}
+func (cm *consumerServiceMock) Requeue(ids []string) (*domain.RequeueResult, error_utils.MessageErr) {
+	requeuedIds = ids
+	return &domain.RequeueResult{Requeued: ids[:1], NotFound: ids[1:]}, nil
+}
+
+func TestGetConsumer(t *testing.T) {
+	services.ConsumerService = &consumerServiceMock{}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/admin/consumer", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/admin/consumer", GetConsumer)
+	r.ServeHTTP(rr, req)
+
+	var state domain.ConsumerState
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &state))
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.True(t, state.Connected)
+	assert.EqualValues(t, 1, state.Events["created"].Applied)
+}
+
+func TestPauseConsumer(t *testing.T) {
+	services.ConsumerService = &consumerServiceMock{}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPost, "/admin/consumer/pause", nil)
+	rr := httptest.NewRecorder()
+	r.POST("/admin/consumer/pause", PauseConsumer)
+	r.ServeHTTP(rr, req)
+
+	var state domain.ConsumerState
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &state))
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.True(t, state.Paused)
+}
+
+func TestReconnectConsumer_Not_Running(t *testing.T) {
+	services.ConsumerService = &consumerServiceMock{}
+	reconnectErr = error_utils.NewServiceUnavailableError("the consumer is not running").WithCode(error_utils.CodeConsumerNotRunning)
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPost, "/admin/consumer/reconnect", nil)
+	rr := httptest.NewRecorder()
+	r.POST("/admin/consumer/reconnect", ReconnectConsumer)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusServiceUnavailable, rr.Code)
+	assert.EqualValues(t, "the consumer is not running", apiErr.Message())
+}
+
+func TestGetDeadLetters_Default_Limit(t *testing.T) {
+	services.ConsumerService = &consumerServiceMock{}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/admin/consumer/dead-letters", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/admin/consumer/dead-letters", GetDeadLetters)
+	r.ServeHTTP(rr, req)
+
+	var letters []domain.DeadLetter
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &letters))
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, 50, deadLetterLimit)
+	assert.EqualValues(t, "a1", letters[0].Id)
+}
+
+func TestGetDeadLetters_Invalid_Limit(t *testing.T) {
+	services.ConsumerService = &consumerServiceMock{}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/admin/consumer/dead-letters?limit=many", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/admin/consumer/dead-letters", GetDeadLetters)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestRequeueDeadLetters(t *testing.T) {
+	services.ConsumerService = &consumerServiceMock{}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPost, "/admin/consumer/dead-letters/requeue", strings.NewReader(`{"ids":["a1","b2"]}`))
+	rr := httptest.NewRecorder()
+	r.POST("/admin/consumer/dead-letters/requeue", RequeueDeadLetters)
+	r.ServeHTTP(rr, req)
+
+	var result domain.RequeueResult
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &result))
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, []string{"a1", "b2"}, requeuedIds)
+	assert.EqualValues(t, []string{"a1"}, result.Requeued)
+	assert.EqualValues(t, []string{"b2"}, result.NotFound)
+}
+
+func TestRequeueDeadLetters_No_Ids(t *testing.T) {
+	services.ConsumerService = &consumerServiceMock{}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPost, "/admin/consumer/dead-letters/requeue", strings.NewReader(`{"ids":[]}`))
+	rr := httptest.NewRecorder()
+	r.POST("/admin/consumer/dead-letters/requeue", RequeueDeadLetters)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusBadRequest, rr.Code)
+}
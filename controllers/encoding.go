@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strings"
+	"testing-project/middlewares"
+	"testing-project/utils/encoders"
+	"testing-project/utils/error_utils"
+)
+
+// negotiate picks the encoder for the response from the Accept header.
+// Error responses are always JSON, legacy or problem, whatever was
+// negotiated.
+func negotiate(c *gin.Context) (encoders.Encoder, error_utils.MessageErr) {
+	c.Header("Vary", "Accept")
+	encoder, ok := encoders.Negotiate(c.GetHeader("Accept"))
+	if !ok {
+		return nil, error_utils.NewNotAcceptableError("Accept should allow one of " + strings.Join(encoders.MediaTypes(), ", "))
+	}
+	return encoder, nil
+}
+
+// render writes v with the negotiated encoder.
+func render(c *gin.Context, status int, encoder encoders.Encoder, v interface{}) {
+	c.Header("Content-Type", encoder.ContentType())
+	c.Status(status)
+	if err := encoder.Encode(c.Writer, v); err != nil {
+		log.Printf("Failed to encode response: %s", err)
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Type")
+			middlewares.WriteError(c, error_utils.NewInternalServerError("response encoding error"))
+		}
+	}
+}
+
+// streamer writes a listing element by element. The response only starts
+// with the first element, so an error that comes before it can still be sent
+// as an ordinary error response.
+type streamer struct {
+	c       *gin.Context
+	encoder encoders.StreamEncoder
+	stream  encoders.Stream
+}
+
+func (s *streamer) Write(v interface{}) error {
+	if s.stream == nil {
+		s.begin()
+	}
+	return s.stream.Write(v)
+}
+
+func (s *streamer) begin() {
+	s.c.Header("Content-Type", s.encoder.ContentType())
+	s.c.Status(http.StatusOK)
+	s.stream = s.encoder.NewStream(s.c.Writer)
+}
+
+// Finish ends the response: with the error when nothing has been written
+// yet, by aborting the handler when the error came midway, and by closing
+// the stream otherwise. The abort makes the server drop the connection, so
+// the client sees a truncated body instead of a complete listing; it needs
+// middlewares.Recovery to let the panic through.
+func (s *streamer) Finish(err error_utils.MessageErr) {
+	if err != nil {
+		if s.stream == nil {
+			middlewares.WriteError(s.c, err)
+			return
+		}
+		log.Printf("Failed to stream response: %s: %s", err.Message(), err.Detail())
+		panic(http.ErrAbortHandler)
+	}
+	if s.stream == nil {
+		s.begin()
+	}
+	if closeErr := s.stream.Close(); closeErr != nil {
+		log.Printf("Failed to stream response: %s", closeErr)
+	}
+}
@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"testing-project/middlewares"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+func GetMessageHistory(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		middlewares.WriteError(c, err)
+		return
+	}
+	includeDeleted, err := getIncludeDeleted(c)
+	if err != nil {
+		middlewares.WriteError(c, err)
+		return
+	}
+	versions, getErr := services.HistoryService.GetHistory(middlewares.TenantFrom(c), msgId, includeDeleted)
+	if getErr != nil {
+		middlewares.WriteError(c, getErr)
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+func GetMessageVersion(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		middlewares.WriteError(c, err)
+		return
+	}
+	n, parseErr := strconv.ParseInt(c.Param("n"), 10, 64)
+	if parseErr != nil || n < 1 {
+		theErr := error_utils.NewBadRequestError("version should be a positive number").WithCode(error_utils.CodeInvalidVersion)
+		middlewares.WriteError(c, theErr)
+		return
+	}
+	includeDeleted, err := getIncludeDeleted(c)
+	if err != nil {
+		middlewares.WriteError(c, err)
+		return
+	}
+	version, getErr := services.HistoryService.GetVersion(middlewares.TenantFrom(c), msgId, n, includeDeleted)
+	if getErr != nil {
+		middlewares.WriteError(c, getErr)
+		return
+	}
+	c.JSON(http.StatusOK, version)
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing-project/domain"
+	"testing-project/middlewares"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+var (
+	getVersionService func(msgId int64, n int64) (*domain.MessageVersion, error_utils.MessageErr)
+)
+
+type historyServiceMock struct{}
+
+func (hm *historyServiceMock) GetHistory(tenant string, msgId int64, includeDeleted bool) ([]domain.MessageVersion, error_utils.MessageErr) {
+	return []domain.MessageVersion{{Version: 1, Event: domain.EventCreated}}, nil
+}
+func (hm *historyServiceMock) GetVersion(tenant string, msgId int64, n int64, includeDeleted bool) (*domain.MessageVersion, error_utils.MessageErr) {
+	return getVersionService(msgId, n)
+}
+
+func TestGetMessageHistory_Success(t *testing.T) {
+	services.HistoryService = &historyServiceMock{}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1/history", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id/history", GetMessageHistory)
+	r.ServeHTTP(rr, req)
+
+	var versions []domain.MessageVersion
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &versions))
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, 1, len(versions))
+}
+
+func TestGetMessageVersion_Success(t *testing.T) {
+	services.HistoryService = &historyServiceMock{}
+	getVersionService = func(msgId int64, n int64) (*domain.MessageVersion, error_utils.MessageErr) {
+		return &domain.MessageVersion{Version: n, Event: domain.EventUpdated, Data: &domain.Message{Id: msgId}}, nil
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/4/versions/3", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id/versions/:n", GetMessageVersion)
+	r.ServeHTTP(rr, req)
+
+	var version domain.MessageVersion
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &version))
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, 3, version.Version)
+	assert.EqualValues(t, 4, version.Data.Id)
+}
+
+func TestGetMessageVersion_Invalid_Version(t *testing.T) {
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/4/versions/0", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id/versions/:n", GetMessageVersion)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+	assert.EqualValues(t, "version should be a positive number", apiErr.Message())
+}
+
+func TestGetMessageHistory_Include_Deleted_Requires_Admin(t *testing.T) {
+	authenticator, _ := middlewares.NewApiKeyAuthenticator(middlewares.HashApiKey("reader") + "=" + middlewares.ScopeMessagesRead)
+	middlewares.Authenticators = []middlewares.Authenticator{authenticator}
+	defer func() { middlewares.Authenticators = nil }()
+
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1/history?include_deleted=true", nil)
+	req.Header.Set("X-API-Key", "reader")
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id/history", middlewares.RequireScopes(middlewares.ScopeMessagesRead), GetMessageHistory)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusForbidden, rr.Code)
+}
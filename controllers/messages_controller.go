@@ -4,37 +4,152 @@ import (
 	"github.com/gin-gonic/gin"
 	"net/http"
 	"strconv"
+	"strings"
+	"testing-project/domain"
+	"testing-project/middlewares"
 	"testing-project/services"
+	"testing-project/utils/encoders"
 	"testing-project/utils/error_utils"
+	"time"
 )
 
 func getMessageId(msgIdParam string) (int64, error_utils.MessageErr) {
 	msgId, msgErr := strconv.ParseInt(msgIdParam, 10, 64)
 	if msgErr != nil {
-		return 0, error_utils.NewBadRequestError("message id should be a number")
+		return 0, error_utils.NewBadRequestError("message id should be a number").WithCode(error_utils.CodeInvalidMessageId)
 	}
 	return msgId, nil
 }
 
+// getIncludeDeleted reads the include_deleted query parameter, which only
+// admins may set.
+func getIncludeDeleted(c *gin.Context) (bool, error_utils.MessageErr) {
+	raw := c.Query("include_deleted")
+	if raw == "" {
+		return false, nil
+	}
+	includeDeleted, parseErr := strconv.ParseBool(raw)
+	if parseErr != nil {
+		return false, error_utils.NewBadRequestError("include_deleted should be a boolean").WithCode(error_utils.CodeInvalidIncludeDeleted)
+	}
+	if includeDeleted && !middlewares.HasScope(c, middlewares.ScopeAdmin) {
+		return false, error_utils.NewForbiddenError("include_deleted requires the " + middlewares.ScopeAdmin + " scope")
+	}
+	return includeDeleted, nil
+}
+
+const maxFilterIds = 100
+
+// getMessageFilter reads the listing filters from the query string.
+func getMessageFilter(c *gin.Context) (domain.MessageFilter, error_utils.MessageErr) {
+	var filter domain.MessageFilter
+	var err error_utils.MessageErr
+	if filter.TitlePrefix, err = getTextParam(c, "title_prefix"); err != nil {
+		return filter, err.WithCode(error_utils.CodeInvalidFilter)
+	}
+	if filter.TitleContains, err = getTextParam(c, "title_contains"); err != nil {
+		return filter, err.WithCode(error_utils.CodeInvalidFilter)
+	}
+	if filter.CreatedAfter, err = getTimeParam(c, "created_after"); err != nil {
+		return filter, err.WithCode(error_utils.CodeInvalidFilter)
+	}
+	if filter.CreatedBefore, err = getTimeParam(c, "created_before"); err != nil {
+		return filter, err.WithCode(error_utils.CodeInvalidFilter)
+	}
+	if filter.CreatedAfter != nil && filter.CreatedBefore != nil && !filter.CreatedAfter.Before(*filter.CreatedBefore) {
+		return filter, error_utils.NewBadRequestError("created_after should be before created_before").WithCode(error_utils.CodeInvalidFilter)
+	}
+	if value, ok := c.GetQuery("id_in"); ok {
+		parts := strings.Split(value, ",")
+		if len(parts) > maxFilterIds {
+			return filter, error_utils.NewBadRequestError("id_in accepts at most " + strconv.Itoa(maxFilterIds) + " ids").WithCode(error_utils.CodeInvalidFilter)
+		}
+		filter.Ids = make([]int64, 0, len(parts))
+		for _, part := range parts {
+			id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if err != nil {
+				return filter, error_utils.NewBadRequestError("id_in should be a comma-separated list of message ids").WithCode(error_utils.CodeInvalidFilter)
+			}
+			filter.Ids = append(filter.Ids, id)
+		}
+	}
+	return filter, nil
+}
+
+func getTextParam(c *gin.Context, name string) (string, error_utils.MessageErr) {
+	value, ok := c.GetQuery(name)
+	if ok && strings.TrimSpace(value) == "" {
+		return "", error_utils.NewBadRequestError(name + " should not be empty")
+	}
+	return value, nil
+}
+
+func getTimeParam(c *gin.Context, name string) (*time.Time, error_utils.MessageErr) {
+	value, ok := c.GetQuery(name)
+	if !ok {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, error_utils.NewBadRequestError(name + " should be an RFC 3339 timestamp")
+	}
+	return &parsed, nil
+}
+
 func GetMessage(c *gin.Context) {
+	encoder, err := negotiate(c)
+	if err != nil {
+		middlewares.WriteError(c, err)
+		return
+	}
 	msgId, err := getMessageId(c.Param("message_id"))
 	if err != nil {
-		c.JSON(err.Status(), err)
+		middlewares.WriteError(c, err)
 		return
 	}
-	message, getErr := services.MessagesService.GetMessage(msgId)
+	includeDeleted, err := getIncludeDeleted(c)
+	if err != nil {
+		middlewares.WriteError(c, err)
+		return
+	}
+	message, getErr := services.MessagesService.GetMessage(middlewares.TenantFrom(c), msgId, includeDeleted)
 	if getErr != nil {
-		c.JSON(getErr.Status(), getErr)
+		middlewares.WriteError(c, getErr)
 		return
 	}
-	c.JSON(http.StatusOK, message)
+	render(c, http.StatusOK, encoder, message)
 }
 
+// GetAllMessages lists messages in the negotiated format. Formats that can be
+// streamed are written message by message as they are read from Redis.
 func GetAllMessages(c *gin.Context) {
-	messages, getErr := services.MessagesService.GetAllMessages()
+	encoder, err := negotiate(c)
+	if err != nil {
+		middlewares.WriteError(c, err)
+		return
+	}
+	filter, err := getMessageFilter(c)
+	if err != nil {
+		middlewares.WriteError(c, err)
+		return
+	}
+	includeDeleted, err := getIncludeDeleted(c)
+	if err != nil {
+		middlewares.WriteError(c, err)
+		return
+	}
+	tenant := middlewares.TenantFrom(c)
+	if streamEncoder, ok := encoder.(encoders.StreamEncoder); ok {
+		out := &streamer{c: c, encoder: streamEncoder}
+		out.Finish(services.MessagesService.EachMessage(tenant, filter, includeDeleted, func(message *domain.Message) error {
+			return out.Write(message)
+		}))
+		return
+	}
+	messages, getErr := services.MessagesService.GetAllMessages(tenant, filter, includeDeleted)
 	if getErr != nil {
-		c.JSON(getErr.Status(), getErr)
+		middlewares.WriteError(c, getErr)
 		return
 	}
-	c.JSON(http.StatusOK, messages)
+	render(c, http.StatusOK, encoder, messages)
 }
@@ -4,29 +4,63 @@ import (
 	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"testing-project/domain"
+	"testing-project/middlewares"
 	"testing-project/services"
 	"testing-project/utils/error_utils"
+	"time"
 )
 
 var (
-	getMessageService    func(msgId int64) (*domain.Message, error_utils.MessageErr)
-	getAllMessageService func() ([]domain.Message, error_utils.MessageErr)
+	requestedTenant         string
+	requestedIncludeDeleted bool
+	requestedFilter         domain.MessageFilter
+	getMessageService       func(msgId int64) (*domain.Message, error_utils.MessageErr)
+	getAllMessageService    func() ([]domain.Message, error_utils.MessageErr)
+	getMessagesService      func(msgIds []int64) ([]domain.Message, error_utils.MessageErr)
 )
 
 type serviceMock struct{}
 
-func (sm *serviceMock) GetMessage(msgId int64) (*domain.Message, error_utils.MessageErr) {
+func (sm *serviceMock) GetMessage(tenant string, msgId int64, includeDeleted bool) (*domain.Message, error_utils.MessageErr) {
+	requestedTenant = tenant
+	requestedIncludeDeleted = includeDeleted
 	return getMessageService(msgId)
 }
 
-func (sm *serviceMock) GetAllMessages() ([]domain.Message, error_utils.MessageErr) {
+func (sm *serviceMock) GetAllMessages(tenant string, filter domain.MessageFilter, includeDeleted bool) ([]domain.Message, error_utils.MessageErr) {
+	requestedTenant = tenant
+	requestedFilter = filter
+	requestedIncludeDeleted = includeDeleted
 	return getAllMessageService()
 }
 
+func (sm *serviceMock) GetMessages(tenant string, msgIds []int64) ([]domain.Message, error_utils.MessageErr) {
+	requestedTenant = tenant
+	return getMessagesService(msgIds)
+}
+
+func (sm *serviceMock) EachMessageByCreated(tenant string, filter domain.MessageFilter, includeDeleted bool, fn func(*domain.Message) error) error_utils.MessageErr {
+	return error_utils.NewInternalServerError("EachMessageByCreated should not be called")
+}
+func (sm *serviceMock) EachMessage(tenant string, filter domain.MessageFilter, includeDeleted bool, fn func(*domain.Message) error) error_utils.MessageErr {
+	messages, err := sm.GetAllMessages(tenant, filter, includeDeleted)
+	if err != nil {
+		return err
+	}
+	for i := range messages {
+		if err := fn(&messages[i]); err != nil {
+			return error_utils.NewInternalServerError(err.Error())
+		}
+	}
+	return nil
+}
+
 // "GetMessage" test cases
 
 func TestGetMessage_Success(t *testing.T) {
@@ -71,6 +105,23 @@ func TestGetMessage_Invalid_Id(t *testing.T) {
 	assert.EqualValues(t, "bad_request", apiErr.Error())
 }
 
+func TestGetMessage_Invalid_Id_Problem(t *testing.T) {
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/abc", nil)
+	req.Header.Set("Accept", "application/json, application/problem+json")
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id", GetMessage)
+	r.ServeHTTP(rr, req)
+
+	var problem error_utils.Problem
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+	assert.EqualValues(t, http.StatusBadRequest, rr.Code)
+	assert.EqualValues(t, error_utils.ProblemContentType, rr.Header().Get("Content-Type"))
+	assert.EqualValues(t, error_utils.CodeInvalidMessageId, problem.Code)
+	assert.EqualValues(t, "message id should be a number", problem.Detail)
+	assert.EqualValues(t, "/messages/abc", problem.Instance)
+}
+
 func TestGetMessage_Message_Not_Found(t *testing.T) {
 	services.MessagesService = &serviceMock{}
 	getMessageService = func(msgId int64) (*domain.Message, error_utils.MessageErr) {
@@ -111,6 +162,111 @@ func TestGetMessage_Message_Database_Error(t *testing.T) {
 	assert.EqualValues(t, "server_error", apiErr.Error())
 }
 
+func TestGetMessage_Tenant_Scoped(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	getMessageService = func(msgId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: msgId}, nil
+	}
+	assert.Nil(t, middlewares.InitializeAuth(middlewares.HashApiKey("key")+"="+middlewares.ScopeMessagesRead+" "+middlewares.ScopeAnyTenant, "", ""))
+	t.Cleanup(func() { middlewares.Authenticators = nil })
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1", nil)
+	req.Header.Set("X-API-Key", "key")
+	req.Header.Set(middlewares.TenantHeader, "team-a")
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id", middlewares.RequireScopes(middlewares.ScopeMessagesRead), middlewares.ResolveTenant(), GetMessage)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "team-a", requestedTenant)
+}
+
+func TestGetMessage_Tenant_Not_Allowed_Anonymously(t *testing.T) {
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1", nil)
+	req.Header.Set(middlewares.TenantHeader, "team-a")
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id", middlewares.ResolveTenant(), GetMessage)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusForbidden, apiErr.Status())
+	assert.EqualValues(t, "tenant not allowed for these credentials", apiErr.Message())
+}
+
+func TestGetMessage_Invalid_Tenant(t *testing.T) {
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1", nil)
+	req.Header.Set(middlewares.TenantHeader, "team:a")
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id", middlewares.ResolveTenant(), GetMessage)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+	assert.EqualValues(t, "invalid tenant id", apiErr.Message())
+}
+
+func TestGetMessage_Include_Deleted(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	getMessageService = func(msgId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: msgId}, nil
+	}
+	authenticator, _ := middlewares.NewApiKeyAuthenticator(middlewares.HashApiKey("admin") + "=" + middlewares.ScopeMessagesRead + " " + middlewares.ScopeAdmin)
+	middlewares.Authenticators = []middlewares.Authenticator{authenticator}
+	defer func() { middlewares.Authenticators = nil }()
+
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1?include_deleted=true", nil)
+	req.Header.Set("X-API-Key", "admin")
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id", middlewares.RequireScopes(middlewares.ScopeMessagesRead), GetMessage)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.True(t, requestedIncludeDeleted)
+}
+
+func TestGetMessage_Include_Deleted_Without_Auth(t *testing.T) {
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1?include_deleted=true", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id", GetMessage)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusForbidden, rr.Code)
+}
+
+func TestGetMessage_Include_Deleted_Requires_Admin(t *testing.T) {
+	authenticator, _ := middlewares.NewApiKeyAuthenticator(middlewares.HashApiKey("reader") + "=" + middlewares.ScopeMessagesRead)
+	middlewares.Authenticators = []middlewares.Authenticator{authenticator}
+	defer func() { middlewares.Authenticators = nil }()
+
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1?include_deleted=true", nil)
+	req.Header.Set("X-API-Key", "reader")
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id", middlewares.RequireScopes(middlewares.ScopeMessagesRead), GetMessage)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusForbidden, rr.Code)
+}
+
+func TestGetMessage_Include_Deleted_Invalid(t *testing.T) {
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1?include_deleted=maybe", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id", GetMessage)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+	assert.EqualValues(t, "include_deleted should be a boolean", apiErr.Message())
+}
+
 // "GetAllMessages" test cases
 
 func TestGetAllMessages_Success(t *testing.T) {
@@ -174,3 +330,158 @@ func TestGetAllMessages_Failure(t *testing.T) {
 	assert.EqualValues(t, "error getting messages", apiErr.Message())
 	assert.EqualValues(t, "server_error", apiErr.Error())
 }
+
+func TestGetAllMessages_Filters(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	getAllMessageService = func() ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{}, nil
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages?title_prefix=Rel&title_contains=note&created_after=2024-01-01T00:00:00Z&created_before=2024-02-01T00:00:00Z&id_in=1,2,3", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages", GetAllMessages)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "Rel", requestedFilter.TitlePrefix)
+	assert.EqualValues(t, "note", requestedFilter.TitleContains)
+	assert.EqualValues(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), requestedFilter.CreatedAfter.UTC())
+	assert.EqualValues(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), requestedFilter.CreatedBefore.UTC())
+	assert.EqualValues(t, []int64{1, 2, 3}, requestedFilter.Ids)
+}
+
+func TestGetAllMessages_Invalid_Filters(t *testing.T) {
+	cases := map[string]string{
+		"title_prefix=":           "title_prefix should not be empty",
+		"created_after=yesterday": "created_after should be an RFC 3339 timestamp",
+		"id_in=1,x":               "id_in should be a comma-separated list of message ids",
+		"created_after=2024-02-01T00:00:00Z&created_before=2024-01-01T00:00:00Z": "created_after should be before created_before",
+	}
+	for query, message := range cases {
+		r := gin.Default()
+		req, _ := http.NewRequest(http.MethodGet, "/messages?"+query, nil)
+		rr := httptest.NewRecorder()
+		r.GET("/messages", GetAllMessages)
+		r.ServeHTTP(rr, req)
+
+		apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+		assert.Nil(t, err)
+		assert.EqualValues(t, http.StatusBadRequest, apiErr.Status(), query)
+		assert.EqualValues(t, message, apiErr.Message(), query)
+	}
+}
+
+func TestGetAllMessages_Negotiated_Formats(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	getAllMessageService = func() ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{
+			{Id: 1, Title: "first", Body: "one", CreatedAt: created},
+			{Id: 2, Title: "second, again", Body: "two", CreatedAt: created},
+		}, nil
+	}
+	cases := map[string]string{
+		"application/x-ndjson": "{\"id\":1,\"title\":\"first\",\"body\":\"one\",\"created_at\":\"2024-01-01T00:00:00Z\"}\n" +
+			"{\"id\":2,\"title\":\"second, again\",\"body\":\"two\",\"created_at\":\"2024-01-01T00:00:00Z\"}\n",
+		"text/csv": "id,title,body,created_at,deleted_at\n" +
+			"1,first,one,2024-01-01T00:00:00Z,\n" +
+			"2,\"second, again\",two,2024-01-01T00:00:00Z,\n",
+	}
+	for accept, body := range cases {
+		r := gin.Default()
+		req, _ := http.NewRequest(http.MethodGet, "/messages", nil)
+		req.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		r.GET("/messages", GetAllMessages)
+		r.ServeHTTP(rr, req)
+
+		assert.EqualValues(t, http.StatusOK, rr.Code, accept)
+		assert.Contains(t, rr.Header().Get("Content-Type"), accept)
+		assert.EqualValues(t, body, rr.Body.String(), accept)
+	}
+}
+
+func TestGetAllMessages_Streamed_Failure(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	getAllMessageService = func() ([]domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewNotFoundError("no messages found")
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	rr := httptest.NewRecorder()
+	r.GET("/messages", GetAllMessages)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Header().Get("Content-Type"), "application/json")
+	assert.EqualValues(t, "no messages found", apiErr.Message())
+}
+
+// midwayFailureMock streams one message and then fails.
+type midwayFailureMock struct {
+	serviceMock
+}
+
+func (sm *midwayFailureMock) EachMessage(tenant string, filter domain.MessageFilter, includeDeleted bool, fn func(*domain.Message) error) error_utils.MessageErr {
+	if err := fn(&domain.Message{Id: 1, Title: "first"}); err != nil {
+		return error_utils.NewInternalServerError(err.Error())
+	}
+	return error_utils.NewServiceUnavailableError("backend unavailable")
+}
+
+func TestGetAllMessages_Streamed_Failure_Midway(t *testing.T) {
+	services.MessagesService = &midwayFailureMock{}
+	r := gin.New()
+	r.Use(middlewares.Recovery())
+	r.GET("/messages", GetAllMessages)
+	server := httptest.NewServer(r)
+	defer server.Close()
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/messages", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+
+	assert.EqualValues(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), `"title":"first"`)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestGetMessage_MessagePack(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	getMessageService = func(msgId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: 1, Title: "the title"}, nil
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1", nil)
+	req.Header.Set("Accept", "application/msgpack")
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id", GetMessage)
+	r.ServeHTTP(rr, req)
+
+	var message map[string]interface{}
+	var handle codec.MsgpackHandle
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "application/msgpack", rr.Header().Get("Content-Type"))
+	assert.Nil(t, codec.NewDecoderBytes(rr.Body.Bytes(), &handle).Decode(&message))
+	assert.EqualValues(t, "the title", message["title"])
+}
+
+func TestGetMessage_Not_Acceptable(t *testing.T) {
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1", nil)
+	req.Header.Set("Accept", "text/html")
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id", GetMessage)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusNotAcceptable, rr.Code)
+	assert.EqualValues(t, "not_acceptable", apiErr.Error())
+}
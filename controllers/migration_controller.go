@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing-project/middlewares"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+func GetMigration(c *gin.Context) {
+	c.JSON(http.StatusOK, services.MigrationService.GetStatus())
+}
+
+// SetMigrationReadFrom switches the store every replica reads messages from,
+// with a body of {"source": "primary" | "secondary"}.
+func SetMigrationReadFrom(c *gin.Context) {
+	var body struct {
+		Source string `json:"source"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		theErr := error_utils.NewBadRequestError("invalid json body")
+		middlewares.WriteError(c, theErr)
+		return
+	}
+	status, setErr := services.MigrationService.SetReadFrom(body.Source)
+	if setErr != nil {
+		middlewares.WriteError(c, setErr)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func StartMigrationBackfill(c *gin.Context) {
+	status, startErr := services.MigrationService.StartBackfill()
+	if startErr != nil {
+		middlewares.WriteError(c, startErr)
+		return
+	}
+	c.JSON(http.StatusAccepted, status)
+}
+
+func VerifyMigration(c *gin.Context) {
+	report, verifyErr := services.MigrationService.Verify()
+	if verifyErr != nil {
+		middlewares.WriteError(c, verifyErr)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+var (
+	requestedReadFrom string
+	setReadFromErr    error_utils.MessageErr
+	startBackfillErr  error_utils.MessageErr
+)
+
+type migrationServiceMock struct{}
+
+func (mm *migrationServiceMock) GetStatus() domain.MigrationStatus {
+	return domain.MigrationStatus{Enabled: true, ReadFrom: domain.MigrationReadPrimary}
+}
+func (mm *migrationServiceMock) SetReadFrom(source string) (domain.MigrationStatus, error_utils.MessageErr) {
+	requestedReadFrom = source
+	return domain.MigrationStatus{Enabled: true, ReadFrom: source}, setReadFromErr
+}
+func (mm *migrationServiceMock) StartBackfill() (domain.MigrationStatus, error_utils.MessageErr) {
+	return domain.MigrationStatus{Enabled: true, Backfill: domain.BackfillProgress{Running: true}}, startBackfillErr
+}
+func (mm *migrationServiceMock) Verify() (*domain.MigrationReport, error_utils.MessageErr) {
+	return &domain.MigrationReport{InSync: true}, nil
+}
+
+func TestSetMigrationReadFrom_Success(t *testing.T) {
+	services.MigrationService = &migrationServiceMock{}
+	setReadFromErr = nil
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPut, "/admin/migration/read-from", strings.NewReader(`{"source":"secondary"}`))
+	rr := httptest.NewRecorder()
+	r.PUT("/admin/migration/read-from", SetMigrationReadFrom)
+	r.ServeHTTP(rr, req)
+
+	var status domain.MigrationStatus
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &status))
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "secondary", requestedReadFrom)
+	assert.EqualValues(t, "secondary", status.ReadFrom)
+}
+
+func TestSetMigrationReadFrom_Disabled(t *testing.T) {
+	services.MigrationService = &migrationServiceMock{}
+	setReadFromErr = error_utils.NewNotFoundError("migration mode is not enabled").WithCode(error_utils.CodeMigrationDisabled)
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPut, "/admin/migration/read-from", strings.NewReader(`{"source":"secondary"}`))
+	rr := httptest.NewRecorder()
+	r.PUT("/admin/migration/read-from", SetMigrationReadFrom)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusNotFound, rr.Code)
+	assert.EqualValues(t, "migration mode is not enabled", apiErr.Message())
+}
+
+func TestStartMigrationBackfill_Running(t *testing.T) {
+	services.MigrationService = &migrationServiceMock{}
+	startBackfillErr = error_utils.NewConflictError("a backfill is already running")
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPost, "/admin/migration/backfill", nil)
+	rr := httptest.NewRecorder()
+	r.POST("/admin/migration/backfill", StartMigrationBackfill)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusConflict, rr.Code)
+}
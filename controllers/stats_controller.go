@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing-project/domain"
+	"testing-project/middlewares"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+// maxStatsBuckets bounds the range of one stats request.
+const maxStatsBuckets = 744
+
+// GetMessageStats answers with the totals and the per-day (default) or
+// per-hour counts from ?from= to ?to= (RFC 3339). The range defaults to the
+// last 30 days, or the last 24 hours for hourly buckets.
+func GetMessageStats(c *gin.Context) {
+	bucket := c.DefaultQuery("bucket", domain.StatsBucketDay)
+	step, defaultBuckets := 24*time.Hour, 30
+	if bucket == domain.StatsBucketHour {
+		step, defaultBuckets = time.Hour, 24
+	} else if bucket != domain.StatsBucketDay {
+		theErr := error_utils.NewBadRequestError("bucket should be day or hour").WithCode(error_utils.CodeInvalidStatsQuery)
+		middlewares.WriteError(c, theErr)
+		return
+	}
+
+	to, err := getTimeParam(c, "to")
+	if err != nil {
+		middlewares.WriteError(c, err.WithCode(error_utils.CodeInvalidStatsQuery))
+		return
+	}
+	if to == nil {
+		now := time.Now().UTC()
+		to = &now
+	}
+	from, err := getTimeParam(c, "from")
+	if err != nil {
+		middlewares.WriteError(c, err.WithCode(error_utils.CodeInvalidStatsQuery))
+		return
+	}
+	if from == nil {
+		defaultFrom := to.Add(-time.Duration(defaultBuckets-1) * step)
+		from = &defaultFrom
+	}
+	if from.After(*to) {
+		theErr := error_utils.NewBadRequestError("from should not be after to").WithCode(error_utils.CodeInvalidStatsQuery)
+		middlewares.WriteError(c, theErr)
+		return
+	}
+	if to.Sub(*from) >= maxStatsBuckets*step {
+		theErr := error_utils.NewBadRequestError("range is too large for the requested bucket").WithCode(error_utils.CodeInvalidStatsQuery)
+		middlewares.WriteError(c, theErr)
+		return
+	}
+
+	stats, getErr := services.StatsService.GetStats(middlewares.TenantFrom(c), bucket, *from, *to)
+	if getErr != nil {
+		middlewares.WriteError(c, getErr)
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+var (
+	requestedStatsFrom time.Time
+	requestedStatsTo   time.Time
+)
+
+type statsServiceMock struct{}
+
+func (sm *statsServiceMock) GetStats(tenant, bucket string, from, to time.Time) (*domain.MessageStats, error_utils.MessageErr) {
+	requestedStatsFrom, requestedStatsTo = from, to
+	return &domain.MessageStats{Bucket: bucket}, nil
+}
+
+func TestGetMessageStats_Success(t *testing.T) {
+	services.StatsService = &statsServiceMock{}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/stats?bucket=hour&from=2024-03-10T00:00:00Z&to=2024-03-11T00:00:00Z", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/stats", GetMessageStats)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), requestedStatsFrom.UTC())
+	assert.EqualValues(t, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC), requestedStatsTo.UTC())
+}
+
+func TestGetMessageStats_Invalid_Params(t *testing.T) {
+	cases := map[string]string{
+		"bucket=week": "bucket should be day or hour",
+		"from=2024-03-11T00:00:00Z&to=2024-03-10T00:00:00Z":             "from should not be after to",
+		"bucket=hour&from=2023-01-01T00:00:00Z&to=2024-01-01T00:00:00Z": "range is too large for the requested bucket",
+		"to=tomorrow": "to should be an RFC 3339 timestamp",
+	}
+	for query, message := range cases {
+		r := gin.Default()
+		req, _ := http.NewRequest(http.MethodGet, "/messages/stats?"+query, nil)
+		rr := httptest.NewRecorder()
+		r.GET("/messages/stats", GetMessageStats)
+		r.ServeHTTP(rr, req)
+
+		apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+		assert.Nil(t, err)
+		assert.EqualValues(t, http.StatusBadRequest, apiErr.Status(), query)
+		assert.EqualValues(t, message, apiErr.Message(), query)
+	}
+}
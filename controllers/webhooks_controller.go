@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing-project/domain"
+	"testing-project/middlewares"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+func GetWebhooks(c *gin.Context) {
+	webhooks, getErr := services.WebhooksService.GetWebhooks()
+	if getErr != nil {
+		middlewares.WriteError(c, getErr)
+		return
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+func CreateWebhook(c *gin.Context) {
+	var webhook domain.Webhook
+	if err := c.ShouldBindJSON(&webhook); err != nil {
+		theErr := error_utils.NewBadRequestError("invalid json body")
+		middlewares.WriteError(c, theErr)
+		return
+	}
+	created, createErr := services.WebhooksService.CreateWebhook(&webhook)
+	if createErr != nil {
+		middlewares.WriteError(c, createErr)
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func DeleteWebhook(c *gin.Context) {
+	if err := services.WebhooksService.DeleteWebhook(c.Param("webhook_id")); err != nil {
+		middlewares.WriteError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func GetWebhookDeliveries(c *gin.Context) {
+	deliveries, getErr := services.WebhooksService.GetDeliveries(c.Param("webhook_id"))
+	if getErr != nil {
+		middlewares.WriteError(c, getErr)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+var (
+	createWebhookService func(*domain.Webhook) (*domain.Webhook, error_utils.MessageErr)
+	deleteWebhookService func(string) error_utils.MessageErr
+)
+
+type webhooksServiceMock struct{}
+
+func (wm *webhooksServiceMock) GetWebhooks() ([]domain.Webhook, error_utils.MessageErr) {
+	return nil, nil
+}
+func (wm *webhooksServiceMock) CreateWebhook(webhook *domain.Webhook) (*domain.Webhook, error_utils.MessageErr) {
+	return createWebhookService(webhook)
+}
+func (wm *webhooksServiceMock) DeleteWebhook(id string) error_utils.MessageErr {
+	return deleteWebhookService(id)
+}
+func (wm *webhooksServiceMock) GetDeliveries(string) ([]domain.WebhookDelivery, error_utils.MessageErr) {
+	return nil, nil
+}
+func (wm *webhooksServiceMock) Dispatch(domain.MessageChange) {}
+func (wm *webhooksServiceMock) RetryDue() int {
+	return 0
+}
+func (wm *webhooksServiceMock) Wait()           {}
+func (wm *webhooksServiceMock) Initialize(bool) {}
+
+// "CreateWebhook" test cases
+
+func TestCreateWebhook_Success(t *testing.T) {
+	services.WebhooksService = &webhooksServiceMock{}
+	createWebhookService = func(webhook *domain.Webhook) (*domain.Webhook, error_utils.MessageErr) {
+		webhook.Id = "abc"
+		return webhook, nil
+	}
+	r := gin.Default()
+	body := `{"url":"http://example.com/hook","events":["created"]}`
+	req, _ := http.NewRequest(http.MethodPost, "/admin/webhooks", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	r.POST("/admin/webhooks", CreateWebhook)
+	r.ServeHTTP(rr, req)
+
+	var webhook domain.Webhook
+	err := json.Unmarshal(rr.Body.Bytes(), &webhook)
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusCreated, rr.Code)
+	assert.EqualValues(t, "abc", webhook.Id)
+	assert.EqualValues(t, []string{"created"}, webhook.Events)
+}
+
+func TestCreateWebhook_Invalid_Json(t *testing.T) {
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPost, "/admin/webhooks", strings.NewReader("{"))
+	rr := httptest.NewRecorder()
+	r.POST("/admin/webhooks", CreateWebhook)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+	assert.EqualValues(t, "invalid json body", apiErr.Message())
+}
+
+// "DeleteWebhook" test cases
+
+func TestDeleteWebhook_Not_Found(t *testing.T) {
+	services.WebhooksService = &webhooksServiceMock{}
+	deleteWebhookService = func(id string) error_utils.MessageErr {
+		return error_utils.NewNotFoundError("webhook not found")
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodDelete, "/admin/webhooks/abc", nil)
+	rr := httptest.NewRecorder()
+	r.DELETE("/admin/webhooks/:webhook_id", DeleteWebhook)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusNotFound, apiErr.Status())
+	assert.EqualValues(t, "webhook not found", apiErr.Message())
+}
@@ -0,0 +1,19 @@
+package domain
+
+// CacheInvalidation tells every replica to drop its cached copy of a message.
+type CacheInvalidation struct {
+	Tenant    string `json:"tenant"`
+	MessageId int64  `json:"message_id"`
+}
+
+// CacheStats describes the in-process message cache of one replica.
+type CacheStats struct {
+	Enabled       bool    `json:"enabled"`
+	Capacity      int     `json:"capacity"`
+	Size          int     `json:"size"`
+	Hits          uint64  `json:"hits"`
+	Misses        uint64  `json:"misses"`
+	HitRatio      float64 `json:"hit_ratio"`
+	Evictions     uint64  `json:"evictions"`
+	Invalidations uint64  `json:"invalidations"`
+}
@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"log"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+)
+
+const (
+	cacheInvalidationChannelKey = "messages:cache:invalidate"
+)
+
+var (
+	CacheInvalidations cacheInvalidationsInterface = &cacheInvalidations{}
+)
+
+type cacheInvalidationsInterface interface {
+	Publish(CacheInvalidation) error_utils.MessageErr
+	Subscribe() (<-chan CacheInvalidation, func())
+	Initialize(*redis.Client)
+}
+
+// cacheInvalidations broadcasts invalidations over Redis Pub/Sub. Delivery
+// is best effort, which is why cached entries also expire on their own.
+type cacheInvalidations struct {
+	client *redis.Client
+}
+
+func (ci *cacheInvalidations) Initialize(client *redis.Client) {
+	ci.client = client
+}
+
+func NewCacheInvalidations(client *redis.Client) cacheInvalidationsInterface {
+	return &cacheInvalidations{client: client}
+}
+
+func (ci *cacheInvalidations) Publish(invalidation CacheInvalidation) error_utils.MessageErr {
+	data, err := json.Marshal(invalidation)
+	if err != nil {
+		return error_formats.Translate(err, "json marshal")
+	}
+	if err := ci.client.Publish(ctx, cacheInvalidationChannelKey, data).Err(); err != nil {
+		return error_formats.Translate(err, "redis publish")
+	}
+	return nil
+}
+
+// Subscribe listens for invalidations published by any replica. The
+// returned function closes the subscription and the channel.
+func (ci *cacheInvalidations) Subscribe() (<-chan CacheInvalidation, func()) {
+	pubsub := ci.client.Subscribe(ctx, cacheInvalidationChannelKey)
+	out := make(chan CacheInvalidation)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(out)
+		for msg := range pubsub.Channel() {
+			var invalidation CacheInvalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &invalidation); err != nil {
+				log.Printf("Failed to unmarshal cache invalidation: %s", err)
+				continue
+			}
+			select {
+			case out <- invalidation:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return out, func() {
+		close(done)
+		pubsub.Close()
+	}
+}
@@ -0,0 +1,154 @@
+package domain
+
+import (
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	"log"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+const (
+	changesStreamKey  = "messages:changes"
+	changesChannelKey = "messages:changes:live"
+	// changesTrimmedKey holds the id of the newest change trimmed from the
+	// stream.
+	changesTrimmedKey = "messages:changes:trimmed"
+)
+
+// appendChangeScript adds change ARGV[1] to the stream KEYS[1], trims the
+// stream to its newest ARGV[2] entries (0 = no limit) and records the id of
+// the newest entry trimmed in KEYS[2]. It returns the id of the new entry.
+var appendChangeScript = redis.NewScript(`
+local id = redis.call('XADD', KEYS[1], '*', 'change', ARGV[1])
+local maxLen = tonumber(ARGV[2])
+if maxLen > 0 then
+  local excess = redis.call('XLEN', KEYS[1]) - maxLen
+  if excess > 0 then
+    local trimmed = redis.call('XRANGE', KEYS[1], '-', '+', 'COUNT', excess)
+    redis.call('SET', KEYS[2], trimmed[#trimmed][1])
+    redis.call('XTRIM', KEYS[1], 'MAXLEN', maxLen)
+  end
+end
+return id
+`)
+
+var (
+	ChangeFeed changeFeedInterface = &changeFeed{maxLen: 1000}
+)
+
+type changeFeedInterface interface {
+	Publish(*MessageChange) error_utils.MessageErr
+	Since(string) ([]MessageChange, error_utils.MessageErr)
+	Subscribe() (<-chan MessageChange, func())
+	Initialize(*redis.Client, int64)
+}
+
+// changeFeed keeps a bounded Redis Stream of recent changes for resumption
+// and fans out live notifications to all replicas through Redis Pub/Sub.
+type changeFeed struct {
+	client *redis.Client
+	maxLen int64
+}
+
+func (cf *changeFeed) Initialize(client *redis.Client, maxLen int64) {
+	cf.client = client
+	if maxLen > 0 {
+		cf.maxLen = maxLen
+	}
+}
+
+func NewChangeFeed(client *redis.Client, maxLen int64) changeFeedInterface {
+	return &changeFeed{client: client, maxLen: maxLen}
+}
+
+func (cf *changeFeed) Publish(change *MessageChange) error_utils.MessageErr {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return error_formats.Translate(err, "json marshal")
+	}
+	id, err := appendChangeScript.Run(ctx, cf.client, []string{changesStreamKey, changesTrimmedKey}, data, cf.maxLen).Text()
+	if err != nil {
+		return error_formats.Translate(err, "redis stream add")
+	}
+
+	change.Id = id
+	data, err = json.Marshal(change)
+	if err != nil {
+		return error_formats.Translate(err, "json marshal")
+	}
+	if err := cf.client.Publish(ctx, changesChannelKey, data).Err(); err != nil {
+		return error_formats.Translate(err, "redis publish")
+	}
+	return nil
+}
+
+// Since returns the retained changes that come strictly after lastId. When
+// changes after lastId were already trimmed from the stream, it returns only
+// a reset change instead, carrying the id of the newest change, as the client
+// cannot catch up by replaying and has to list the messages again.
+func (cf *changeFeed) Since(lastId string) ([]MessageChange, error_utils.MessageErr) {
+	if err := ValidateChangeId(lastId); err != nil {
+		return nil, err
+	}
+	entries, err := cf.client.XRange(ctx, changesStreamKey, "("+lastId, "+").Result()
+	if err != nil {
+		return nil, error_formats.Translate(err, "redis stream range")
+	}
+	// Checked after reading, as the script trims and records atomically:
+	// nothing was missed unless a change after lastId had been trimmed.
+	if len(entries) > 0 {
+		trimmed, err := cf.client.Get(ctx, changesTrimmedKey).Result()
+		if err != nil && err != redis.Nil {
+			return nil, error_formats.Translate(err, "redis stream range")
+		}
+		if trimmed != "" && CompareChangeIds(trimmed, lastId) > 0 {
+			return []MessageChange{{Id: entries[len(entries)-1].ID, Event: EventReset, AppliedAt: time.Now().UTC()}}, nil
+		}
+	}
+
+	changes := make([]MessageChange, 0, len(entries))
+	for _, entry := range entries {
+		raw, ok := entry.Values["change"].(string)
+		if !ok {
+			continue
+		}
+		var change MessageChange
+		if err := json.Unmarshal([]byte(raw), &change); err != nil {
+			continue
+		}
+		change.Id = entry.ID
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// Subscribe listens for live changes published by any replica. The returned
+// function closes the subscription and the channel.
+func (cf *changeFeed) Subscribe() (<-chan MessageChange, func()) {
+	pubsub := cf.client.Subscribe(ctx, changesChannelKey)
+	out := make(chan MessageChange)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(out)
+		for msg := range pubsub.Channel() {
+			var change MessageChange
+			if err := json.Unmarshal([]byte(msg.Payload), &change); err != nil {
+				log.Printf("Failed to unmarshal change: %s", err)
+				continue
+			}
+			select {
+			case out <- change:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return out, func() {
+		close(done)
+		pubsub.Close()
+	}
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"strconv"
+	"strings"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+const (
+	EventCreated  = "created"
+	EventUpdated  = "updated"
+	EventDeleted  = "deleted"
+	EventRestored = "restored"
+
+	// EventReset tells a stream client that changes it missed are no longer
+	// retained, so it must list the messages again instead of replaying.
+	EventReset = "reset"
+)
+
+// MessageChange describes a single change applied to the read model.
+// Id is the Redis Stream entry id and is used as the SSE event id.
+type MessageChange struct {
+	Id        string    `json:"id"`
+	Event     string    `json:"event"`
+	Tenant    string    `json:"tenant"`
+	MessageId int64     `json:"message_id"`
+	Data      *Message  `json:"data,omitempty"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// ValidateChangeId rejects a resumption id that is not a stream entry id.
+func ValidateChangeId(id string) error_utils.MessageErr {
+	ms, seq, ok := strings.Cut(id, "-")
+	if ok {
+		_, msErr := strconv.ParseUint(ms, 10, 64)
+		_, seqErr := strconv.ParseUint(seq, 10, 64)
+		ok = msErr == nil && seqErr == nil
+	}
+	if !ok {
+		return error_utils.NewBadRequestError("invalid last event id").WithCode(error_utils.CodeInvalidLastEventId).WithDetail(id)
+	}
+	return nil
+}
+
+// CompareChangeIds orders two stream entry ids ("<ms>-<seq>").
+// It returns -1, 0 or 1 like strings.Compare.
+func CompareChangeIds(a, b string) int {
+	aMs, aSeq := splitChangeId(a)
+	bMs, bSeq := splitChangeId(b)
+	switch {
+	case aMs < bMs:
+		return -1
+	case aMs > bMs:
+		return 1
+	case aSeq < bSeq:
+		return -1
+	case aSeq > bSeq:
+		return 1
+	}
+	return 0
+}
+
+func splitChangeId(id string) (uint64, uint64) {
+	parts := strings.SplitN(id, "-", 2)
+	ms, _ := strconv.ParseUint(parts[0], 10, 64)
+	var seq uint64
+	if len(parts) == 2 {
+		seq, _ = strconv.ParseUint(parts[1], 10, 64)
+	}
+	return ms, seq
+}
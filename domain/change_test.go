@@ -0,0 +1,122 @@
+package domain_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/go-redis/redismock/v8"
+	"github.com/stretchr/testify/assert"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+func TestChangeFeedPublish_Success(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	feed := domain.NewChangeFeed(client, 100)
+	live := client.Subscribe(context.Background(), "messages:changes:live")
+	defer live.Close()
+	_, err := live.Receive(context.Background())
+	assert.Nil(t, err)
+
+	change := &domain.MessageChange{
+		Event:     domain.EventCreated,
+		MessageId: 5,
+		Data:      &domain.Message{Id: 5, Title: "Title", Body: "Body"},
+		AppliedAt: time.Now().UTC(),
+	}
+	stored, _ := json.Marshal(change)
+
+	assert.Nil(t, feed.Publish(change))
+
+	entries, _ := server.Stream("messages:changes")
+	assert.Len(t, entries, 1)
+	assert.Equal(t, entries[0].ID, change.Id)
+	assert.Equal(t, []string{"change", string(stored)}, entries[0].Values)
+	published, _ := json.Marshal(change)
+	msg, err := live.ReceiveMessage(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, string(published), msg.Payload)
+}
+
+func TestChangeFeedSince_Success(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	feed := domain.NewChangeFeed(db, 100)
+
+	data, _ := json.Marshal(domain.MessageChange{Event: domain.EventDeleted, MessageId: 3})
+	mock.ExpectXRange("messages:changes", "(1-0", "+").SetVal([]redis.XMessage{
+		{ID: "2-0", Values: map[string]interface{}{"change": string(data)}},
+		{ID: "3-0", Values: map[string]interface{}{"change": "not json"}},
+	})
+	mock.ExpectGet("messages:changes:trimmed").RedisNil()
+
+	changes, err := feed.Since("1-0")
+
+	assert.Nil(t, err)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, "2-0", changes[0].Id)
+	assert.Equal(t, domain.EventDeleted, changes[0].Event)
+	assert.EqualValues(t, 3, changes[0].MessageId)
+}
+
+func TestChangeFeedSince_RedisError(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	feed := domain.NewChangeFeed(db, 100)
+
+	mock.ExpectXRange("messages:changes", "(1-0", "+").SetErr(redis.ErrClosed)
+
+	changes, err := feed.Since("1-0")
+
+	assert.Nil(t, changes)
+	assert.True(t, errors.Is(err, error_utils.ErrUnavailable))
+}
+
+func TestChangeFeedSince_Trimmed_Resets(t *testing.T) {
+	server := miniredis.RunT(t)
+	feed := domain.NewChangeFeed(redis.NewClient(&redis.Options{Addr: server.Addr()}), 2)
+	var ids []string
+	for i := 0; i < 4; i++ {
+		change := &domain.MessageChange{Event: domain.EventCreated, MessageId: int64(i)}
+		assert.Nil(t, feed.Publish(change))
+		ids = append(ids, change.Id)
+	}
+
+	// The first two were trimmed: a client that saw both missed nothing,
+	// even though its id is older than the oldest one left.
+	changes, err := feed.Since(ids[1])
+	assert.Nil(t, err)
+	assert.Len(t, changes, 2)
+	assert.Equal(t, ids[2], changes[0].Id)
+
+	changes, err = feed.Since(ids[0])
+	assert.Nil(t, err)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, domain.EventReset, changes[0].Event)
+	assert.Equal(t, ids[3], changes[0].Id)
+}
+
+func TestChangeFeedSince_Invalid_Id(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	feed := domain.NewChangeFeed(db, 100)
+
+	for _, id := range []string{"abc", "1", "1-", "-1", "1-x"} {
+		changes, err := feed.Since(id)
+
+		assert.Nil(t, changes)
+		assert.EqualValues(t, 400, err.Status())
+		assert.Equal(t, "INVALID_LAST_EVENT_ID", err.Code())
+	}
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestCompareChangeIds(t *testing.T) {
+	assert.Equal(t, -1, domain.CompareChangeIds("1-5", "2-0"))
+	assert.Equal(t, 1, domain.CompareChangeIds("10-0", "9-9"))
+	assert.Equal(t, -1, domain.CompareChangeIds("3-1", "3-2"))
+	assert.Equal(t, 0, domain.CompareChangeIds("3-2", "3-2"))
+}
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"sync"
+	"time"
+)
+
+// circuitBreaker stops calling a failing dependency. After threshold
+// consecutive failures it opens for openFor, then lets a single probe
+// through; the probe's outcome closes or reopens it.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	openFor   time.Duration
+	failures  int
+	openedAt  time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(threshold int, openFor time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, openFor: openFor}
+}
+
+func (b *circuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if time.Since(b.openedAt) < b.openFor || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *circuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *circuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
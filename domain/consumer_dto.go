@@ -0,0 +1,78 @@
+package domain
+
+import "time"
+
+// ConsumerState describes the event consumer of one replica. Lag and
+// DeadLetters are the number of events waiting in the queue and in its
+// dead-letter queue, known only while the consumer is connected.
+type ConsumerState struct {
+	Connected   bool                      `json:"connected"`
+	Paused      bool                      `json:"paused"`
+	Queue       string                    `json:"queue"`
+	Lag         *int                      `json:"lag,omitempty"`
+	DeadLetters *int                      `json:"dead_letters,omitempty"`
+	Reconnects  int64                     `json:"reconnects"`
+	ConnectedAt *time.Time                `json:"connected_at,omitempty"`
+	LastEventAt *time.Time                `json:"last_event_at,omitempty"`
+	LastError   string                    `json:"last_error,omitempty"`
+	Events      map[string]*EventCounters `json:"events"`
+}
+
+// EventCounters count the events of one type the consumer received and
+// what became of them: applied, skipped as duplicates, failed and retried,
+// or dead-lettered.
+type EventCounters struct {
+	Received     int64 `json:"received"`
+	Applied      int64 `json:"applied"`
+	Duplicates   int64 `json:"duplicates"`
+	Failed       int64 `json:"failed"`
+	DeadLettered int64 `json:"dead_lettered"`
+}
+
+// DeadLetter is an event the consumer gave up on, with the reason why.
+type DeadLetter struct {
+	Id             string     `json:"id"`
+	Event          string     `json:"event,omitempty"`
+	Tenant         string     `json:"tenant,omitempty"`
+	Reason         string     `json:"reason"`
+	DeadLetteredAt *time.Time `json:"dead_lettered_at,omitempty"`
+	Body           string     `json:"body"`
+}
+
+// RequeueResult lists the outcome of requeueing dead-lettered events: the
+// ids put back on the queue and those the dead-letter queue no longer had.
+type RequeueResult struct {
+	Requeued []string `json:"requeued"`
+	NotFound []string `json:"not_found"`
+}
+
+// What became of each event of a batch.
+const (
+	BatchItemApplied      = "applied"
+	BatchItemDuplicate    = "duplicate"
+	BatchItemDeadLettered = "dead_lettered"
+	BatchItemDropped      = "dropped"
+)
+
+// BatchReport is the outcome of every event of a batch delivery, in batch
+// order, which the consumer sends to the delivery's reply-to queue once the
+// batch is settled.
+type BatchReport struct {
+	MessageId    string             `json:"message_id,omitempty"`
+	Applied      int                `json:"applied"`
+	Duplicates   int                `json:"duplicates"`
+	DeadLettered int                `json:"dead_lettered"`
+	Dropped      int                `json:"dropped"`
+	Items        []BatchItemOutcome `json:"items"`
+}
+
+// BatchItemOutcome is what became of one event of a batch, with the reason
+// when it failed.
+type BatchItemOutcome struct {
+	Index     int    `json:"index"`
+	Event     string `json:"event,omitempty"`
+	MessageId int64  `json:"message_id,omitempty"`
+	Status    string `json:"status"`
+	Code      string `json:"code,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
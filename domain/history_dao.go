@@ -0,0 +1,168 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+var (
+	HistoryRepo historyRepoInterface = &historyRepo{maxVersions: 50}
+)
+
+type historyRepoInterface interface {
+	Append(string, int64, *MessageVersion) error_utils.MessageErr
+	GetAll(string, int64) ([]MessageVersion, error_utils.MessageErr)
+	Get(string, int64, int64) (*MessageVersion, error_utils.MessageErr)
+	Latest(string, int64) (*MessageVersion, error_utils.MessageErr)
+	Initialize(*redis.Client, int64, time.Duration)
+}
+
+// historyRepo keeps the applied versions of each message in a Redis list,
+// oldest first, capped to maxVersions entries and to entries younger than
+// maxAge (0 = no age limit). Version numbers keep increasing when old
+// entries are trimmed, so the list always holds a contiguous range.
+type historyRepo struct {
+	client      *redis.Client
+	maxVersions int64
+	maxAge      time.Duration
+}
+
+func (hr *historyRepo) Initialize(client *redis.Client, maxVersions int64, maxAge time.Duration) {
+	hr.client = client
+	if maxVersions > 0 {
+		hr.maxVersions = maxVersions
+	}
+	hr.maxAge = maxAge
+}
+
+func NewHistoryRepository(client *redis.Client, maxVersions int64, maxAge time.Duration) historyRepoInterface {
+	return &historyRepo{client: client, maxVersions: maxVersions, maxAge: maxAge}
+}
+
+func historyKey(tenant string, messageId int64) string {
+	return fmt.Sprintf("%smessage_history:%d", tenantPrefix(tenant), messageId)
+}
+
+func historySeqKey(tenant string, messageId int64) string {
+	return fmt.Sprintf("%smessage_history_seq:%d", tenantPrefix(tenant), messageId)
+}
+
+// Append numbers version as the next version of the message and stores it.
+func (hr *historyRepo) Append(tenant string, messageId int64, version *MessageVersion) error_utils.MessageErr {
+	key := historyKey(tenant, messageId)
+	seqKey := historySeqKey(tenant, messageId)
+
+	seq, err := hr.client.Incr(ctx, seqKey).Result()
+	if err != nil {
+		return error_formats.Translate(err, "redis history")
+	}
+	version.Version = seq
+	data, err := json.Marshal(version)
+	if err != nil {
+		return error_formats.Translate(err, "json marshal")
+	}
+
+	_, err = hr.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, key, data)
+		if hr.maxVersions > 0 {
+			pipe.LTrim(ctx, key, -hr.maxVersions, -1)
+		}
+		if hr.maxAge > 0 {
+			pipe.Expire(ctx, key, hr.maxAge)
+			pipe.Expire(ctx, seqKey, hr.maxAge)
+		}
+		return nil
+	})
+	if err != nil {
+		return error_formats.Translate(err, "redis history")
+	}
+	if hr.maxAge > 0 {
+		return hr.trimOlderThan(key, time.Now().Add(-hr.maxAge))
+	}
+	return nil
+}
+
+func (hr *historyRepo) trimOlderThan(key string, cutoff time.Time) error_utils.MessageErr {
+	for {
+		oldest, err := hr.first(key)
+		if err != nil || oldest == nil || !oldest.AppliedAt.Before(cutoff) {
+			return err
+		}
+		if err := hr.client.LPop(ctx, key).Err(); err != nil && err != redis.Nil {
+			return error_formats.Translate(err, "redis history")
+		}
+	}
+}
+
+func (hr *historyRepo) first(key string) (*MessageVersion, error_utils.MessageErr) {
+	return hr.at(key, 0)
+}
+
+// Latest returns the last applied version of a message, or nil when it has
+// no history.
+func (hr *historyRepo) Latest(tenant string, messageId int64) (*MessageVersion, error_utils.MessageErr) {
+	return hr.at(historyKey(tenant, messageId), -1)
+}
+
+func (hr *historyRepo) at(key string, index int64) (*MessageVersion, error_utils.MessageErr) {
+	data, err := hr.client.LIndex(ctx, key, index).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, error_formats.Translate(err, "redis history")
+	}
+	var version MessageVersion
+	if err := json.Unmarshal([]byte(data), &version); err != nil {
+		return nil, error_formats.Translate(err, "json unmarshal")
+	}
+	return &version, nil
+}
+
+func (hr *historyRepo) GetAll(tenant string, messageId int64) ([]MessageVersion, error_utils.MessageErr) {
+	entries, err := hr.client.LRange(ctx, historyKey(tenant, messageId), 0, -1).Result()
+	if err != nil {
+		return nil, error_formats.Translate(err, "redis history")
+	}
+
+	versions := make([]MessageVersion, 0, len(entries))
+	for _, entry := range entries {
+		var version MessageVersion
+		if err := json.Unmarshal([]byte(entry), &version); err != nil {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	if len(versions) == 0 {
+		return nil, error_utils.NewNotFoundError("message history not found").WithCode(error_utils.CodeMessageNotFound)
+	}
+	return versions, nil
+}
+
+// Get returns version n of a message, working out its list position from the
+// oldest retained version.
+func (hr *historyRepo) Get(tenant string, messageId int64, n int64) (*MessageVersion, error_utils.MessageErr) {
+	key := historyKey(tenant, messageId)
+	oldest, getErr := hr.first(key)
+	if getErr != nil {
+		return nil, getErr
+	}
+	if oldest == nil || n < oldest.Version {
+		return nil, error_utils.NewNotFoundError("message version not found").WithCode(error_utils.CodeVersionNotFound)
+	}
+
+	data, err := hr.client.LIndex(ctx, key, n-oldest.Version).Result()
+	if err == redis.Nil {
+		return nil, error_utils.NewNotFoundError("message version not found").WithCode(error_utils.CodeVersionNotFound)
+	} else if err != nil {
+		return nil, error_formats.Translate(err, "redis history")
+	}
+	var version MessageVersion
+	if err := json.Unmarshal([]byte(data), &version); err != nil {
+		return nil, error_formats.Translate(err, "json unmarshal")
+	}
+	return &version, nil
+}
@@ -0,0 +1,13 @@
+package domain
+
+import "time"
+
+// MessageVersion is one applied version of a message together with the event
+// that produced it. Data is empty for deletions.
+type MessageVersion struct {
+	Version   int64     `json:"version"`
+	Event     string    `json:"event"`
+	ChangeId  string    `json:"change_id,omitempty"`
+	Data      *Message  `json:"data,omitempty"`
+	AppliedAt time.Time `json:"applied_at"`
+}
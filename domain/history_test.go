@@ -0,0 +1,68 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"testing-project/domain"
+)
+
+func TestHistory_Append_And_Get(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewHistoryRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}), 2, 0)
+
+	for _, title := range []string{"first", "second", "third"} {
+		version := &domain.MessageVersion{Event: domain.EventUpdated, Data: &domain.Message{Id: 7, Title: title}, AppliedAt: time.Now()}
+		assert.Nil(t, repo.Append("team-a", 7, version))
+	}
+
+	versions, err := repo.GetAll("team-a", 7)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(versions))
+	assert.EqualValues(t, 2, versions[0].Version)
+	assert.Equal(t, "third", versions[1].Data.Title)
+
+	version, err := repo.Get("team-a", 7, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "second", version.Data.Title)
+
+	latest, err := repo.Latest("team-a", 7)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 3, latest.Version)
+
+	_, err = repo.Get("team-a", 7, 1)
+	assert.Equal(t, "message version not found", err.Message())
+	_, err = repo.Get("team-a", 7, 4)
+	assert.Equal(t, "message version not found", err.Message())
+	assert.True(t, server.Exists("tenant:team-a:message_history:7"))
+}
+
+func TestHistory_Max_Age(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewHistoryRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}), 0, time.Hour)
+
+	assert.Nil(t, repo.Append(domain.DefaultTenant, 1, &domain.MessageVersion{Event: domain.EventCreated, AppliedAt: time.Now().Add(-2 * time.Hour)}))
+	assert.Nil(t, repo.Append(domain.DefaultTenant, 1, &domain.MessageVersion{Event: domain.EventDeleted, AppliedAt: time.Now()}))
+
+	versions, err := repo.GetAll(domain.DefaultTenant, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(versions))
+	assert.Equal(t, domain.EventDeleted, versions[0].Event)
+	assert.EqualValues(t, 2, versions[0].Version)
+	assert.True(t, server.TTL("message_history:1") > 0)
+}
+
+func TestHistory_Not_Found(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewHistoryRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}), 0, 0)
+
+	_, err := repo.GetAll(domain.DefaultTenant, 1)
+	assert.Equal(t, "message history not found", err.Message())
+
+	latest, err := repo.Latest(domain.DefaultTenant, 1)
+	assert.Nil(t, err)
+	assert.Nil(t, latest)
+}
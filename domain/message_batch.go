@@ -0,0 +1,202 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+// The writes a batch is made of.
+const (
+	WriteSave       = "save"
+	WriteDelete     = "delete"
+	WriteSoftDelete = "soft_delete"
+	WriteRestore    = "restore"
+)
+
+// MessageWrite is one write of a batch: the save of Message, or the delete,
+// soft delete or restore of the message MessageId, in Tenant.
+type MessageWrite struct {
+	Op        string
+	Tenant    string
+	Message   *Message
+	MessageId int64
+}
+
+// MessageWriteResult is the outcome of one write of a batch. Message is the
+// message as stored by a save or restore.
+type MessageWriteResult struct {
+	Message *Message
+	Err     error_utils.MessageErr
+}
+
+// stagedWrite is a write queued in the batch transaction, with what it
+// stores once the transaction ran.
+type stagedWrite struct {
+	index  int
+	op     string
+	stored *Message
+	run    func(redis.Pipeliner) *redis.Cmd
+	cmd    *redis.Cmd
+}
+
+// ApplyBatch applies writes, in order, to the messages of their tenants in
+// one Redis transaction. Every write has its own result: one the scripts
+// refuse, such as a save over the tenant's quota, fails alone and a restore
+// of a missing message fails before the transaction. The returned error
+// means the transaction did not run and nothing was written.
+func (mr *messageRepo) ApplyBatch(writes []MessageWrite) ([]MessageWriteResult, error_utils.MessageErr) {
+	results := make([]MessageWriteResult, len(writes))
+	// current holds the messages as the writes staged so far leave them, by
+	// key, nil for removed ones, so a write sees those before it.
+	current := make(map[string]*Message)
+	load := func(repo *messageRepo, messageId int64) (*Message, error_utils.MessageErr) {
+		msg, ok := current[repo.messageKey(messageId)]
+		if !ok {
+			return repo.Get(messageId)
+		}
+		if msg == nil {
+			return nil, error_utils.NewNotFoundError("message not found").WithCode(error_utils.CodeMessageNotFound)
+		}
+		copied := *msg
+		return &copied, nil
+	}
+
+	var staged []*stagedWrite
+	for i, write := range writes {
+		repo := mr.ForTenant(write.Tenant).(*messageRepo)
+		switch write.Op {
+		case WriteSave:
+			msg := write.Message
+			data, err := json.Marshal(msg)
+			if err != nil {
+				results[i].Err = error_formats.Translate(err, "json marshal")
+				continue
+			}
+			keys, quota := repo.scriptKeys(msg.Id), QuotaFor(repo.tenant)
+			staged = append(staged, &stagedWrite{index: i, op: write.Op, stored: msg, run: func(pipe redis.Pipeliner) *redis.Cmd {
+				return saveScript.Eval(ctx, pipe, keys, data, quota, repo.deletedMember(msg.Id),
+					msg.Id, msg.CreatedAt.UnixMilli(), titleMember(msg), countedStats(msg))
+			}})
+			current[repo.messageKey(msg.Id)] = msg
+
+		case WriteDelete:
+			messageId := write.MessageId
+			keys := repo.scriptKeys(messageId)
+			staged = append(staged, &stagedWrite{index: i, op: write.Op, run: func(pipe redis.Pipeliner) *redis.Cmd {
+				return deleteScript.Eval(ctx, pipe, keys, repo.deletedMember(messageId), messageId)
+			}})
+			current[repo.messageKey(messageId)] = nil
+
+		case WriteSoftDelete:
+			messageId := write.MessageId
+			msg, getErr := load(repo, messageId)
+			if errors.Is(getErr, error_utils.ErrNotFound) {
+				continue
+			} else if getErr != nil {
+				return nil, getErr
+			}
+			if msg.IsDeleted() {
+				continue
+			}
+			deletedAt := time.Now().UTC()
+			msg.DeletedAt = &deletedAt
+			data, err := json.Marshal(msg)
+			if err != nil {
+				results[i].Err = error_formats.Translate(err, "json marshal")
+				continue
+			}
+			keys := repo.scriptKeys(messageId)
+			staged = append(staged, &stagedWrite{index: i, op: write.Op, run: func(pipe redis.Pipeliner) *redis.Cmd {
+				return softDeleteScript.Eval(ctx, pipe, keys, data, repo.deletedMember(messageId), deletedAt.Unix(), messageId)
+			}})
+			current[repo.messageKey(messageId)] = msg
+
+		case WriteRestore:
+			messageId := write.MessageId
+			msg, getErr := load(repo, messageId)
+			if errors.Is(getErr, error_utils.ErrNotFound) {
+				results[i].Err = getErr
+				continue
+			} else if getErr != nil {
+				return nil, getErr
+			}
+			results[i].Message = msg
+			if !msg.IsDeleted() {
+				continue
+			}
+			msg.DeletedAt = nil
+			data, err := json.Marshal(msg)
+			if err != nil {
+				results[i].Err = error_formats.Translate(err, "json marshal")
+				continue
+			}
+			keys := repo.scriptKeys(messageId)
+			staged = append(staged, &stagedWrite{index: i, op: write.Op, stored: msg, run: func(pipe redis.Pipeliner) *redis.Cmd {
+				return restoreScript.Eval(ctx, pipe, keys, data, repo.deletedMember(messageId), messageId, countedStats(msg))
+			}})
+			current[repo.messageKey(messageId)] = msg
+
+		default:
+			results[i].Err = error_utils.NewBadRequestError("unknown write").WithDetail(write.Op)
+		}
+	}
+	if len(staged) == 0 {
+		return results, nil
+	}
+
+	_, err := mr.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, write := range staged {
+			write.cmd = write.run(pipe)
+		}
+		return nil
+	})
+	// A reply error belongs to one command; any other error means the
+	// transaction did not run.
+	var replyErr redis.Error
+	if err != nil && !errors.As(err, &replyErr) {
+		return nil, error_formats.Translate(err, "redis batch")
+	}
+	for _, write := range staged {
+		result := &results[write.index]
+		saved, cmdErr := write.cmd.Int()
+		switch {
+		case cmdErr != nil:
+			result.Err = error_formats.Translate(cmdErr, "redis batch "+write.op)
+		case write.op == WriteSave && saved == 0:
+			result.Err = error_utils.NewUnprocessibleEntityError("tenant message quota exceeded").WithCode(error_utils.CodeTenantQuotaExceeded)
+		case write.op == WriteRestore && saved == 0:
+			result.Err = error_utils.NewNotFoundError("message not found").WithCode(error_utils.CodeMessageNotFound)
+		default:
+			result.Message = write.stored
+		}
+	}
+	return results, nil
+}
+
+// ApplyBatch applies the batch to the primary and then mirrors every write
+// that succeeded there, as the single writes do.
+func (r *migratingRepo) ApplyBatch(writes []MessageWrite) ([]MessageWriteResult, error_utils.MessageErr) {
+	results, err := r.primary.ApplyBatch(writes)
+	if err != nil {
+		return nil, err
+	}
+	for i, write := range writes {
+		if results[i].Err != nil {
+			continue
+		}
+		repo := r.ForTenant(write.Tenant).(*migratingRepo)
+		switch write.Op {
+		case WriteSave, WriteRestore:
+			results[i].Err = repo.mirror(write.Op, repo.secondary.importMessage(results[i].Message))
+		case WriteDelete:
+			results[i].Err = repo.mirror(write.Op, repo.secondary.Delete(write.MessageId))
+		case WriteSoftDelete:
+			results[i].Err = repo.mirror(write.Op, repo.sync(write.MessageId))
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,99 @@
+package domain_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+func TestApplyBatch(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewMessageRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	assert.Nil(t, repo.ForTenant("team-a").Save(&domain.Message{Id: 9, Title: "old"}))
+
+	results, err := repo.ApplyBatch([]domain.MessageWrite{
+		{Op: domain.WriteSave, Tenant: "team-a", Message: &domain.Message{Id: 1, Title: "first"}},
+		{Op: domain.WriteSave, Tenant: "team-b", Message: &domain.Message{Id: 1, Title: "other tenant"}},
+		{Op: domain.WriteSoftDelete, Tenant: "team-a", MessageId: 1},
+		{Op: domain.WriteRestore, Tenant: "team-a", MessageId: 1},
+		{Op: domain.WriteDelete, Tenant: "team-a", MessageId: 9},
+		{Op: domain.WriteRestore, Tenant: "team-a", MessageId: 9},
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, results, 6)
+	for _, result := range results[:5] {
+		assert.Nil(t, result.Err)
+	}
+	assert.EqualValues(t, "first", results[3].Message.Title)
+	assert.True(t, errors.Is(results[5].Err, error_utils.ErrNotFound))
+
+	first, getErr := repo.ForTenant("team-a").Get(1)
+	assert.Nil(t, getErr)
+	assert.False(t, first.IsDeleted())
+	other, getErr := repo.ForTenant("team-b").Get(1)
+	assert.Nil(t, getErr)
+	assert.EqualValues(t, "other tenant", other.Title)
+	assert.False(t, server.Exists("tenant:team-a:message:9"))
+	count, _ := server.Get("tenant:team-a:message_count")
+	assert.Equal(t, "1", count)
+}
+
+func TestApplyBatch_Quota_Fails_Alone(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewMessageRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	assert.Nil(t, domain.InitializeQuotas("team-a=1"))
+	defer domain.InitializeQuotas("")
+
+	results, err := repo.ApplyBatch([]domain.MessageWrite{
+		{Op: domain.WriteSave, Tenant: "team-a", Message: &domain.Message{Id: 1, Title: "first"}},
+		{Op: domain.WriteSave, Tenant: "team-a", Message: &domain.Message{Id: 2, Title: "second"}},
+	})
+
+	assert.Nil(t, err)
+	assert.Nil(t, results[0].Err)
+	assert.EqualValues(t, error_utils.CodeTenantQuotaExceeded, results[1].Err.Code())
+	assert.True(t, server.Exists("tenant:team-a:message:1"))
+	assert.False(t, server.Exists("tenant:team-a:message:2"))
+}
+
+func TestApplyBatch_Redis_Down(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewMessageRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	server.Close()
+
+	_, err := repo.ApplyBatch([]domain.MessageWrite{
+		{Op: domain.WriteSave, Tenant: "team-a", Message: &domain.Message{Id: 1, Title: "first"}},
+	})
+
+	assert.True(t, errors.Is(err, error_utils.ErrUnavailable))
+}
+
+func TestApplyBatch_Migration_Mirrors_Writes(t *testing.T) {
+	repo, primary, secondary, secondaryServer := newMigration(t, domain.MigrationReadPrimary)
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	results, err := repo.ApplyBatch([]domain.MessageWrite{
+		{Op: domain.WriteSave, Tenant: "team-a", Message: &domain.Message{Id: 1, Title: "first", Body: "body", CreatedAt: created}},
+		{Op: domain.WriteSave, Tenant: "team-a", Message: &domain.Message{Id: 2, Title: "second", Body: "body", CreatedAt: created}},
+		{Op: domain.WriteSoftDelete, Tenant: "team-a", MessageId: 1},
+		{Op: domain.WriteDelete, Tenant: "team-a", MessageId: 2},
+	})
+
+	assert.Nil(t, err)
+	for _, result := range results {
+		assert.Nil(t, result.Err)
+	}
+	fromPrimary, _ := primary.ForTenant("team-a").Get(1)
+	fromSecondary, getErr := secondary.ForTenant("team-a").Get(1)
+	assert.Nil(t, getErr)
+	assert.Equal(t, fromPrimary, fromSecondary)
+	assert.True(t, fromSecondary.IsDeleted())
+	assert.False(t, secondaryServer.Exists("tenant:team-a:message:2"))
+}
@@ -3,70 +3,168 @@ package domain
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/go-redis/redis/v8"
 	"log"
 	"strconv"
+	"strings"
+	"testing-project/utils/error_formats"
 	"testing-project/utils/error_utils"
+	"time"
 )
 
+// deletedIndexKey is a sorted set of soft-deleted messages across all
+// tenants, members "<tenant>:<id>" scored by deletion time, which the purge
+// job walks to find messages past their retention.
+const deletedIndexKey = "messages:deleted"
+
 var (
 	MessageRepo messageRepoInterface = &messageRepo{}
 	ctx                              = context.Background()
 )
 
+// MessageRepository names the repository interface outside this package so
+// that test doubles elsewhere can implement ForTenant.
+type MessageRepository = messageRepoInterface
+
 type messageRepoInterface interface {
+	ForTenant(string) messageRepoInterface
 	Get(int64) (*Message, error_utils.MessageErr)
 	GetAll() ([]Message, error_utils.MessageErr)
+	Each(func(*Message) error) error_utils.MessageErr
+	GetMany([]int64) ([]Message, error_utils.MessageErr)
+	Find(MessageFilter) ([]Message, error_utils.MessageErr)
+	EachMatching(MessageFilter, func(*Message) error) error_utils.MessageErr
+	EachByCreated(MessageFilter, func(*Message) error) error_utils.MessageErr
 	Save(*Message) error_utils.MessageErr
+	SaveIfAbsent(*Message) (bool, error_utils.MessageErr)
 	Delete(int64) error_utils.MessageErr
+	SoftDelete(int64) error_utils.MessageErr
+	Restore(int64) (*Message, error_utils.MessageErr)
+	ApplyBatch([]MessageWrite) ([]MessageWriteResult, error_utils.MessageErr)
+	PurgeDeleted(time.Time) (map[string][]int64, error_utils.MessageErr)
+	Reindex() (int, error_utils.MessageErr)
 	Initialize(string, string, string) *redis.Client
 }
 
+// messageRepo reads and writes the messages of one tenant. Every key it
+// touches carries the tenant prefix, so one tenant's repository can never see
+// another tenant's messages.
 type messageRepo struct {
 	client *redis.Client
+	tenant string
 }
 
 func (mr *messageRepo) Initialize(addr, password, db string) *redis.Client {
+	mr.client = NewRedisClient(addr, password, db)
+	return mr.client
+}
+
+// NewRedisClient connects to a Redis server and exits if it does not answer.
+func NewRedisClient(addr, password, db string) *redis.Client {
 	dbIndex, _ := strconv.Atoi(db)
-	mr.client = redis.NewClient(&redis.Options{
+	client := redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: password,
 		DB:       dbIndex,
 	})
 
-	_, err := mr.client.Ping(ctx).Result()
+	_, err := client.Ping(ctx).Result()
 	if err != nil {
 		log.Fatal("Ошибка подключения к Redis:", err)
 	}
 
 	fmt.Println("Успешное подключение к Redis")
-	return mr.client
+	return client
 }
 
 func NewMessageRepository(client *redis.Client) messageRepoInterface {
-	return &messageRepo{client: client}
+	return &messageRepo{client: client, tenant: DefaultTenant}
+}
+
+// ForTenant returns a repository scoped to tenant. The tenant must already be
+// normalized with NormalizeTenant.
+func (mr *messageRepo) ForTenant(tenant string) messageRepoInterface {
+	if tenant == "" {
+		tenant = DefaultTenant
+	}
+	return &messageRepo{client: mr.client, tenant: tenant}
+}
+
+func (mr *messageRepo) messageKey(messageId int64) string {
+	return fmt.Sprintf("%smessage:%d", tenantPrefix(mr.tenant), messageId)
+}
+
+func (mr *messageRepo) countKey() string {
+	return tenantPrefix(mr.tenant) + "message_count"
+}
+
+func (mr *messageRepo) deletedMember(messageId int64) string {
+	return fmt.Sprintf("%s:%d", mr.tenant, messageId)
+}
+
+func createdIndexKey(tenant string) string {
+	return tenantPrefix(tenant) + "messages:by_created"
+}
+
+func titleIndexKey(tenant string) string {
+	return tenantPrefix(tenant) + "messages:by_title"
+}
+
+func statsKey(tenant string) string {
+	return tenantPrefix(tenant) + "messages:stats"
+}
+
+func statsOfKey(tenant string) string {
+	return tenantPrefix(tenant) + "messages:stats_of"
+}
+
+// scriptKeys are the keys every script in message_scripts.go works on.
+func (mr *messageRepo) scriptKeys(messageId int64) []string {
+	return []string{
+		mr.messageKey(messageId),
+		mr.countKey(),
+		deletedIndexKey,
+		createdIndexKey(mr.tenant),
+		titleIndexKey(mr.tenant),
+		tenantPrefix(mr.tenant) + "messages:title_of",
+		statsKey(mr.tenant),
+		statsOfKey(mr.tenant),
+	}
+}
+
+func titleMember(msg *Message) string {
+	return fmt.Sprintf("%s\x00%d", strings.ToLower(msg.Title), msg.Id)
+}
+
+// countedStats is what the statistics counters hold for a live message.
+func countedStats(msg *Message) string {
+	created := msg.CreatedAt.UTC()
+	return fmt.Sprintf("%d|%d|%s|%s", len(msg.Title), len(msg.Body), created.Format(statsDayLayout), created.Format(statsHourLayout))
 }
 
 func (mr *messageRepo) Get(messageId int64) (*Message, error_utils.MessageErr) {
-	data, err := mr.client.Get(ctx, fmt.Sprintf("message:%d", messageId)).Result()
-	if err == redis.Nil {
-		return nil, error_utils.NewNotFoundError("message not found")
-	} else if err != nil {
-		return nil, error_utils.NewInternalServerError("redis get error")
+	data, err := mr.client.Get(ctx, mr.messageKey(messageId)).Result()
+	if err != nil {
+		translated := error_formats.Translate(err, "redis get")
+		if errors.Is(translated, error_utils.ErrNotFound) {
+			return nil, error_utils.NewNotFoundError("message not found").WithCode(error_utils.CodeMessageNotFound)
+		}
+		return nil, translated
 	}
 
 	var msg Message
 	if err := json.Unmarshal([]byte(data), &msg); err != nil {
-		return nil, error_utils.NewInternalServerError("json unmarshal error")
+		return nil, error_formats.Translate(err, "json unmarshal")
 	}
 	return &msg, nil
 }
 
 func (mr *messageRepo) GetAll() ([]Message, error_utils.MessageErr) {
-	keys, err := mr.client.Keys(ctx, "message:*").Result()
+	keys, err := mr.client.Keys(ctx, tenantPrefix(mr.tenant)+"message:*").Result()
 	if err != nil {
-		return nil, error_utils.NewInternalServerError("error fetching keys")
+		return nil, error_formats.Translate(err, "redis keys")
 	}
 
 	messages := make([]Message, 0)
@@ -82,29 +180,550 @@ func (mr *messageRepo) GetAll() ([]Message, error_utils.MessageErr) {
 		messages = append(messages, msg)
 	}
 	if len(messages) == 0 {
-		return nil, error_utils.NewNotFoundError("no messages found")
+		return nil, error_utils.NewNotFoundError("no messages found").WithCode(error_utils.CodeNoMessagesFound)
+	}
+	return messages, nil
+}
+
+// eachPageSize is how many keys Each asks SCAN for, and reads with one MGET.
+const eachPageSize = 100
+
+// Each calls fn for every stored message of the tenant as the messages are
+// read, a page at a time, so a caller can stream a listing without holding
+// it in memory. It stops at the first error fn returns.
+func (mr *messageRepo) Each(fn func(*Message) error) error_utils.MessageErr {
+	// SCAN may return a key more than once while the keyspace is rehashed.
+	seen := make(map[string]struct{})
+	var cursor uint64
+	for {
+		keys, next, err := mr.client.Scan(ctx, cursor, tenantPrefix(mr.tenant)+"message:*", eachPageSize).Result()
+		if err != nil {
+			return error_formats.Translate(err, "redis scan")
+		}
+		fresh := keys[:0]
+		for _, key := range keys {
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				fresh = append(fresh, key)
+			}
+		}
+		if len(fresh) > 0 {
+			values, err := mr.client.MGet(ctx, fresh...).Result()
+			if err != nil {
+				return error_formats.Translate(err, "redis mget")
+			}
+			for _, value := range values {
+				data, ok := value.(string)
+				if !ok {
+					continue
+				}
+				var msg Message
+				if err := json.Unmarshal([]byte(data), &msg); err != nil {
+					continue
+				}
+				if err := fn(&msg); err != nil {
+					return error_utils.NewInternalServerError(err.Error()).Wrap(err)
+				}
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// GetMany fetches several messages in one round-trip. Ids that do not exist
+// are simply left out of the result.
+func (mr *messageRepo) GetMany(messageIds []int64) ([]Message, error_utils.MessageErr) {
+	messages := make([]Message, 0, len(messageIds))
+	if len(messageIds) == 0 {
+		return messages, nil
+	}
+
+	keys := make([]string, len(messageIds))
+	for i, id := range messageIds {
+		keys[i] = mr.messageKey(id)
+	}
+	values, err := mr.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, error_formats.Translate(err, "redis mget")
+	}
+
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var msg Message
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			continue
+		}
+		messages = append(messages, msg)
 	}
 	return messages, nil
 }
 
 func (mr *messageRepo) Save(msg *Message) error_utils.MessageErr {
+	_, err := mr.save(saveScript, msg)
+	return err
+}
+
+// SaveIfAbsent saves msg unless a version of it, soft-deleted or not, is
+// already stored, and reports whether it saved it.
+func (mr *messageRepo) SaveIfAbsent(msg *Message) (bool, error_utils.MessageErr) {
+	saved, err := mr.save(saveIfAbsentScript, msg)
+	return saved > 0, err
+}
+
+func (mr *messageRepo) save(script *redis.Script, msg *Message) (int, error_utils.MessageErr) {
 	data, err := json.Marshal(msg)
 	if err != nil {
-		return error_utils.NewInternalServerError("json marshal error")
+		return 0, error_formats.Translate(err, "json marshal")
 	}
-	key := fmt.Sprintf("message:%d", msg.Id)
-	err = mr.client.Set(ctx, key, data, 0).Err()
+	saved, err := script.Run(ctx, mr.client, mr.scriptKeys(msg.Id), data, QuotaFor(mr.tenant), mr.deletedMember(msg.Id),
+		msg.Id, msg.CreatedAt.UnixMilli(), titleMember(msg), countedStats(msg)).Int()
 	if err != nil {
-		return error_utils.NewInternalServerError("redis save error")
+		return 0, error_formats.Translate(err, "redis save")
 	}
-	return nil
+	if saved == 0 {
+		return 0, error_utils.NewUnprocessibleEntityError("tenant message quota exceeded").WithCode(error_utils.CodeTenantQuotaExceeded)
+	}
+	return saved, nil
 }
 
 func (mr *messageRepo) Delete(messageId int64) error_utils.MessageErr {
-	key := fmt.Sprintf("message:%d", messageId)
-	_, err := mr.client.Del(ctx, key).Result()
+	if err := deleteScript.Run(ctx, mr.client, mr.scriptKeys(messageId), mr.deletedMember(messageId), messageId).Err(); err != nil {
+		return error_formats.Translate(err, "redis delete")
+	}
+	return nil
+}
+
+// Find returns the messages matching filter, see EachMatching.
+func (mr *messageRepo) Find(filter MessageFilter) ([]Message, error_utils.MessageErr) {
+	messages := make([]Message, 0)
+	err := mr.EachMatching(filter, func(message *Message) error {
+		messages = append(messages, *message)
+		return nil
+	})
 	if err != nil {
-		return error_utils.NewInternalServerError("redis delete error")
+		return nil, err
+	}
+	return messages, nil
+}
+
+// EachMatching calls fn for every message matching filter until fn fails.
+// One index narrows down the candidates (ids, then title prefix, then
+// creation time, then a scan of the title index for substrings) and the
+// filter itself makes the final decision on the loaded messages. Candidates
+// are read and loaded a page at a time, so nothing is held in memory.
+func (mr *messageRepo) EachMatching(filter MessageFilter, fn func(*Message) error) error_utils.MessageErr {
+	return mr.eachCandidate(&filter, func(ids []int64) error_utils.MessageErr {
+		messages, err := mr.GetMany(ids)
+		if err != nil {
+			return err
+		}
+		for i := range messages {
+			if !filter.Matches(&messages[i]) {
+				continue
+			}
+			if err := fn(&messages[i]); err != nil {
+				return error_utils.NewInternalServerError(err.Error()).Wrap(err)
+			}
+		}
+		return nil
+	})
+}
+
+// EachByCreated calls fn for every message matching filter in the order of
+// the created-at index, from the filter's lower bound on, until fn fails.
+// The index and the messages are read a page at a time, so a caller that
+// only wants the first few stops early and nothing is held in memory.
+func (mr *messageRepo) EachByCreated(filter MessageFilter, fn func(*Message) error) error_utils.MessageErr {
+	// Scores are truncated to milliseconds, so the bounds are inclusive
+	// here and exact in Matches.
+	var from int64
+	lower := false
+	if filter.CreatedAfter != nil {
+		from, lower = filter.CreatedAfter.UnixMilli(), true
+	}
+	if filter.After != nil && (!lower || filter.After.CreatedAt > from) {
+		from, lower = filter.After.CreatedAt, true
+	}
+	byCreated := &redis.ZRangeBy{Min: "-inf", Max: "+inf"}
+	if lower {
+		byCreated.Min = strconv.FormatInt(from, 10)
+	}
+	if filter.CreatedBefore != nil {
+		byCreated.Max = strconv.FormatInt(filter.CreatedBefore.UnixMilli(), 10)
+	}
+
+	return mr.eachCreated(byCreated, func(ids []int64) error_utils.MessageErr {
+		messages, err := mr.GetMany(ids)
+		if err != nil {
+			return err
+		}
+		for i := range messages {
+			if !filter.Matches(&messages[i]) {
+				continue
+			}
+			if err := fn(&messages[i]); err != nil {
+				return error_utils.NewInternalServerError(err.Error()).Wrap(err)
+			}
+		}
+		return nil
+	})
+}
+
+// eachCreated calls fn with the ids of the created-at index within the
+// score range, in index order, a page of eachPageSize at a time. Each page
+// starts at the score the previous one ended on, skipping the members with
+// that score it already returned, so paging costs no more than the page.
+func (mr *messageRepo) eachCreated(byCreated *redis.ZRangeBy, fn func(ids []int64) error_utils.MessageErr) error_utils.MessageErr {
+	page := &redis.ZRangeBy{Min: byCreated.Min, Max: byCreated.Max, Count: eachPageSize}
+	for {
+		members, err := mr.client.ZRangeByScoreWithScores(ctx, createdIndexKey(mr.tenant), page).Result()
+		if err != nil {
+			return error_formats.Translate(err, "redis index")
+		}
+		ids := make([]int64, 0, len(members))
+		for _, member := range members {
+			if id, err := strconv.ParseInt(member.Member.(string), 10, 64); err == nil {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) > 0 {
+			if err := fn(ids); err != nil {
+				return err
+			}
+		}
+		if int64(len(members)) < eachPageSize {
+			return nil
+		}
+
+		last := strconv.FormatFloat(members[len(members)-1].Score, 'f', -1, 64)
+		if last != page.Min {
+			page.Min, page.Offset = last, 0
+		}
+		for _, member := range members {
+			if strconv.FormatFloat(member.Score, 'f', -1, 64) == last {
+				page.Offset++
+			}
+		}
+	}
+}
+
+// eachCandidate calls fn with the ids of the index that best narrows down
+// filter, a page of about eachPageSize at a time.
+func (mr *messageRepo) eachCandidate(filter *MessageFilter, fn func(ids []int64) error_utils.MessageErr) error_utils.MessageErr {
+	switch {
+	case filter.Ids != nil:
+		for start := 0; start < len(filter.Ids); start += eachPageSize {
+			end := start + eachPageSize
+			if end > len(filter.Ids) {
+				end = len(filter.Ids)
+			}
+			if err := fn(filter.Ids[start:end]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case filter.TitlePrefix != "":
+		prefix := strings.ToLower(filter.TitlePrefix)
+		byTitle := &redis.ZRangeBy{Min: "[" + prefix, Max: "[" + prefix + "\xff", Count: eachPageSize}
+		for {
+			members, err := mr.client.ZRangeByLex(ctx, titleIndexKey(mr.tenant), byTitle).Result()
+			if err != nil {
+				return error_formats.Translate(err, "redis index")
+			}
+			if len(members) > 0 {
+				if err := fn(idsFromTitleMembers(members)); err != nil {
+					return err
+				}
+			}
+			if int64(len(members)) < eachPageSize {
+				return nil
+			}
+			// Members are unique, so the next page starts right after the last.
+			byTitle.Min = "(" + members[len(members)-1]
+		}
+	case filter.CreatedAfter != nil || filter.CreatedBefore != nil:
+		// Scores are truncated to milliseconds, so the bounds are inclusive
+		// here and exact in Matches.
+		byCreated := &redis.ZRangeBy{Min: "-inf", Max: "+inf"}
+		if filter.CreatedAfter != nil {
+			byCreated.Min = strconv.FormatInt(filter.CreatedAfter.UnixMilli(), 10)
+		}
+		if filter.CreatedBefore != nil {
+			byCreated.Max = strconv.FormatInt(filter.CreatedBefore.UnixMilli(), 10)
+		}
+		return mr.eachCreated(byCreated, fn)
+	default:
+		// SCAN may return a member more than once while the index is being
+		// rehashed, so the ids already passed on are remembered.
+		match := "*" + escapeGlob(strings.ToLower(filter.TitleContains)) + "*"
+		seen := make(map[int64]bool)
+		var cursor uint64
+		for {
+			page, next, err := mr.client.ZScan(ctx, titleIndexKey(mr.tenant), cursor, match, eachPageSize).Result()
+			if err != nil {
+				return error_formats.Translate(err, "redis index")
+			}
+			members := make([]string, 0, len(page)/2)
+			for i := 0; i < len(page); i += 2 {
+				members = append(members, page[i])
+			}
+			var ids []int64
+			for _, id := range idsFromTitleMembers(members) {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+			if len(ids) > 0 {
+				if err := fn(ids); err != nil {
+					return err
+				}
+			}
+			if next == 0 {
+				return nil
+			}
+			cursor = next
+		}
+	}
+}
+
+func idsFromTitleMembers(members []string) []int64 {
+	ids := make([]int64, 0, len(members))
+	for _, member := range members {
+		separator := strings.LastIndexByte(member, 0)
+		if id, err := strconv.ParseInt(member[separator+1:], 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func escapeGlob(s string) string {
+	var escaped strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\^`, r) {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
+
+// Reindex rebuilds the indexes and statistics of every stored message of
+// every tenant, for messages stored before they existed, and returns how
+// many it saw.
+func (mr *messageRepo) Reindex() (int, error_utils.MessageErr) {
+	indexed := 0
+	err := mr.eachStored(func(tenant string, msg *Message) error_utils.MessageErr {
+		counted := ""
+		if !msg.IsDeleted() {
+			counted = countedStats(msg)
+		}
+		keys := mr.ForTenant(tenant).(*messageRepo).scriptKeys(msg.Id)
+		if err := reindexScript.Run(ctx, mr.client, keys, msg.Id, msg.CreatedAt.UnixMilli(), titleMember(msg), counted).Err(); err != nil {
+			return error_formats.Translate(err, "redis index")
+		}
+		indexed++
+		return nil
+	})
+	return indexed, err
+}
+
+// eachStored calls fn for every stored message of every tenant, in no
+// particular order, until fn fails. Keys that vanish while it runs or do not
+// hold a message are skipped.
+func (mr *messageRepo) eachStored(fn func(tenant string, msg *Message) error_utils.MessageErr) error_utils.MessageErr {
+	// SCAN may return a key more than once while the keyspace is rehashed.
+	seen := make(map[string]struct{})
+	for _, pattern := range []string{"message:*", "tenant:*:message:*"} {
+		iter := mr.client.Scan(ctx, 0, pattern, 1000).Iterator()
+		for iter.Next(ctx) {
+			key := iter.Val()
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			tenant := DefaultTenant
+			if strings.HasPrefix(key, "tenant:") {
+				tenant = strings.SplitN(key, ":", 3)[1]
+			}
+			data, err := mr.client.Get(ctx, key).Result()
+			if err != nil {
+				continue
+			}
+			var msg Message
+			if err := json.Unmarshal([]byte(data), &msg); err != nil {
+				continue
+			}
+			if err := fn(tenant, &msg); err != nil {
+				return err
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return error_formats.Translate(err, "redis scan")
+		}
+	}
+	return nil
+}
+
+// importMessage stores msg exactly as it is, soft-deleted or not, with its
+// indexes and statistics. Unlike Save it ignores the tenant's quota, since
+// the message was already accepted where it is copied from.
+func (mr *messageRepo) importMessage(msg *Message) error_utils.MessageErr {
+	args, err := mr.importArgs(msg)
+	if err != nil {
+		return err
+	}
+	if err := importScript.Run(ctx, mr.client, mr.scriptKeys(msg.Id), args...).Err(); err != nil {
+		return error_formats.Translate(err, "redis import")
+	}
+	return nil
+}
+
+// importMessageIf is importMessage applied only while the stored message is
+// still exactly expected (empty when there should be none), and reports
+// whether it was.
+func (mr *messageRepo) importMessageIf(msg *Message, expected string) (bool, error_utils.MessageErr) {
+	args, err := mr.importArgs(msg)
+	if err != nil {
+		return false, err
+	}
+	imported, runErr := importIfScript.Run(ctx, mr.client, mr.scriptKeys(msg.Id), append(args, expected)...).Int()
+	if runErr != nil {
+		return false, error_formats.Translate(runErr, "redis import")
+	}
+	return imported == 1, nil
+}
+
+func (mr *messageRepo) importArgs(msg *Message) ([]interface{}, error_utils.MessageErr) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, error_formats.Translate(err, "json marshal")
+	}
+	counted, deletedAt := "", int64(0)
+	if msg.IsDeleted() {
+		deletedAt = msg.DeletedAt.Unix()
+	} else {
+		counted = countedStats(msg)
+	}
+	return []interface{}{data, mr.deletedMember(msg.Id), msg.Id, msg.CreatedAt.UnixMilli(), titleMember(msg), counted, deletedAt}, nil
+}
+
+// deleteIf is Delete applied only while the stored message is still exactly
+// expected.
+func (mr *messageRepo) deleteIf(messageId int64, expected string) error_utils.MessageErr {
+	if err := deleteIfScript.Run(ctx, mr.client, mr.scriptKeys(messageId), mr.deletedMember(messageId), messageId, expected).Err(); err != nil {
+		return error_formats.Translate(err, "redis delete")
+	}
+	return nil
+}
+
+// raw returns the message as it is stored, empty when there is none.
+func (mr *messageRepo) raw(messageId int64) (string, error_utils.MessageErr) {
+	data, err := mr.client.Get(ctx, mr.messageKey(messageId)).Result()
+	if err == redis.Nil {
+		return "", nil
+	} else if err != nil {
+		return "", error_formats.Translate(err, "redis get")
+	}
+	return data, nil
+}
+
+// SoftDelete marks a message as deleted instead of removing it, so it can
+// still be inspected and restored until it is purged. Deleting a message that
+// does not exist or is already deleted is a no-op, like Delete.
+func (mr *messageRepo) SoftDelete(messageId int64) error_utils.MessageErr {
+	msg, getErr := mr.Get(messageId)
+	if getErr != nil {
+		if errors.Is(getErr, error_utils.ErrNotFound) {
+			return nil
+		}
+		return getErr
+	}
+	if msg.IsDeleted() {
+		return nil
+	}
+
+	deletedAt := time.Now().UTC()
+	msg.DeletedAt = &deletedAt
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return error_formats.Translate(err, "json marshal")
+	}
+	err = softDeleteScript.Run(ctx, mr.client, mr.scriptKeys(messageId), data, mr.deletedMember(messageId), deletedAt.Unix(), messageId).Err()
+	if err != nil {
+		return error_formats.Translate(err, "redis delete")
 	}
 	return nil
 }
+
+// Restore brings a soft-deleted message back and returns it.
+func (mr *messageRepo) Restore(messageId int64) (*Message, error_utils.MessageErr) {
+	msg, getErr := mr.Get(messageId)
+	if getErr != nil {
+		return nil, getErr
+	}
+	if !msg.IsDeleted() {
+		return msg, nil
+	}
+
+	msg.DeletedAt = nil
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, error_formats.Translate(err, "json marshal")
+	}
+	restored, err := restoreScript.Run(ctx, mr.client, mr.scriptKeys(messageId), data, mr.deletedMember(messageId), messageId, countedStats(msg)).Int()
+	if err != nil {
+		return nil, error_formats.Translate(err, "redis restore")
+	}
+	if restored == 0 {
+		return nil, error_utils.NewNotFoundError("message not found").WithCode(error_utils.CodeMessageNotFound)
+	}
+	return msg, nil
+}
+
+// PurgeDeleted hard-deletes the messages of every tenant that were
+// soft-deleted before the given time and returns the ids removed, by tenant,
+// so that cached copies can be invalidated. On error, the ids removed so far
+// are returned with it.
+func (mr *messageRepo) PurgeDeleted(before time.Time) (map[string][]int64, error_utils.MessageErr) {
+	members, err := mr.client.ZRangeByScore(ctx, deletedIndexKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(before.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return nil, error_formats.Translate(err, "redis purge")
+	}
+
+	purged := map[string][]int64{}
+	for _, member := range members {
+		tenant, rawId, _ := strings.Cut(member, ":")
+		messageId, parseErr := strconv.ParseInt(rawId, 10, 64)
+		if parseErr != nil {
+			mr.client.ZRem(ctx, deletedIndexKey, member)
+			continue
+		}
+
+		repo := mr.ForTenant(tenant)
+		msg, getErr := repo.Get(messageId)
+		if getErr != nil && !errors.Is(getErr, error_utils.ErrNotFound) {
+			return purged, getErr
+		}
+		if msg == nil || !msg.IsDeleted() {
+			// Gone or saved again since it was deleted.
+			mr.client.ZRem(ctx, deletedIndexKey, member)
+			continue
+		}
+		if err := repo.Delete(messageId); err != nil {
+			return purged, err
+		}
+		purged[tenant] = append(purged[tenant], messageId)
+	}
+	return purged, nil
+}
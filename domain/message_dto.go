@@ -1,16 +1,37 @@
 package domain
 
 import (
+	"strconv"
 	"strings"
 	"testing-project/utils/error_utils"
 	"time"
 )
 
 type Message struct {
-	Id        int64     `json:"id"`
-	Title     string    `json:"title"`
-	Body      string    `json:"body"`
-	CreatedAt time.Time `json:"created_at"`
+	Id        int64      `json:"id"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// IsDeleted reports whether the message has been soft-deleted.
+func (m *Message) IsDeleted() bool {
+	return m.DeletedAt != nil
+}
+
+// CSVHeader names the columns of CSVRecord.
+func (m Message) CSVHeader() []string {
+	return []string{"id", "title", "body", "created_at", "deleted_at"}
+}
+
+// CSVRecord is the message as a CSV row, with timestamps in RFC 3339.
+func (m Message) CSVRecord() []string {
+	deletedAt := ""
+	if m.DeletedAt != nil {
+		deletedAt = m.DeletedAt.Format(time.RFC3339Nano)
+	}
+	return []string{strconv.FormatInt(m.Id, 10), m.Title, m.Body, m.CreatedAt.Format(time.RFC3339Nano), deletedAt}
 }
 
 func (m *Message) Validate() error_utils.MessageErr {
@@ -24,3 +45,25 @@ func (m *Message) Validate() error_utils.MessageErr {
 	}
 	return nil
 }
+
+// DiffMessages names the fields, as they are called in JSON, in which two
+// versions of a message differ. Timestamps are compared as instants.
+func DiffMessages(a, b *Message) []string {
+	var fields []string
+	if a.Id != b.Id {
+		fields = append(fields, "id")
+	}
+	if a.Title != b.Title {
+		fields = append(fields, "title")
+	}
+	if a.Body != b.Body {
+		fields = append(fields, "body")
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		fields = append(fields, "created_at")
+	}
+	if a.IsDeleted() != b.IsDeleted() || a.IsDeleted() && !a.DeletedAt.Equal(*b.DeletedAt) {
+		fields = append(fields, "deleted_at")
+	}
+	return fields
+}
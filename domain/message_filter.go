@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MessageFilter narrows down a message listing. Title matching and Search,
+// which looks in the title and the body, are case insensitive, created
+// bounds are exclusive, After keeps the messages that come after a position
+// in the created-at index and the zero value matches every message.
+type MessageFilter struct {
+	TitlePrefix   string
+	TitleContains string
+	Search        string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	After         *MessagePosition
+	Ids           []int64
+}
+
+// MessagePosition is where a message sits in the created-at index: by
+// creation time in ms, then by id in the index's order, which compares ids
+// as strings.
+type MessagePosition struct {
+	CreatedAt int64
+	Id        int64
+}
+
+func PositionOf(m *Message) MessagePosition {
+	return MessagePosition{CreatedAt: m.CreatedAt.UnixMilli(), Id: m.Id}
+}
+
+// Before reports whether p comes before q in the created-at index.
+func (p MessagePosition) Before(q MessagePosition) bool {
+	if p.CreatedAt != q.CreatedAt {
+		return p.CreatedAt < q.CreatedAt
+	}
+	return strconv.FormatInt(p.Id, 10) < strconv.FormatInt(q.Id, 10)
+}
+
+func (f *MessageFilter) IsEmpty() bool {
+	return f.TitlePrefix == "" && f.TitleContains == "" && f.Search == "" &&
+		f.CreatedAfter == nil && f.CreatedBefore == nil && f.After == nil && f.Ids == nil
+}
+
+func (f *MessageFilter) Matches(m *Message) bool {
+	title := strings.ToLower(m.Title)
+	if f.TitlePrefix != "" && !strings.HasPrefix(title, strings.ToLower(f.TitlePrefix)) {
+		return false
+	}
+	if f.TitleContains != "" && !strings.Contains(title, strings.ToLower(f.TitleContains)) {
+		return false
+	}
+	if f.Search != "" {
+		search := strings.ToLower(f.Search)
+		if !strings.Contains(title, search) && !strings.Contains(strings.ToLower(m.Body), search) {
+			return false
+		}
+	}
+	if f.CreatedAfter != nil && !m.CreatedAt.After(*f.CreatedAfter) {
+		return false
+	}
+	if f.CreatedBefore != nil && !m.CreatedAt.Before(*f.CreatedBefore) {
+		return false
+	}
+	if f.After != nil && !f.After.Before(PositionOf(m)) {
+		return false
+	}
+	if f.Ids != nil {
+		for _, id := range f.Ids {
+			if id == m.Id {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,199 @@
+package domain
+
+import "github.com/go-redis/redis/v8"
+
+// The scripts below keep a message and everything derived from it in step,
+// atomically. They all take the keys returned by messageRepo.scriptKeys:
+//
+//	KEYS[1] the message          KEYS[5] title index
+//	KEYS[2] message count        KEYS[6] indexed title per id
+//	KEYS[3] soft-delete index    KEYS[7] statistics counters
+//	KEYS[4] created-at index     KEYS[8] counted statistics per id
+//
+// The created-at index scores ids by creation time in ms. The title index
+// holds "<lower-cased title>\x00<id>" members that are only ever ranged
+// lexicographically. The statistics counters cover live (not soft-deleted)
+// messages: "total", "title_length", "body_length" and "day:<yyyy-mm-dd>" /
+// "hour:<yyyy-mm-ddThh>" buckets by creation time (UTC). What was counted
+// for each id is remembered as "<title len>|<body len>|<day>|<hour>" so it
+// can be taken back exactly.
+const messageLua = `
+local function index(id, created, title)
+  local old = redis.call('HGET', KEYS[6], id)
+  if old then
+    redis.call('ZREM', KEYS[5], old)
+  end
+  redis.call('ZADD', KEYS[5], 0, title)
+  redis.call('HSET', KEYS[6], id, title)
+  redis.call('ZADD', KEYS[4], created, id)
+end
+
+local function unindex(id)
+  local old = redis.call('HGET', KEYS[6], id)
+  if old then
+    redis.call('ZREM', KEYS[5], old)
+  end
+  redis.call('HDEL', KEYS[6], id)
+  redis.call('ZREM', KEYS[4], id)
+end
+
+local function count(field, by)
+  if redis.call('HINCRBY', KEYS[7], field, by) == 0 then
+    redis.call('HDEL', KEYS[7], field)
+  end
+end
+
+local function unstat(id)
+  local old = redis.call('HGET', KEYS[8], id)
+  if not old then
+    return
+  end
+  local titleLength, bodyLength, day, hour = string.match(old, '^(%d+)|(%d+)|([^|]+)|([^|]+)$')
+  count('total', -1)
+  count('title_length', -tonumber(titleLength))
+  count('body_length', -tonumber(bodyLength))
+  count('day:' .. day, -1)
+  count('hour:' .. hour, -1)
+  redis.call('HDEL', KEYS[8], id)
+end
+
+local function stat(id, counted)
+  unstat(id)
+  local titleLength, bodyLength, day, hour = string.match(counted, '^(%d+)|(%d+)|([^|]+)|([^|]+)$')
+  count('total', 1)
+  count('title_length', tonumber(titleLength))
+  count('body_length', tonumber(bodyLength))
+  count('day:' .. day, 1)
+  count('hour:' .. hour, 1)
+  redis.call('HSET', KEYS[8], id, counted)
+end
+`
+
+// saveScript stores a message (ARGV[1]) and keeps the tenant's message count
+// in step, refusing new messages once the tenant's quota (ARGV[2],
+// 0 = unlimited) is reached. Updates to existing messages are always
+// allowed. A saved message is live again, so it also leaves the soft-delete
+// index (member ARGV[3]). ARGV[4..7] are the id, creation time, title member
+// and statistics of the message.
+var saveScript = redis.NewScript(messageLua + saveLua)
+
+// saveIfAbsentScript is saveScript for a message that must not replace a
+// stored one: when the message exists, soft-deleted or not, it is left alone
+// and the script returns -1. The check and the write are one atomic step, so
+// an event applied meanwhile always wins.
+var saveIfAbsentScript = redis.NewScript(messageLua + `
+if redis.call('EXISTS', KEYS[1]) == 1 then
+  return -1
+end
+` + saveLua)
+
+const saveLua = `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+  local quota = tonumber(ARGV[2])
+  local total = tonumber(redis.call('GET', KEYS[2]) or '0')
+  if quota > 0 and total >= quota then
+    return 0
+  end
+  redis.call('INCR', KEYS[2])
+end
+redis.call('SET', KEYS[1], ARGV[1])
+redis.call('ZREM', KEYS[3], ARGV[3])
+index(ARGV[4], ARGV[5], ARGV[6])
+stat(ARGV[4], ARGV[7])
+return 1
+`
+
+// deleteScript removes message ARGV[2] with everything derived from it and
+// returns how many messages were removed. ARGV[1] is its soft-delete member.
+// The version history is kept, ending with the deletion, and is trimmed by
+// its own version and age caps.
+var deleteScript = redis.NewScript(messageLua + deleteLua)
+
+// deleteIfScript is deleteScript applied only while the stored message is
+// still exactly ARGV[3]; it returns -1 otherwise.
+var deleteIfScript = redis.NewScript(messageLua + `
+if (redis.call('GET', KEYS[1]) or '') ~= ARGV[3] then
+  return -1
+end
+` + deleteLua)
+
+const deleteLua = `
+local removed = redis.call('DEL', KEYS[1])
+if removed > 0 then
+  redis.call('DECR', KEYS[2])
+end
+redis.call('ZREM', KEYS[3], ARGV[1])
+unindex(ARGV[2])
+unstat(ARGV[2])
+return removed
+`
+
+// softDeleteScript stores the marked message (ARGV[1]), adds it to the
+// soft-delete index (member ARGV[2], score ARGV[3]) and stops counting it.
+var softDeleteScript = redis.NewScript(messageLua + `
+redis.call('SET', KEYS[1], ARGV[1])
+redis.call('ZADD', KEYS[3], ARGV[3], ARGV[2])
+unstat(ARGV[4])
+return 1
+`)
+
+// restoreScript stores the restored message (ARGV[1]), takes it out of the
+// soft-delete index (member ARGV[2]) and counts it again in the statistics.
+// It skips the quota check on purpose: a soft-deleted message keeps counting
+// towards the tenant's message count until it is purged, so bringing it back
+// cannot take the tenant over its quota. A message purged in the meantime is
+// not brought back (returns 0), as that would store it without counting it.
+var restoreScript = redis.NewScript(messageLua + `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+  return 0
+end
+redis.call('SET', KEYS[1], ARGV[1])
+redis.call('ZREM', KEYS[3], ARGV[2])
+stat(ARGV[3], ARGV[4])
+return 1
+`)
+
+// reindexScript rebuilds the indexes and statistics of an existing message.
+// Statistics ARGV[4] are empty for soft-deleted messages.
+var reindexScript = redis.NewScript(messageLua + `
+index(ARGV[1], ARGV[2], ARGV[3])
+if ARGV[4] == '' then
+  unstat(ARGV[1])
+else
+  stat(ARGV[1], ARGV[4])
+end
+return 1
+`)
+
+// importScript stores a message (ARGV[1]) exactly as it is, without a quota
+// check. A soft-deleted message (empty statistics ARGV[6]) goes into the
+// soft-delete index (member ARGV[2], score ARGV[7]) and is not counted; a
+// live one leaves it and is counted. ARGV[3..5] are the id, creation time
+// and title member of the message.
+var importScript = redis.NewScript(messageLua + importLua)
+
+// importIfScript is importScript applied only while the stored message is
+// still exactly ARGV[8] (empty when there should be none); it returns 0
+// otherwise. The migration copies with it, so a version that dual writes
+// stored after the copier looked is never overwritten with an older one.
+var importIfScript = redis.NewScript(messageLua + `
+if (redis.call('GET', KEYS[1]) or '') ~= ARGV[8] then
+  return 0
+end
+` + importLua)
+
+const importLua = `
+if redis.call('EXISTS', KEYS[1]) == 0 then
+  redis.call('INCR', KEYS[2])
+end
+redis.call('SET', KEYS[1], ARGV[1])
+index(ARGV[3], ARGV[4], ARGV[5])
+if ARGV[6] == '' then
+  redis.call('ZADD', KEYS[3], ARGV[7], ARGV[2])
+  unstat(ARGV[3])
+else
+  redis.call('ZREM', KEYS[3], ARGV[2])
+  stat(ARGV[3], ARGV[6])
+end
+return 1
+`
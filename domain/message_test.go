@@ -2,12 +2,16 @@ package domain_test
 
 import (
 	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
 	"github.com/go-redis/redismock/v8"
 	"github.com/stretchr/testify/assert"
 	"testing-project/domain"
+	"testing-project/utils/error_utils"
 )
 
 func TestGetMessage_Success(t *testing.T) {
@@ -42,6 +46,23 @@ func TestGetMessage_NotFound(t *testing.T) {
 
 	assert.Nil(t, result)
 	assert.Equal(t, "message not found", err.Message())
+	assert.True(t, errors.Is(err, error_utils.ErrNotFound))
+}
+
+func TestGetMessage_Redis_Error_Keeps_Cause(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	repo := domain.NewMessageRepository(db)
+	cause := errors.New("connection reset by peer")
+
+	mock.ExpectGet("message:1").SetErr(cause)
+
+	result, err := repo.Get(1)
+
+	assert.Nil(t, result)
+	assert.Equal(t, "redis get error", err.Message())
+	assert.True(t, errors.Is(err, cause))
+	assert.True(t, errors.Is(err, error_utils.ErrInternal))
+	assert.Equal(t, "connection reset by peer", err.Detail())
 }
 
 func TestGetAllMessages_Success(t *testing.T) {
@@ -79,8 +100,8 @@ func TestGetAllMessages_Empty(t *testing.T) {
 }
 
 func TestSaveMessage_Success(t *testing.T) {
-	db, mock := redismock.NewClientMock()
-	repo := domain.NewMessageRepository(db)
+	server := miniredis.RunT(t)
+	repo := domain.NewMessageRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}))
 
 	msg := &domain.Message{
 		Id:        10,
@@ -89,22 +110,322 @@ func TestSaveMessage_Success(t *testing.T) {
 		CreatedAt: time.Now(),
 	}
 	data, _ := json.Marshal(msg)
-	key := "message:10"
-
-	mock.ExpectSet(key, data, 0).SetVal("OK")
 
 	err := repo.Save(msg)
 
 	assert.Nil(t, err)
+	stored, _ := server.Get("message:10")
+	assert.Equal(t, string(data), stored)
+	count, _ := server.Get("message_count")
+	assert.Equal(t, "1", count)
+}
+
+func TestSaveMessage_Tenant_Quota(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewMessageRepository(redis.NewClient(&redis.Options{Addr: server.Addr()})).ForTenant("team-a")
+	assert.Nil(t, domain.InitializeQuotas("team-a=1"))
+	defer domain.InitializeQuotas("")
+
+	assert.Nil(t, repo.Save(&domain.Message{Id: 1, Title: "first"}))
+	assert.Nil(t, repo.Save(&domain.Message{Id: 1, Title: "first, updated"}))
+	err := repo.Save(&domain.Message{Id: 2, Title: "second"})
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "tenant message quota exceeded", err.Message())
+	assert.True(t, server.Exists("tenant:team-a:message:1"))
+	assert.False(t, server.Exists("tenant:team-a:message:2"))
+	assert.False(t, server.Exists("message:1"))
+}
+
+func TestRestore_Counts_Against_Quota_Until_Purged(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewMessageRepository(redis.NewClient(&redis.Options{Addr: server.Addr()})).ForTenant("team-a")
+	assert.Nil(t, domain.InitializeQuotas("team-a=1"))
+	defer domain.InitializeQuotas("")
+	assert.Nil(t, repo.Save(&domain.Message{Id: 1, Title: "first"}))
+	assert.Nil(t, repo.SoftDelete(1))
+
+	err := repo.Save(&domain.Message{Id: 2, Title: "second"})
+	assert.NotNil(t, err)
+	assert.Equal(t, "tenant message quota exceeded", err.Message())
+
+	restored, err := repo.Restore(1)
+	assert.Nil(t, err)
+	assert.False(t, restored.IsDeleted())
+	count, _ := server.Get("tenant:team-a:message_count")
+	assert.Equal(t, "1", count)
 }
 
 func TestDeleteMessage_Success(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewMessageRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	assert.Nil(t, repo.Save(&domain.Message{Id: 12, Title: "Hello"}))
+
+	err := repo.Delete(12)
+
+	assert.Nil(t, err)
+	assert.False(t, server.Exists("message:12"))
+	count, _ := server.Get("message_count")
+	assert.Equal(t, "0", count)
+	titles, _ := server.ZMembers("messages:by_title")
+	assert.Empty(t, titles)
+}
+
+func TestGetManyMessages_Success(t *testing.T) {
 	db, mock := redismock.NewClientMock()
 	repo := domain.NewMessageRepository(db)
 
-	mock.ExpectDel("message:12").SetVal(1)
+	msg := domain.Message{Id: 3, Title: "Title", Body: "Body"}
+	data, _ := json.Marshal(msg)
+
+	mock.ExpectMGet("message:3", "message:4").SetVal([]interface{}{string(data), nil})
 
-	err := repo.Delete(12)
+	result, err := repo.GetMany([]int64{3, 4})
+
+	assert.Nil(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, msg.Id, result[0].Id)
+}
+
+func TestGetMessage_Tenant_Scoped(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	repo := domain.NewMessageRepository(db).ForTenant("team-a")
+
+	mock.ExpectGet("tenant:team-a:message:1").RedisNil()
+	mock.ExpectKeys("tenant:team-a:message:*").SetVal([]string{})
+
+	result, err := repo.Get(1)
+	assert.Nil(t, result)
+	assert.Equal(t, "message not found", err.Message())
+
+	messages, err := repo.GetAll()
+	assert.Nil(t, messages)
+	assert.Equal(t, "no messages found", err.Message())
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestNormalizeTenant(t *testing.T) {
+	tenant, err := domain.NormalizeTenant("")
+	assert.Nil(t, err)
+	assert.Equal(t, domain.DefaultTenant, tenant)
+
+	tenant, err = domain.NormalizeTenant(" team-a ")
+	assert.Nil(t, err)
+	assert.Equal(t, "team-a", tenant)
+
+	_, err = domain.NormalizeTenant("team:a")
+	assert.Equal(t, "invalid tenant id", err.Message())
+}
+
+func TestSoftDeleteMessage_Restore_Purge(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewMessageRepository(redis.NewClient(&redis.Options{Addr: server.Addr()})).ForTenant("team-a")
+	assert.Nil(t, repo.Save(&domain.Message{Id: 1, Title: "first"}))
+	assert.Nil(t, repo.Save(&domain.Message{Id: 2, Title: "second"}))
+
+	assert.Nil(t, repo.SoftDelete(1))
+	assert.Nil(t, repo.SoftDelete(2))
+	assert.Nil(t, repo.SoftDelete(3))
+	server.Lpush("tenant:team-a:message_history:1", "{}")
+	server.Set("tenant:team-a:message_history_seq:1", "1")
+
+	deleted, err := repo.Get(1)
+	assert.Nil(t, err)
+	assert.True(t, deleted.IsDeleted())
+
+	restored, err := repo.Restore(2)
+	assert.Nil(t, err)
+	assert.False(t, restored.IsDeleted())
+
+	purged, err := repo.PurgeDeleted(time.Now().Add(time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string][]int64{"team-a": {1}}, purged)
+	assert.False(t, server.Exists("tenant:team-a:message:1"))
+	assert.True(t, server.Exists("tenant:team-a:message_history:1"))
+	assert.True(t, server.Exists("tenant:team-a:message_history_seq:1"))
+	assert.True(t, server.Exists("tenant:team-a:message:2"))
+	count, _ := server.Get("tenant:team-a:message_count")
+	assert.Equal(t, "1", count)
+}
+
+func TestPurgeDeleted_Keeps_Recent(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewMessageRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	assert.Nil(t, repo.Save(&domain.Message{Id: 1, Title: "first"}))
+	assert.Nil(t, repo.SoftDelete(1))
+
+	purged, err := repo.PurgeDeleted(time.Now().Add(-time.Hour))
+
+	assert.Nil(t, err)
+	assert.Empty(t, purged)
+	assert.True(t, server.Exists("message:1"))
+}
+
+func TestSaveMessage_Clears_Deleted(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewMessageRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	assert.Nil(t, repo.Save(&domain.Message{Id: 1, Title: "first"}))
+	assert.Nil(t, repo.SoftDelete(1))
+
+	assert.Nil(t, repo.Save(&domain.Message{Id: 1, Title: "first, again"}))
+	purged, err := repo.PurgeDeleted(time.Now().Add(time.Minute))
 
 	assert.Nil(t, err)
+	assert.Empty(t, purged)
+	assert.True(t, server.Exists("message:1"))
+}
+
+func TestFindMessages(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewMessageRepository(redis.NewClient(&redis.Options{Addr: server.Addr()})).ForTenant("team-a")
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Nil(t, repo.Save(&domain.Message{Id: 1, Title: "Release notes", CreatedAt: base}))
+	assert.Nil(t, repo.Save(&domain.Message{Id: 2, Title: "Weekly report", CreatedAt: base.Add(time.Hour)}))
+	assert.Nil(t, repo.Save(&domain.Message{Id: 3, Title: "Release plan", CreatedAt: base.Add(2 * time.Hour)}))
+	assert.Nil(t, repo.Save(&domain.Message{Id: 2, Title: "Release report", CreatedAt: base.Add(time.Hour)}))
+
+	ids := func(messages []domain.Message, err error_utils.MessageErr) []int64 {
+		assert.Nil(t, err)
+		result := make([]int64, 0, len(messages))
+		for _, msg := range messages {
+			result = append(result, msg.Id)
+		}
+		return result
+	}
+	after := base
+	before := base.Add(2 * time.Hour)
+
+	assert.ElementsMatch(t, []int64{1, 2, 3}, ids(repo.Find(domain.MessageFilter{TitlePrefix: "release"})))
+	assert.ElementsMatch(t, []int64{2}, ids(repo.Find(domain.MessageFilter{TitleContains: "REPORT"})))
+	assert.ElementsMatch(t, []int64{2}, ids(repo.Find(domain.MessageFilter{CreatedAfter: &after, CreatedBefore: &before})))
+	assert.ElementsMatch(t, []int64{3}, ids(repo.Find(domain.MessageFilter{Ids: []int64{3, 4}, TitlePrefix: "rel"})))
+	assert.Empty(t, ids(repo.Find(domain.MessageFilter{TitlePrefix: "weekly"})))
+}
+
+func TestEachMatching_Pages(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewMessageRepository(redis.NewClient(&redis.Options{Addr: server.Addr()})).ForTenant("team-a")
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var all []int64
+	for id := int64(1); id <= 250; id++ {
+		all = append(all, id)
+		assert.Nil(t, repo.Save(&domain.Message{Id: id, Title: "Release note", CreatedAt: base.Add(time.Duration(id) * time.Second)}))
+	}
+	after := base
+
+	walk := func(filter domain.MessageFilter) []int64 {
+		var ids []int64
+		err := repo.EachMatching(filter, func(msg *domain.Message) error {
+			ids = append(ids, msg.Id)
+			return nil
+		})
+		assert.Nil(t, err)
+		return ids
+	}
+
+	assert.ElementsMatch(t, all, walk(domain.MessageFilter{Ids: all}))
+	assert.ElementsMatch(t, all, walk(domain.MessageFilter{TitlePrefix: "release"}))
+	assert.ElementsMatch(t, all, walk(domain.MessageFilter{CreatedAfter: &after}))
+	assert.ElementsMatch(t, all, walk(domain.MessageFilter{TitleContains: "NOTE"}))
+
+	calls := 0
+	err := repo.EachMatching(domain.MessageFilter{TitlePrefix: "release"}, func(msg *domain.Message) error {
+		calls++
+		return errors.New("client went away")
+	})
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "client went away", err.Message())
+}
+
+func TestReindexMessages(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewMessageRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	data, _ := json.Marshal(domain.Message{Id: 4, Title: "Legacy"})
+	server.Set("message:4", string(data))
+	server.Set("tenant:team-a:message:5", string(data))
+
+	indexed, err := repo.Reindex()
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, indexed)
+	messages, err := repo.Find(domain.MessageFilter{TitlePrefix: "leg"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(messages))
+	assert.True(t, server.Exists("tenant:team-a:messages:by_title"))
+}
+
+func TestEachMessage(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewMessageRepository(redis.NewClient(&redis.Options{Addr: server.Addr()})).ForTenant("team-a")
+	for id := int64(1); id <= 250; id++ {
+		assert.Nil(t, repo.Save(&domain.Message{Id: id, Title: "title"}))
+	}
+	assert.Nil(t, domain.NewMessageRepository(redis.NewClient(&redis.Options{Addr: server.Addr()})).Save(&domain.Message{Id: 999}))
+
+	seen := make(map[int64]bool)
+	err := repo.Each(func(msg *domain.Message) error {
+		seen[msg.Id] = true
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 250, len(seen))
+	assert.False(t, seen[999])
+
+	calls := 0
+	err = repo.Each(func(msg *domain.Message) error {
+		calls++
+		return errors.New("client went away")
+	})
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "client went away", err.Message())
+}
+
+func TestEachByCreated(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewMessageRepository(redis.NewClient(&redis.Options{Addr: server.Addr()})).ForTenant("team-a")
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// More messages share a creation time than fit in one page.
+	for id := int64(1); id <= 250; id++ {
+		created := base
+		if id > 150 {
+			created = base.Add(time.Duration(id) * time.Millisecond)
+		}
+		assert.Nil(t, repo.Save(&domain.Message{Id: id, Title: "title", Body: "body", CreatedAt: created}))
+	}
+	assert.Nil(t, repo.Save(&domain.Message{Id: 300, Title: "title", Body: "needle", CreatedAt: base.Add(time.Hour)}))
+
+	walk := func(filter domain.MessageFilter) []domain.Message {
+		var messages []domain.Message
+		err := repo.EachByCreated(filter, func(msg *domain.Message) error {
+			messages = append(messages, *msg)
+			return nil
+		})
+		assert.Nil(t, err)
+		return messages
+	}
+
+	all := walk(domain.MessageFilter{})
+	assert.Equal(t, 251, len(all))
+	for i := 1; i < len(all); i++ {
+		assert.True(t, domain.PositionOf(&all[i-1]).Before(domain.PositionOf(&all[i])))
+	}
+
+	after := domain.PositionOf(&all[119])
+	rest := walk(domain.MessageFilter{After: &after})
+	assert.Equal(t, all[120:], rest)
+
+	found := walk(domain.MessageFilter{Search: "NEEDLE"})
+	assert.Len(t, found, 1)
+	assert.EqualValues(t, 300, found[0].Id)
+
+	createdAfter := base.Add(200 * time.Millisecond)
+	assert.Equal(t, 51, len(walk(domain.MessageFilter{CreatedAfter: &createdAfter})))
+
+	calls := 0
+	err := repo.EachByCreated(domain.MessageFilter{}, func(msg *domain.Message) error {
+		calls++
+		return errors.New("page full")
+	})
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "page full", err.Message())
 }
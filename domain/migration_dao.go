@@ -0,0 +1,504 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"log"
+	"sync"
+	"sync/atomic"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+// maxDriftSamples bounds how many differences a MigrationReport lists.
+const maxDriftSamples = 100
+
+// migrationVerifiedKey holds, on the primary, when a verification last found
+// both stores in sync. Drift found later or a failed mirrored write clears
+// it, and reads can only be switched to the secondary while it is set.
+const migrationVerifiedKey = "migration:verified_at"
+
+// migrationReadFromKey holds, on the primary, the store every replica reads
+// messages from once SetReadFrom chose one. It outlives restarts and wins
+// over the configured source.
+const migrationReadFromKey = "migration:read_from"
+
+// readFromRefresh is how often a replica rereads migrationReadFromKey.
+const readFromRefresh = 5 * time.Second
+
+var (
+	Migration migrationInterface = &migration{}
+)
+
+type migrationInterface interface {
+	Initialize(primary, secondary *redis.Client, readFrom string) error
+	Enabled() bool
+	Repository() messageRepoInterface
+	SetReadFrom(string) error_utils.MessageErr
+	StartBackfill() error_utils.MessageErr
+	Verify() (*MigrationReport, error_utils.MessageErr)
+	Status() MigrationStatus
+}
+
+// migration moves the read model from the primary Redis to the secondary
+// without downtime: every write goes to both (see migratingRepo), a backfill
+// copies what the primary held before, a verification reports where the two
+// still differ, and reads are switched over once they do not. Only messages,
+// with their indexes, statistics and counts, move. History, the change feed,
+// webhooks and their pending retries, processed event ids and rate limits
+// stay on the primary, which therefore stays in service after reads are
+// switched: the migration cannot retire it.
+type migration struct {
+	primary                *messageRepo
+	secondary              *messageRepo
+	configuredReadFrom     string
+	readSecondary          atomic.Bool
+	readFromRefreshedAt    atomic.Int64
+	secondaryWriteFailures atomic.Int64
+
+	mu         sync.Mutex
+	backfill   BackfillProgress
+	lastReport *MigrationReport
+}
+
+func NewMigration(primary, secondary *redis.Client, readFrom string) (migrationInterface, error) {
+	m := &migration{}
+	return m, m.Initialize(primary, secondary, readFrom)
+}
+
+// Initialize turns migration mode on. readFrom, MigrationReadPrimary or
+// MigrationReadSecondary, is where reads come from until SetReadFrom stores
+// a source for every replica.
+func (m *migration) Initialize(primary, secondary *redis.Client, readFrom string) error {
+	if err := checkReadSource(readFrom); err != nil {
+		return fmt.Errorf("%s", err.Message())
+	}
+	m.primary = &messageRepo{client: primary, tenant: DefaultTenant}
+	m.secondary = &messageRepo{client: secondary, tenant: DefaultTenant}
+	m.configuredReadFrom = readFrom
+	m.readSecondary.Store(readFrom == MigrationReadSecondary)
+	m.readFromRefreshedAt.Store(time.Now().UnixNano())
+	if err := m.refreshReadFrom(); err != nil {
+		log.Printf("Failed to read the migration read source: %s: %s", err.Message(), err.Detail())
+	}
+	log.Printf("Reading messages from the %s Redis", m.readFrom())
+	return nil
+}
+
+func (m *migration) Enabled() bool {
+	return m.primary != nil
+}
+
+// Repository is the message repository that writes to both stores and reads
+// from the chosen one.
+func (m *migration) Repository() messageRepoInterface {
+	return &migratingRepo{migration: m, primary: m.primary, secondary: m.secondary}
+}
+
+// SetReadFrom switches the store reads are served from. The source is stored
+// on the primary, which every replica rereads within readFromRefresh and on
+// startup. Reads only move to the secondary once a verification found it in
+// sync.
+func (m *migration) SetReadFrom(source string) error_utils.MessageErr {
+	if err := checkReadSource(source); err != nil {
+		return err
+	}
+	if source == MigrationReadSecondary {
+		verifiedAt, err := m.verifiedAt()
+		if err != nil {
+			return err
+		}
+		if verifiedAt == nil {
+			return error_utils.NewConflictError("the secondary has not been verified in sync with the primary").WithCode(error_utils.CodeMigrationNotVerified)
+		}
+	}
+	if err := m.primary.client.Set(ctx, migrationReadFromKey, source, 0).Err(); err != nil {
+		return error_formats.Translate(err, "redis migration")
+	}
+	m.readSecondary.Store(source == MigrationReadSecondary)
+	log.Printf("Reading messages from the %s Redis", source)
+	return nil
+}
+
+func checkReadSource(source string) error_utils.MessageErr {
+	if source != MigrationReadPrimary && source != MigrationReadSecondary {
+		return error_utils.NewBadRequestError("read source should be primary or secondary").WithCode(error_utils.CodeInvalidReadSource)
+	}
+	return nil
+}
+
+// refreshReadFrom loads the read source stored on the primary. The
+// configured one applies while none is stored.
+func (m *migration) refreshReadFrom() error_utils.MessageErr {
+	source, err := m.primary.client.Get(ctx, migrationReadFromKey).Result()
+	if err == redis.Nil {
+		source = m.configuredReadFrom
+	} else if err != nil {
+		return error_formats.Translate(err, "redis migration")
+	}
+	readSecondary := source == MigrationReadSecondary
+	if m.readSecondary.Swap(readSecondary) != readSecondary {
+		log.Printf("Reading messages from the %s Redis", source)
+	}
+	return nil
+}
+
+// readsSecondary tells whether reads come from the secondary, rereading the
+// stored source once readFromRefresh has passed. A single caller rereads it
+// while the others go on with the source known so far.
+func (m *migration) readsSecondary() bool {
+	last, now := m.readFromRefreshedAt.Load(), time.Now().UnixNano()
+	if now-last >= int64(readFromRefresh) && m.readFromRefreshedAt.CompareAndSwap(last, now) {
+		if err := m.refreshReadFrom(); err != nil {
+			log.Printf("Failed to reread the migration read source: %s: %s", err.Message(), err.Detail())
+		}
+	}
+	return m.readSecondary.Load()
+}
+
+func (m *migration) readFrom() string {
+	if m.readSecondary.Load() {
+		return MigrationReadSecondary
+	}
+	return MigrationReadPrimary
+}
+
+// StartBackfill copies every message of the primary to the secondary in the
+// background. Only one backfill runs at a time.
+func (m *migration) StartBackfill() error_utils.MessageErr {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.backfill.Running {
+		return error_utils.NewConflictError("a backfill is already running").WithCode(error_utils.CodeBackfillRunning)
+	}
+	startedAt := time.Now().UTC()
+	m.backfill = BackfillProgress{Running: true, StartedAt: &startedAt}
+	go m.runBackfill()
+	return nil
+}
+
+func (m *migration) runBackfill() {
+	err := m.primary.eachStored(func(tenant string, msg *Message) error_utils.MessageErr {
+		copied, copyErr := m.copy(tenant, msg)
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		switch {
+		case copyErr != nil:
+			m.backfill.Failed++
+			log.Printf("Failed to copy message %s:%d: %s: %s", tenant, msg.Id, copyErr.Message(), copyErr.Detail())
+		case copied:
+			m.backfill.Copied++
+		default:
+			m.backfill.Skipped++
+		}
+		return nil
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	finishedAt := time.Now().UTC()
+	m.backfill.Running = false
+	m.backfill.FinishedAt = &finishedAt
+	if err != nil {
+		m.backfill.Error = err.Message()
+		log.Printf("Backfill failed: %s: %s", err.Message(), err.Detail())
+		return
+	}
+	log.Printf("Backfill finished: %d copied, %d skipped, %d failed", m.backfill.Copied, m.backfill.Skipped, m.backfill.Failed)
+}
+
+// copy puts msg on the secondary unless dual writes already have, and then
+// reads the primary again: an event applied between the two reads left a
+// newer version there, which replaces the copy. Both writes to the secondary
+// only apply while it holds what the copier expects, so a version that dual
+// writes stored meanwhile, which is newer still, is never overwritten.
+func (m *migration) copy(tenant string, msg *Message) (bool, error_utils.MessageErr) {
+	primary := m.primary.ForTenant(tenant).(*messageRepo)
+	secondary := m.secondary.ForTenant(tenant).(*messageRepo)
+	copied, err := secondary.importMessageIf(msg, "")
+	if err != nil || !copied {
+		return false, err
+	}
+	data, marshalErr := json.Marshal(msg)
+	if marshalErr != nil {
+		return true, error_formats.Translate(marshalErr, "json marshal")
+	}
+
+	current, err := primary.Get(msg.Id)
+	if errors.Is(err, error_utils.ErrNotFound) {
+		return true, secondary.deleteIf(msg.Id, string(data))
+	} else if err != nil {
+		return true, err
+	}
+	if len(DiffMessages(current, msg)) > 0 {
+		_, err := secondary.importMessageIf(current, string(data))
+		return true, err
+	}
+	return true, nil
+}
+
+// Verify compares every message of both stores. Writes applied while it
+// runs can show up as drift, so drift is worth confirming with a second run.
+func (m *migration) Verify() (*MigrationReport, error_utils.MessageErr) {
+	report := &MigrationReport{Drift: []MessageDrift{}, StartedAt: time.Now().UTC()}
+	err := m.primary.eachStored(func(tenant string, msg *Message) error_utils.MessageErr {
+		report.Checked++
+		other, getErr := m.secondary.ForTenant(tenant).Get(msg.Id)
+		if errors.Is(getErr, error_utils.ErrNotFound) {
+			report.add(MessageDrift{Tenant: tenant, MessageId: msg.Id, Kind: DriftMissing})
+			return nil
+		} else if getErr != nil {
+			return getErr
+		}
+		if fields := DiffMessages(msg, other); len(fields) > 0 {
+			report.add(MessageDrift{Tenant: tenant, MessageId: msg.Id, Kind: DriftMismatched, Fields: fields})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = m.secondary.eachStored(func(tenant string, msg *Message) error_utils.MessageErr {
+		_, getErr := m.primary.ForTenant(tenant).Get(msg.Id)
+		if errors.Is(getErr, error_utils.ErrNotFound) {
+			report.Checked++
+			report.add(MessageDrift{Tenant: tenant, MessageId: msg.Id, Kind: DriftExtra})
+			return nil
+		}
+		return getErr
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report.InSync = report.Missing+report.Extra+report.Mismatched == 0
+	report.FinishedAt = time.Now().UTC()
+	var recordErr error
+	if report.InSync {
+		recordErr = m.primary.client.Set(ctx, migrationVerifiedKey, report.FinishedAt.Format(time.RFC3339Nano), 0).Err()
+	} else {
+		recordErr = m.primary.client.Del(ctx, migrationVerifiedKey).Err()
+	}
+	if recordErr != nil {
+		return nil, error_formats.Translate(recordErr, "redis migration")
+	}
+	log.Printf("Migration verified: %d checked, %d missing, %d extra, %d mismatched", report.Checked, report.Missing, report.Extra, report.Mismatched)
+	m.mu.Lock()
+	m.lastReport = report
+	m.mu.Unlock()
+	return report, nil
+}
+
+func (r *MigrationReport) add(drift MessageDrift) {
+	switch drift.Kind {
+	case DriftMissing:
+		r.Missing++
+	case DriftExtra:
+		r.Extra++
+	case DriftMismatched:
+		r.Mismatched++
+	}
+	if len(r.Drift) < maxDriftSamples {
+		r.Drift = append(r.Drift, drift)
+	}
+}
+
+// verifiedAt returns when a verification last found both stores in sync,
+// nil when none did since they last differed.
+func (m *migration) verifiedAt() (*time.Time, error_utils.MessageErr) {
+	value, err := m.primary.client.Get(ctx, migrationVerifiedKey).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, error_formats.Translate(err, "redis migration")
+	}
+	verifiedAt, parseErr := time.Parse(time.RFC3339Nano, value)
+	if parseErr != nil {
+		return nil, nil
+	}
+	return &verifiedAt, nil
+}
+
+func (m *migration) Status() MigrationStatus {
+	if !m.Enabled() {
+		return MigrationStatus{}
+	}
+	verifiedAt, err := m.verifiedAt()
+	if err != nil {
+		log.Printf("Failed to read the migration verification: %s: %s", err.Message(), err.Detail())
+	}
+	if err := m.refreshReadFrom(); err != nil {
+		log.Printf("Failed to read the migration read source: %s: %s", err.Message(), err.Detail())
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return MigrationStatus{
+		Enabled:                true,
+		ReadFrom:               m.readFrom(),
+		VerifiedAt:             verifiedAt,
+		SecondaryWriteFailures: m.secondaryWriteFailures.Load(),
+		Backfill:               m.backfill,
+		LastVerification:       m.lastReport,
+	}
+}
+
+// migratingRepo is the message repository of one tenant in migration mode.
+// Writes go to the primary first and are then mirrored to the secondary
+// exactly, so both hold the same bytes; reads go to the store chosen with
+// SetReadFrom.
+type migratingRepo struct {
+	migration *migration
+	primary   *messageRepo
+	secondary *messageRepo
+}
+
+func (r *migratingRepo) ForTenant(tenant string) messageRepoInterface {
+	return &migratingRepo{
+		migration: r.migration,
+		primary:   r.primary.ForTenant(tenant).(*messageRepo),
+		secondary: r.secondary.ForTenant(tenant).(*messageRepo),
+	}
+}
+
+func (r *migratingRepo) reader() *messageRepo {
+	if r.migration.readsSecondary() {
+		return r.secondary
+	}
+	return r.primary
+}
+
+// mirror handles the result of a write to the secondary. While reads come
+// from the primary, a failed write is counted and logged and left for the
+// verification to report; once reads come from the secondary, it fails the
+// write.
+func (r *migratingRepo) mirror(operation string, err error_utils.MessageErr) error_utils.MessageErr {
+	if err == nil {
+		return nil
+	}
+	r.migration.secondaryWriteFailures.Add(1)
+	// The stores differ now, so they have to be verified again.
+	r.migration.primary.client.Del(ctx, migrationVerifiedKey)
+	log.Printf("Failed to %s on the secondary Redis: %s: %s", operation, err.Message(), err.Detail())
+	if r.migration.readsSecondary() {
+		return err
+	}
+	return nil
+}
+
+// sync copies the primary's version of a message to the secondary, or
+// removes it there when the primary no longer has it. The secondary is read
+// first and only changed while it still holds that, so a write mirrored
+// after the primary was read is not undone.
+func (r *migratingRepo) sync(messageId int64) error_utils.MessageErr {
+	previous, err := r.secondary.raw(messageId)
+	if err != nil {
+		return err
+	}
+	msg, err := r.primary.Get(messageId)
+	if errors.Is(err, error_utils.ErrNotFound) {
+		return r.secondary.deleteIf(messageId, previous)
+	} else if err != nil {
+		return err
+	}
+	_, err = r.secondary.importMessageIf(msg, previous)
+	return err
+}
+
+func (r *migratingRepo) Get(messageId int64) (*Message, error_utils.MessageErr) {
+	return r.reader().Get(messageId)
+}
+
+func (r *migratingRepo) GetAll() ([]Message, error_utils.MessageErr) {
+	return r.reader().GetAll()
+}
+
+func (r *migratingRepo) Each(fn func(*Message) error) error_utils.MessageErr {
+	return r.reader().Each(fn)
+}
+
+func (r *migratingRepo) EachByCreated(filter MessageFilter, fn func(*Message) error) error_utils.MessageErr {
+	return r.reader().EachByCreated(filter, fn)
+}
+
+func (r *migratingRepo) GetMany(messageIds []int64) ([]Message, error_utils.MessageErr) {
+	return r.reader().GetMany(messageIds)
+}
+
+func (r *migratingRepo) Find(filter MessageFilter) ([]Message, error_utils.MessageErr) {
+	return r.reader().Find(filter)
+}
+
+func (r *migratingRepo) EachMatching(filter MessageFilter, fn func(*Message) error) error_utils.MessageErr {
+	return r.reader().EachMatching(filter, fn)
+}
+
+// Save applies the primary's quota; the secondary takes the saved message
+// as it is.
+func (r *migratingRepo) Save(msg *Message) error_utils.MessageErr {
+	if err := r.primary.Save(msg); err != nil {
+		return err
+	}
+	return r.mirror("save", r.secondary.importMessage(msg))
+}
+
+// SaveIfAbsent decides on the primary; the secondary follows it when the
+// message was saved.
+func (r *migratingRepo) SaveIfAbsent(msg *Message) (bool, error_utils.MessageErr) {
+	saved, err := r.primary.SaveIfAbsent(msg)
+	if err != nil || !saved {
+		return saved, err
+	}
+	return true, r.mirror("save", r.secondary.importMessage(msg))
+}
+
+func (r *migratingRepo) Delete(messageId int64) error_utils.MessageErr {
+	if err := r.primary.Delete(messageId); err != nil {
+		return err
+	}
+	return r.mirror("delete", r.secondary.Delete(messageId))
+}
+
+// SoftDelete copies the primary's result, so both stores keep the same
+// deletion time.
+func (r *migratingRepo) SoftDelete(messageId int64) error_utils.MessageErr {
+	if err := r.primary.SoftDelete(messageId); err != nil {
+		return err
+	}
+	return r.mirror("soft delete", r.sync(messageId))
+}
+
+func (r *migratingRepo) Restore(messageId int64) (*Message, error_utils.MessageErr) {
+	msg, err := r.primary.Restore(messageId)
+	if err != nil {
+		return nil, err
+	}
+	return msg, r.mirror("restore", r.secondary.importMessage(msg))
+}
+
+// PurgeDeleted purges both stores, which hold the same deletion times.
+func (r *migratingRepo) PurgeDeleted(before time.Time) (map[string][]int64, error_utils.MessageErr) {
+	purged, err := r.primary.PurgeDeleted(before)
+	if err != nil {
+		return purged, err
+	}
+	_, err = r.secondary.PurgeDeleted(before)
+	return purged, r.mirror("purge", err)
+}
+
+func (r *migratingRepo) Reindex() (int, error_utils.MessageErr) {
+	indexed, err := r.primary.Reindex()
+	if err != nil {
+		return indexed, err
+	}
+	_, err = r.secondary.Reindex()
+	return indexed, r.mirror("reindex", err)
+}
+
+// Initialize is not used in migration mode; both clients are connected
+// before the migration is initialized.
+func (r *migratingRepo) Initialize(addr, password, db string) *redis.Client {
+	return r.primary.client
+}
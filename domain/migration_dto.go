@@ -0,0 +1,66 @@
+package domain
+
+import "time"
+
+const (
+	MigrationReadPrimary   = "primary"
+	MigrationReadSecondary = "secondary"
+)
+
+// The kinds of drift a verification finds.
+const (
+	DriftMissing    = "missing"
+	DriftExtra      = "extra"
+	DriftMismatched = "mismatched"
+)
+
+// MigrationStatus describes the migration of the read model to another
+// Redis as this replica sees it.
+type MigrationStatus struct {
+	Enabled                bool             `json:"enabled"`
+	ReadFrom               string           `json:"read_from,omitempty"`
+	VerifiedAt             *time.Time       `json:"verified_at,omitempty"`
+	SecondaryWriteFailures int64            `json:"secondary_write_failures"`
+	Backfill               BackfillProgress `json:"backfill"`
+	LastVerification       *MigrationReport `json:"last_verification,omitempty"`
+}
+
+// BackfillProgress counts the messages the copier has gone through: copied
+// to the secondary, skipped because dual writes had already put them there,
+// and failed.
+type BackfillProgress struct {
+	Running    bool       `json:"running"`
+	Copied     int64      `json:"copied"`
+	Skipped    int64      `json:"skipped"`
+	Failed     int64      `json:"failed"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// MigrationReport is the outcome of comparing the primary with the
+// secondary. Drift lists at most maxDriftSamples of the differences; the
+// counts cover all of them.
+type MigrationReport struct {
+	Checked    int            `json:"checked"`
+	Missing    int            `json:"missing"`
+	Extra      int            `json:"extra"`
+	Mismatched int            `json:"mismatched"`
+	InSync     bool           `json:"in_sync"`
+	Drift      []MessageDrift `json:"drift"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt time.Time      `json:"finished_at"`
+}
+
+// MessageDrift is one message that differs between two stores: missing from
+// the one being checked, extra on it, or stored on both with different
+// Fields. Repaired and RepairError are only set by a reconciliation run
+// with repairs.
+type MessageDrift struct {
+	Tenant      string   `json:"tenant"`
+	MessageId   int64    `json:"message_id"`
+	Kind        string   `json:"kind"`
+	Fields      []string `json:"fields,omitempty"`
+	Repaired    bool     `json:"repaired,omitempty"`
+	RepairError string   `json:"repair_error,omitempty"`
+}
@@ -0,0 +1,142 @@
+package domain_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+func newMigration(t *testing.T, readFrom string) (domain.MessageRepository, domain.MessageRepository, domain.MessageRepository, *miniredis.Miniredis) {
+	primaryServer, secondaryServer := miniredis.RunT(t), miniredis.RunT(t)
+	primary := redis.NewClient(&redis.Options{Addr: primaryServer.Addr()})
+	secondary := redis.NewClient(&redis.Options{Addr: secondaryServer.Addr()})
+	migration, err := domain.NewMigration(primary, secondary, readFrom)
+	assert.Nil(t, err)
+	return migration.Repository(), domain.NewMessageRepository(primary), domain.NewMessageRepository(secondary), secondaryServer
+}
+
+func TestMigration_Dual_Writes(t *testing.T) {
+	repo, primary, secondary, secondaryServer := newMigration(t, domain.MigrationReadPrimary)
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	tenantRepo := repo.ForTenant("team-a")
+
+	assert.Nil(t, tenantRepo.Save(&domain.Message{Id: 1, Title: "first", Body: "body", CreatedAt: created}))
+	assert.Nil(t, tenantRepo.Save(&domain.Message{Id: 2, Title: "second", Body: "body", CreatedAt: created}))
+	assert.Nil(t, tenantRepo.SoftDelete(1))
+	assert.Nil(t, tenantRepo.Delete(2))
+
+	fromPrimary, _ := primary.ForTenant("team-a").Get(1)
+	fromSecondary, err := secondary.ForTenant("team-a").Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, fromPrimary, fromSecondary)
+	assert.True(t, fromSecondary.IsDeleted())
+	assert.False(t, secondaryServer.Exists("tenant:team-a:message:2"))
+	count, _ := secondaryServer.Get("tenant:team-a:message_count")
+	assert.Equal(t, "1", count)
+}
+
+func TestMigration_Read_Switch(t *testing.T) {
+	primaryServer, secondaryServer := miniredis.RunT(t), miniredis.RunT(t)
+	primary := redis.NewClient(&redis.Options{Addr: primaryServer.Addr()})
+	secondary := redis.NewClient(&redis.Options{Addr: secondaryServer.Addr()})
+	migration, _ := domain.NewMigration(primary, secondary, domain.MigrationReadPrimary)
+	assert.Nil(t, domain.NewMessageRepository(secondary).Save(&domain.Message{Id: 1, Title: "only on the secondary"}))
+	repo := migration.Repository()
+
+	_, err := repo.Get(1)
+	assert.True(t, errors.Is(err, error_utils.ErrNotFound))
+
+	// Reads only move once a verification found the stores in sync.
+	assert.Equal(t, error_utils.CodeMigrationNotVerified, migration.SetReadFrom(domain.MigrationReadSecondary).Code())
+	report, _ := migration.Verify()
+	assert.False(t, report.InSync)
+	assert.Nil(t, migration.Status().VerifiedAt)
+	assert.Nil(t, domain.NewMessageRepository(primary).Save(&domain.Message{Id: 1, Title: "only on the secondary"}))
+	report, _ = migration.Verify()
+	assert.True(t, report.InSync)
+	assert.NotNil(t, migration.Status().VerifiedAt)
+	assert.Nil(t, domain.NewMessageRepository(primary).Delete(1))
+
+	assert.Nil(t, migration.SetReadFrom(domain.MigrationReadSecondary))
+	msg, err := repo.Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "only on the secondary", msg.Title)
+	assert.Equal(t, domain.MigrationReadSecondary, migration.Status().ReadFrom)
+
+	assert.Equal(t, error_utils.CodeInvalidReadSource, migration.SetReadFrom("tertiary").Code())
+}
+
+func TestMigration_Read_Switch_Is_Shared(t *testing.T) {
+	primaryServer, secondaryServer := miniredis.RunT(t), miniredis.RunT(t)
+	primary := redis.NewClient(&redis.Options{Addr: primaryServer.Addr()})
+	secondary := redis.NewClient(&redis.Options{Addr: secondaryServer.Addr()})
+	switched, _ := domain.NewMigration(primary, secondary, domain.MigrationReadPrimary)
+	other, _ := domain.NewMigration(primary, secondary, domain.MigrationReadPrimary)
+	assert.Nil(t, domain.NewMessageRepository(secondary).Save(&domain.Message{Id: 1, Title: "only on the secondary"}))
+	assert.Nil(t, domain.NewMessageRepository(primary).Save(&domain.Message{Id: 1, Title: "only on the secondary"}))
+	report, _ := switched.Verify()
+	assert.True(t, report.InSync)
+	assert.Nil(t, domain.NewMessageRepository(primary).Delete(1))
+
+	assert.Nil(t, switched.SetReadFrom(domain.MigrationReadSecondary))
+
+	// The other replica follows the stored source, and so does a restarted
+	// one configured to read from the primary.
+	assert.Equal(t, domain.MigrationReadSecondary, other.Status().ReadFrom)
+	msg, err := other.Repository().Get(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "only on the secondary", msg.Title)
+	restarted, _ := domain.NewMigration(primary, secondary, domain.MigrationReadPrimary)
+	_, err = restarted.Repository().Get(1)
+	assert.Nil(t, err)
+
+	assert.Nil(t, other.SetReadFrom(domain.MigrationReadPrimary))
+	assert.Equal(t, domain.MigrationReadPrimary, switched.Status().ReadFrom)
+}
+
+func TestMigration_Backfill_And_Verify(t *testing.T) {
+	primaryServer, secondaryServer := miniredis.RunT(t), miniredis.RunT(t)
+	primary := redis.NewClient(&redis.Options{Addr: primaryServer.Addr()})
+	secondary := redis.NewClient(&redis.Options{Addr: secondaryServer.Addr()})
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	primaryRepo := domain.NewMessageRepository(primary)
+	secondaryRepo := domain.NewMessageRepository(secondary)
+	assert.Nil(t, primaryRepo.Save(&domain.Message{Id: 1, Title: "one", Body: "body", CreatedAt: created}))
+	assert.Nil(t, primaryRepo.ForTenant("team-a").Save(&domain.Message{Id: 2, Title: "two", Body: "body", CreatedAt: created}))
+	assert.Nil(t, primaryRepo.Save(&domain.Message{Id: 3, Title: "three", Body: "body", CreatedAt: created}))
+	assert.Nil(t, primaryRepo.SoftDelete(3))
+	assert.Nil(t, secondaryRepo.Save(&domain.Message{Id: 1, Title: "one, edited", Body: "body", CreatedAt: created}))
+	assert.Nil(t, secondaryRepo.Save(&domain.Message{Id: 4, Title: "stray", Body: "body", CreatedAt: created}))
+	migration, _ := domain.NewMigration(primary, secondary, domain.MigrationReadPrimary)
+
+	report, err := migration.Verify()
+	assert.Nil(t, err)
+	assert.False(t, report.InSync)
+	assert.Equal(t, 4, report.Checked)
+	assert.Equal(t, 2, report.Missing)
+	assert.Equal(t, 1, report.Extra)
+	assert.Equal(t, 1, report.Mismatched)
+	assert.Contains(t, report.Drift, domain.MessageDrift{Tenant: domain.DefaultTenant, MessageId: 1, Kind: domain.DriftMismatched, Fields: []string{"title"}})
+	assert.Contains(t, report.Drift, domain.MessageDrift{Tenant: "team-a", MessageId: 2, Kind: domain.DriftMissing})
+
+	assert.Nil(t, migration.StartBackfill())
+	assert.Eventually(t, func() bool { return !migration.Status().Backfill.Running }, time.Second, 10*time.Millisecond)
+	progress := migration.Status().Backfill
+	assert.EqualValues(t, 2, progress.Copied)
+	assert.EqualValues(t, 1, progress.Skipped)
+	assert.Empty(t, progress.Error)
+
+	copied, _ := secondaryRepo.Get(3)
+	original, _ := primaryRepo.Get(3)
+	assert.Equal(t, original, copied)
+	report, _ = migration.Verify()
+	assert.Equal(t, 1, report.Mismatched)
+	assert.Equal(t, 1, report.Extra)
+	assert.Equal(t, report, migration.Status().LastVerification)
+}
@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"github.com/go-redis/redis/v8"
+	"strconv"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+// processedEventsKey is a sorted set of the keys of the events applied
+// within the dedup window, scored by when they were applied.
+const processedEventsKey = "events:processed"
+
+var (
+	ProcessedEvents processedEventsInterface = &processedEvents{}
+)
+
+type processedEventsInterface interface {
+	Seen(string) (bool, error_utils.MessageErr)
+	Mark(string) error_utils.MessageErr
+	Enabled() bool
+	Initialize(*redis.Client, time.Duration)
+}
+
+// processedEvents remembers the events the consumer applied for window, so
+// redeliveries of them can be skipped. A window of zero turns it off.
+type processedEvents struct {
+	client *redis.Client
+	window time.Duration
+}
+
+func (pe *processedEvents) Initialize(client *redis.Client, window time.Duration) {
+	pe.client = client
+	pe.window = window
+}
+
+func NewProcessedEvents(client *redis.Client, window time.Duration) processedEventsInterface {
+	return &processedEvents{client: client, window: window}
+}
+
+func (pe *processedEvents) Enabled() bool {
+	return pe.client != nil && pe.window > 0
+}
+
+// Seen reports whether the event with key was applied within the window.
+func (pe *processedEvents) Seen(key string) (bool, error_utils.MessageErr) {
+	if !pe.Enabled() {
+		return false, nil
+	}
+	score, err := pe.client.ZScore(ctx, processedEventsKey, key).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, error_formats.Translate(err, "redis processed events score")
+	}
+	return time.UnixMilli(int64(score)).After(time.Now().Add(-pe.window)), nil
+}
+
+// Mark records that the event with key was applied, and forgets the events
+// applied before the window.
+func (pe *processedEvents) Mark(key string) error_utils.MessageErr {
+	if !pe.Enabled() {
+		return nil
+	}
+	now := time.Now()
+	_, err := pe.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, processedEventsKey, &redis.Z{Score: float64(now.UnixMilli()), Member: key})
+		pipe.ZRemRangeByScore(ctx, processedEventsKey, "-inf", "("+strconv.FormatInt(now.Add(-pe.window).UnixMilli(), 10))
+		return nil
+	})
+	if err != nil {
+		return error_formats.Translate(err, "redis processed events mark")
+	}
+	return nil
+}
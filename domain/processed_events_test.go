@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestProcessedEvents_Seen_After_Mark(t *testing.T) {
+	server := miniredis.RunT(t)
+	processed := NewProcessedEvents(redis.NewClient(&redis.Options{Addr: server.Addr()}), time.Hour)
+
+	seen, err := processed.Seen("id:event-1")
+	assert.Nil(t, err)
+	assert.False(t, seen)
+
+	assert.Nil(t, processed.Mark("id:event-1"))
+	seen, err = processed.Seen("id:event-1")
+	assert.Nil(t, err)
+	assert.True(t, seen)
+}
+
+func TestProcessedEvents_Forgets_Events_Outside_Window(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	processed := NewProcessedEvents(client, time.Hour)
+	old := float64(time.Now().Add(-2 * time.Hour).UnixMilli())
+	client.ZAdd(ctx, processedEventsKey, &redis.Z{Score: old, Member: "id:old"})
+
+	seen, err := processed.Seen("id:old")
+	assert.Nil(t, err)
+	assert.False(t, seen)
+
+	assert.Nil(t, processed.Mark("id:new"))
+	members, _ := client.ZRange(ctx, processedEventsKey, 0, -1).Result()
+	assert.Equal(t, []string{"id:new"}, members)
+}
+
+func TestProcessedEvents_Disabled(t *testing.T) {
+	server := miniredis.RunT(t)
+	processed := NewProcessedEvents(redis.NewClient(&redis.Options{Addr: server.Addr()}), 0)
+
+	assert.Nil(t, processed.Mark("id:event-1"))
+	seen, err := processed.Seen("id:event-1")
+	assert.Nil(t, err)
+	assert.False(t, seen)
+	assert.False(t, server.Exists(processedEventsKey))
+}
@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"strconv"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+var (
+	RateLimitRepo rateLimitRepoInterface = &rateLimitRepo{}
+)
+
+// takeTokenScript implements a token bucket that refills continuously. It
+// uses the Redis clock so that every replica agrees on the time, and returns
+// {allowed, remaining tokens, ms until next token, ms until the bucket is full}.
+var takeTokenScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+  tokens = capacity
+  ts = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate))
+
+local retry = 0
+if tokens < 1 then
+  retry = math.ceil((1 - tokens) / rate)
+end
+return {allowed, math.floor(tokens), retry, math.ceil((capacity - tokens) / rate)}
+`)
+
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int64
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+type rateLimitRepoInterface interface {
+	Take(string, int64, float64) (*RateLimitResult, error_utils.MessageErr)
+	Initialize(*redis.Client)
+}
+
+type rateLimitRepo struct {
+	client *redis.Client
+}
+
+func (rr *rateLimitRepo) Initialize(client *redis.Client) {
+	rr.client = client
+}
+
+func NewRateLimitRepository(client *redis.Client) rateLimitRepoInterface {
+	return &rateLimitRepo{client: client}
+}
+
+// Take removes one token from the bucket stored under key. The bucket holds
+// at most capacity tokens and regains perSecond tokens every second.
+func (rr *rateLimitRepo) Take(key string, capacity int64, perSecond float64) (*RateLimitResult, error_utils.MessageErr) {
+	perMs := strconv.FormatFloat(perSecond/1000, 'f', -1, 64)
+	values, err := takeTokenScript.Run(ctx, rr.client, []string{fmt.Sprintf("ratelimit:%s", key)}, capacity, perMs).Int64Slice()
+	if err != nil {
+		return nil, error_formats.Translate(err, "redis rate limit")
+	}
+	if len(values) != 4 {
+		return nil, error_utils.NewInternalServerError("redis rate limit error")
+	}
+	return &RateLimitResult{
+		Allowed:    values[0] == 1,
+		Remaining:  values[1],
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,50 @@
+package domain_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+func TestRateLimitTake_Bucket(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewRateLimitRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+
+	first, err := repo.Take("messages.list:ip:1.2.3.4", 2, 1)
+	assert.Nil(t, err)
+	assert.True(t, first.Allowed)
+	assert.EqualValues(t, 1, first.Remaining)
+
+	second, err := repo.Take("messages.list:ip:1.2.3.4", 2, 1)
+	assert.Nil(t, err)
+	assert.True(t, second.Allowed)
+	assert.EqualValues(t, 0, second.Remaining)
+
+	third, err := repo.Take("messages.list:ip:1.2.3.4", 2, 1)
+	assert.Nil(t, err)
+	assert.False(t, third.Allowed)
+	assert.True(t, third.RetryAfter > 0 && third.RetryAfter <= time.Second)
+	assert.True(t, third.ResetAfter > time.Second && third.ResetAfter <= 2*time.Second)
+
+	other, err := repo.Take("messages.list:ip:5.6.7.8", 2, 1)
+	assert.Nil(t, err)
+	assert.True(t, other.Allowed)
+	assert.True(t, server.Exists("ratelimit:messages.list:ip:5.6.7.8"))
+}
+
+func TestRateLimitTake_RedisError(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewRateLimitRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	server.Close()
+
+	result, err := repo.Take("messages.list:ip:1.2.3.4", 2, 1)
+
+	assert.Nil(t, result)
+	assert.True(t, errors.Is(err, error_utils.ErrUnavailable))
+}
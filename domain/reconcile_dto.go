@@ -0,0 +1,22 @@
+package domain
+
+// ReconcileSummary counts what a reconciliation of Redis against an
+// authoritative source found: messages it checked, live in the source but
+// not in Redis, live in Redis but not in the source, and live in both with
+// different fields, and how many of them it repaired. Differences in
+// messages Redis changed after the source was taken are not drift; they
+// count as newer.
+type ReconcileSummary struct {
+	Checked      int `json:"checked"`
+	Missing      int `json:"missing"`
+	Extra        int `json:"extra"`
+	Mismatched   int `json:"mismatched"`
+	Newer        int `json:"newer"`
+	Repaired     int `json:"repaired"`
+	RepairFailed int `json:"repair_failed"`
+}
+
+// InSync reports whether Redis matches the source, counting repairs.
+func (s *ReconcileSummary) InSync() bool {
+	return s.Missing+s.Extra+s.Mismatched == s.Repaired
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"strings"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+)
+
+func init() {
+	error_formats.Register("redis", translateRedisError)
+}
+
+// translateRedisError classifies missing keys and the errors Redis answers
+// with while it cannot serve a command for now: a replica that became
+// read-only after a failover, a server still loading its dataset and
+// cluster slots that are moving. Timeouts and refused connections are left
+// to the generic translators.
+func translateRedisError(err error) error_utils.MessageErr {
+	if errors.Is(err, redis.Nil) {
+		return error_utils.NewNotFoundError("record not found")
+	}
+	if errors.Is(err, redis.ErrClosed) {
+		return error_utils.NewServiceUnavailableError("backend unavailable").WithCode(error_utils.CodeBackendUnavailable)
+	}
+	var redisErr redis.Error
+	if !errors.As(err, &redisErr) {
+		return nil
+	}
+	switch prefix, _, _ := strings.Cut(redisErr.Error(), " "); prefix {
+	case "READONLY":
+		return error_utils.NewServiceUnavailableError("backend is read-only").WithCode(error_utils.CodeBackendReadOnly)
+	case "LOADING":
+		return error_utils.NewServiceUnavailableError("backend is loading").WithCode(error_utils.CodeBackendLoading)
+	case "MOVED", "ASK", "TRYAGAIN", "CLUSTERDOWN":
+		return error_utils.NewServiceUnavailableError("backend is resharding").WithCode(error_utils.CodeBackendMoved)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package domain_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/go-redis/redismock/v8"
+	"github.com/stretchr/testify/assert"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+// redisReply is an error reply from the server, as go-redis reports it.
+type redisReply string
+
+func (e redisReply) Error() string { return string(e) }
+
+func (redisReply) RedisError() {}
+
+func TestGetMessage_Translates_Redis_Errors(t *testing.T) {
+	tests := []struct {
+		reply string
+		code  string
+	}{
+		{"READONLY You can't write against a read only replica.", error_utils.CodeBackendReadOnly},
+		{"LOADING Redis is loading the dataset in memory", error_utils.CodeBackendLoading},
+		{"MOVED 3999 127.0.0.1:6381", error_utils.CodeBackendMoved},
+		{"ERR unknown command", error_utils.CodeInternalError},
+	}
+	for _, test := range tests {
+		db, mock := redismock.NewClientMock()
+		repo := domain.NewMessageRepository(db)
+		mock.ExpectGet("message:1").SetErr(redisReply(test.reply))
+
+		_, err := repo.Get(1)
+
+		assert.Equal(t, test.code, err.Code(), test.reply)
+		assert.Contains(t, err.Detail(), test.reply)
+	}
+}
+
+func TestGetMessage_Translated_Unavailable(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	repo := domain.NewMessageRepository(db)
+	mock.ExpectGet("message:1").SetErr(redisReply("READONLY You can't write against a read only replica."))
+
+	_, err := repo.Get(1)
+
+	assert.True(t, errors.Is(err, error_utils.ErrUnavailable))
+	assert.Equal(t, "backend is read-only", err.Message())
+}
+
+func TestRepositories_Unavailable_When_Redis_Is_Down(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	server.Close()
+	now := time.Now()
+	calls := map[string]func() error_utils.MessageErr{
+		"history": func() error_utils.MessageErr {
+			return domain.NewHistoryRepository(client, 0, 0).Append(domain.DefaultTenant, 1, &domain.MessageVersion{})
+		},
+		"stats": func() error_utils.MessageErr {
+			_, err := domain.NewStatsRepository(client).Get(domain.DefaultTenant, "hour", now.Add(-time.Hour), now)
+			return err
+		},
+		"changes": func() error_utils.MessageErr {
+			return domain.NewChangeFeed(client, 10).Publish(&domain.MessageChange{})
+		},
+		"webhooks": func() error_utils.MessageErr {
+			_, err := domain.NewWebhookRepository(client).GetAll()
+			return err
+		},
+		"rate limits": func() error_utils.MessageErr {
+			_, err := domain.NewRateLimitRepository(client).Take("key", 1, 1)
+			return err
+		},
+		"cache invalidations": func() error_utils.MessageErr {
+			return domain.NewCacheInvalidations(client).Publish(domain.CacheInvalidation{})
+		},
+	}
+	for name, call := range calls {
+		err := call()
+
+		assert.True(t, errors.Is(err, error_utils.ErrUnavailable), name)
+	}
+}
@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"github.com/go-redis/redis/v8"
+	"strconv"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+const newestPageSize = 10
+
+var (
+	StatsRepo statsRepoInterface = &statsRepo{}
+)
+
+type statsRepoInterface interface {
+	Get(string, string, time.Time, time.Time) (*MessageStats, error_utils.MessageErr)
+	Initialize(*redis.Client)
+}
+
+// statsRepo reads the counters that the message scripts maintain, so no
+// statistic needs a scan over the messages themselves.
+type statsRepo struct {
+	client *redis.Client
+}
+
+func (sr *statsRepo) Initialize(client *redis.Client) {
+	sr.client = client
+}
+
+func NewStatsRepository(client *redis.Client) statsRepoInterface {
+	return &statsRepo{client: client}
+}
+
+// Get returns the statistics of tenant with one bucket per day or hour from
+// the bucket holding from to the one holding to.
+func (sr *statsRepo) Get(tenant, bucket string, from, to time.Time) (*MessageStats, error_utils.MessageErr) {
+	from = from.UTC()
+	step, layout := 24*time.Hour, statsDayLayout
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	if bucket == StatsBucketHour {
+		step, layout = time.Hour, statsHourLayout
+		start = from.Truncate(time.Hour)
+	}
+
+	fields := []string{"total", "title_length", "body_length"}
+	stats := &MessageStats{Bucket: bucket, Buckets: make([]StatsBucket, 0)}
+	for at := start; !at.After(to); at = at.Add(step) {
+		stats.Buckets = append(stats.Buckets, StatsBucket{Start: at})
+		fields = append(fields, bucket+":"+at.Format(layout))
+	}
+
+	values, err := sr.client.HMGet(ctx, statsKey(tenant), fields...).Result()
+	if err != nil {
+		return nil, error_formats.Translate(err, "redis stats")
+	}
+	counters := make([]int64, len(values))
+	for i, value := range values {
+		if str, ok := value.(string); ok {
+			counters[i], _ = strconv.ParseInt(str, 10, 64)
+		}
+	}
+	stats.Total = counters[0]
+	if stats.Total > 0 {
+		stats.AvgTitleLength = float64(counters[1]) / float64(stats.Total)
+		stats.AvgBodyLength = float64(counters[2]) / float64(stats.Total)
+	}
+	for i := range stats.Buckets {
+		stats.Buckets[i].Count = counters[3+i]
+	}
+
+	newest, getErr := sr.newest(tenant)
+	if getErr != nil {
+		return nil, getErr
+	}
+	stats.NewestCreatedAt = newest
+	return stats, nil
+}
+
+// newest walks the created-at index from the end, skipping soft-deleted
+// messages, which stay indexed but are not counted.
+func (sr *statsRepo) newest(tenant string) (*time.Time, error_utils.MessageErr) {
+	for offset := int64(0); ; offset += newestPageSize {
+		page, err := sr.client.ZRevRangeWithScores(ctx, createdIndexKey(tenant), offset, offset+newestPageSize-1).Result()
+		if err != nil {
+			return nil, error_formats.Translate(err, "redis stats")
+		}
+		if len(page) == 0 {
+			return nil, nil
+		}
+		ids := make([]string, len(page))
+		for i, entry := range page {
+			ids[i] = entry.Member.(string)
+		}
+		counted, err := sr.client.HMGet(ctx, statsOfKey(tenant), ids...).Result()
+		if err != nil {
+			return nil, error_formats.Translate(err, "redis stats")
+		}
+		for i, value := range counted {
+			if value != nil {
+				newest := time.UnixMilli(int64(page[i].Score)).UTC()
+				return &newest, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+const (
+	StatsBucketDay  = "day"
+	StatsBucketHour = "hour"
+
+	statsDayLayout  = "2006-01-02"
+	statsHourLayout = "2006-01-02T15"
+)
+
+// MessageStats summarises a tenant's live messages. Buckets count messages
+// by creation time (UTC) over the requested range.
+type MessageStats struct {
+	Total           int64         `json:"total"`
+	AvgTitleLength  float64       `json:"avg_title_length"`
+	AvgBodyLength   float64       `json:"avg_body_length"`
+	NewestCreatedAt *time.Time    `json:"newest_created_at"`
+	Bucket          string        `json:"bucket"`
+	Buckets         []StatsBucket `json:"buckets"`
+}
+
+type StatsBucket struct {
+	Start time.Time `json:"start"`
+	Count int64     `json:"count"`
+}
@@ -0,0 +1,62 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"testing-project/domain"
+)
+
+func TestStats_Maintained_Incrementally(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	repo := domain.NewMessageRepository(client).ForTenant("team-a")
+	stats := domain.NewStatsRepository(client)
+	day := time.Date(2024, 3, 10, 9, 30, 0, 0, time.UTC)
+
+	assert.Nil(t, repo.Save(&domain.Message{Id: 1, Title: "abcd", Body: "12", CreatedAt: day}))
+	assert.Nil(t, repo.Save(&domain.Message{Id: 2, Title: "ab", Body: "123456", CreatedAt: day.Add(time.Hour)}))
+	assert.Nil(t, repo.Save(&domain.Message{Id: 3, Title: "x", Body: "y", CreatedAt: day.Add(24 * time.Hour)}))
+	assert.Nil(t, repo.Save(&domain.Message{Id: 2, Title: "abcdef", Body: "1234", CreatedAt: day.Add(time.Hour)}))
+	assert.Nil(t, repo.Delete(3))
+
+	result, err := stats.Get("team-a", domain.StatsBucketDay, day.Add(-24*time.Hour), day.Add(24*time.Hour))
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, result.Total)
+	assert.EqualValues(t, 5, result.AvgTitleLength)
+	assert.EqualValues(t, 3, result.AvgBodyLength)
+	assert.Equal(t, day.Add(time.Hour), *result.NewestCreatedAt)
+	assert.Equal(t, 3, len(result.Buckets))
+	assert.EqualValues(t, []int64{0, 2, 0}, []int64{result.Buckets[0].Count, result.Buckets[1].Count, result.Buckets[2].Count})
+	assert.Equal(t, time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), result.Buckets[1].Start)
+}
+
+func TestStats_Hourly_Skips_Soft_Deleted(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	repo := domain.NewMessageRepository(client)
+	stats := domain.NewStatsRepository(client)
+	hour := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	assert.Nil(t, repo.Save(&domain.Message{Id: 1, Title: "a", CreatedAt: hour}))
+	assert.Nil(t, repo.Save(&domain.Message{Id: 2, Title: "b", CreatedAt: hour.Add(time.Hour)}))
+	assert.Nil(t, repo.SoftDelete(2))
+
+	result, err := stats.Get(domain.DefaultTenant, domain.StatsBucketHour, hour, hour.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, result.Total)
+	assert.Equal(t, hour, *result.NewestCreatedAt)
+	assert.EqualValues(t, 1, result.Buckets[0].Count)
+	assert.EqualValues(t, 0, result.Buckets[1].Count)
+
+	_, restoreErr := repo.Restore(2)
+	assert.Nil(t, restoreErr)
+	result, err = stats.Get(domain.DefaultTenant, domain.StatsBucketHour, hour, hour.Add(time.Hour))
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, result.Total)
+	assert.EqualValues(t, 1, result.Buckets[1].Count)
+}
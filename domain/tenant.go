@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing-project/utils/error_utils"
+)
+
+// DefaultTenant owns the original, un-prefixed "message:*" keyspace so data
+// written before tenants existed stays readable.
+const DefaultTenant = "default"
+
+var (
+	tenantPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	TenantQuotas  = map[string]int64{}
+)
+
+// NormalizeTenant maps an absent tenant to DefaultTenant and rejects ids that
+// could escape their key prefix.
+func NormalizeTenant(tenant string) (string, error_utils.MessageErr) {
+	tenant = strings.TrimSpace(tenant)
+	if tenant == "" {
+		return DefaultTenant, nil
+	}
+	if !tenantPattern.MatchString(tenant) {
+		return "", error_utils.NewBadRequestError("invalid tenant id").WithCode(error_utils.CodeInvalidTenant)
+	}
+	return tenant, nil
+}
+
+// InitializeQuotas parses "<tenant>=<max messages>" entries separated by
+// commas. The "*" entry applies to tenants without their own quota and zero
+// means unlimited.
+func InitializeQuotas(config string) error {
+	quotas := map[string]int64{}
+	for _, entry := range strings.Split(config, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		tenant, value, found := strings.Cut(entry, "=")
+		quota, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if !found || err != nil || quota < 0 {
+			return fmt.Errorf("invalid tenant quota %q", entry)
+		}
+		quotas[strings.TrimSpace(tenant)] = quota
+	}
+	TenantQuotas = quotas
+	return nil
+}
+
+func QuotaFor(tenant string) int64 {
+	if quota, ok := TenantQuotas[tenant]; ok {
+		return quota
+	}
+	return TenantQuotas["*"]
+}
+
+func tenantPrefix(tenant string) string {
+	if tenant == "" || tenant == DefaultTenant {
+		return ""
+	}
+	return fmt.Sprintf("tenant:%s:", tenant)
+}
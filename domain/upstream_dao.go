@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"net/http"
+	"strings"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+const (
+	upstreamFailureThreshold = 5
+	upstreamOpenFor          = 30 * time.Second
+)
+
+var (
+	Upstream upstreamInterface = &upstream{}
+)
+
+type upstreamInterface interface {
+	Enabled() bool
+	Fetch(string, int64) (*Message, error_utils.MessageErr)
+	Initialize(*redis.Client, string, time.Duration, time.Duration)
+}
+
+// upstream reads messages from the writer service that owns them, for when
+// an event never made it into Redis. Ids the writer does not know are
+// remembered for negativeTtl so repeated lookups do not reach it, and a
+// circuit breaker stops calls while it is failing.
+type upstream struct {
+	client      *redis.Client
+	httpClient  *http.Client
+	baseUrl     string
+	negativeTtl time.Duration
+	breaker     *circuitBreaker
+}
+
+func (u *upstream) Initialize(client *redis.Client, baseUrl string, timeout, negativeTtl time.Duration) {
+	u.client = client
+	u.httpClient = &http.Client{Timeout: timeout}
+	u.baseUrl = strings.TrimRight(baseUrl, "/")
+	u.negativeTtl = negativeTtl
+	u.breaker = newCircuitBreaker(upstreamFailureThreshold, upstreamOpenFor)
+}
+
+func NewUpstream(client *redis.Client, baseUrl string, timeout, negativeTtl time.Duration) upstreamInterface {
+	u := &upstream{}
+	u.Initialize(client, baseUrl, timeout, negativeTtl)
+	return u
+}
+
+// Enabled reports whether read-through is configured at all.
+func (u *upstream) Enabled() bool {
+	return u.baseUrl != ""
+}
+
+func missKey(tenant string, messageId int64) string {
+	return fmt.Sprintf("%smessage_miss:%d", tenantPrefix(tenant), messageId)
+}
+
+func (u *upstream) Fetch(tenant string, messageId int64) (*Message, error_utils.MessageErr) {
+	if u.negativeTtl > 0 {
+		if missing, err := u.client.Exists(ctx, missKey(tenant, messageId)).Result(); err == nil && missing > 0 {
+			return nil, error_utils.NewNotFoundError("message not found").WithCode(error_utils.CodeMessageNotFound)
+		}
+	}
+	if !u.breaker.Allow() {
+		return nil, error_utils.NewServiceUnavailableError("upstream unavailable").WithCode(error_utils.CodeUpstreamUnavailable).WithDetail("circuit breaker open")
+	}
+
+	msg, status, err := u.get(tenant, messageId)
+	if err != nil {
+		u.breaker.Failure()
+		return nil, error_utils.NewServiceUnavailableError("upstream unavailable").WithCode(error_utils.CodeUpstreamUnavailable).Wrap(err)
+	}
+	u.breaker.Success()
+
+	if status == http.StatusNotFound {
+		if u.negativeTtl > 0 {
+			u.client.Set(ctx, missKey(tenant, messageId), 1, u.negativeTtl)
+		}
+		return nil, error_utils.NewNotFoundError("message not found").WithCode(error_utils.CodeMessageNotFound)
+	}
+	return msg, nil
+}
+
+// get treats anything but 200 and 404 as the upstream failing.
+func (u *upstream) get(tenant string, messageId int64) (*Message, int, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/messages/%d", u.baseUrl, messageId), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Tenant-ID", tenant)
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var msg Message
+		if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+			return nil, resp.StatusCode, err
+		}
+		return &msg, resp.StatusCode, nil
+	case http.StatusNotFound:
+		return nil, resp.StatusCode, nil
+	default:
+		return nil, resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+}
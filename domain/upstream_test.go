@@ -0,0 +1,68 @@
+package domain_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"testing-project/domain"
+)
+
+func TestUpstream_Fetch_Success(t *testing.T) {
+	server := miniredis.RunT(t)
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/messages/5", r.URL.Path)
+		assert.Equal(t, "team-a", r.Header.Get("X-Tenant-ID"))
+		json.NewEncoder(w).Encode(domain.Message{Id: 5, Title: "from upstream"})
+	}))
+	defer stub.Close()
+	upstream := domain.NewUpstream(redis.NewClient(&redis.Options{Addr: server.Addr()}), stub.URL, time.Second, time.Minute)
+
+	msg, err := upstream.Fetch("team-a", 5)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "from upstream", msg.Title)
+}
+
+func TestUpstream_Fetch_Negative_Cache(t *testing.T) {
+	server := miniredis.RunT(t)
+	var calls int32
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer stub.Close()
+	upstream := domain.NewUpstream(redis.NewClient(&redis.Options{Addr: server.Addr()}), stub.URL, time.Second, time.Minute)
+
+	_, err := upstream.Fetch(domain.DefaultTenant, 5)
+	assert.Equal(t, http.StatusNotFound, err.Status())
+	_, err = upstream.Fetch(domain.DefaultTenant, 5)
+	assert.Equal(t, http.StatusNotFound, err.Status())
+
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+	assert.True(t, server.Exists("message_miss:5"))
+}
+
+func TestUpstream_Fetch_Circuit_Breaker(t *testing.T) {
+	server := miniredis.RunT(t)
+	var calls int32
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer stub.Close()
+	upstream := domain.NewUpstream(redis.NewClient(&redis.Options{Addr: server.Addr()}), stub.URL, time.Second, time.Minute)
+
+	for i := 0; i < 8; i++ {
+		_, err := upstream.Fetch(domain.DefaultTenant, int64(i))
+		assert.Equal(t, http.StatusServiceUnavailable, err.Status())
+	}
+
+	assert.EqualValues(t, 5, atomic.LoadInt32(&calls))
+}
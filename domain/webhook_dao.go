@@ -0,0 +1,215 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+const (
+	webhooksKey       = "webhooks"
+	deliveryLogLength = 100
+
+	// Pending retries: their ids scored by the time of the next attempt in
+	// ms, and the retries themselves by id.
+	webhookRetriesKey     = "webhook_retries"
+	webhookRetriesDataKey = "webhook_retries:data"
+)
+
+// claimRetriesScript takes up to ARGV[2] retries due at ARGV[1] and pushes
+// them back to ARGV[3], so no other replica takes them meanwhile and they
+// are attempted again if the claimer never finishes them.
+var claimRetriesScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+if #ids == 0 then
+  return {}
+end
+for _, id in ipairs(ids) do
+  redis.call('ZADD', KEYS[1], ARGV[3], id)
+end
+return redis.call('HMGET', KEYS[2], unpack(ids))
+`)
+
+var (
+	WebhookRepo webhookRepoInterface = &webhookRepo{}
+)
+
+type webhookRepoInterface interface {
+	Get(string) (*Webhook, error_utils.MessageErr)
+	GetAll() ([]Webhook, error_utils.MessageErr)
+	Save(*Webhook) error_utils.MessageErr
+	Delete(string) error_utils.MessageErr
+	LogDelivery(*WebhookDelivery) error_utils.MessageErr
+	GetDeliveries(string) ([]WebhookDelivery, error_utils.MessageErr)
+	ScheduleRetry(*WebhookRetry, time.Time) error_utils.MessageErr
+	ClaimRetries(time.Time, time.Duration, int) ([]WebhookRetry, error_utils.MessageErr)
+	CompleteRetry(string) error_utils.MessageErr
+	Initialize(*redis.Client)
+}
+
+// webhookRepo keeps subscriptions in a single Redis hash, the most recent
+// delivery attempts of each subscription in a capped list and the deliveries
+// waiting to be retried in a sorted set, so they survive restarts.
+type webhookRepo struct {
+	client *redis.Client
+}
+
+func (wr *webhookRepo) Initialize(client *redis.Client) {
+	wr.client = client
+}
+
+func NewWebhookRepository(client *redis.Client) webhookRepoInterface {
+	return &webhookRepo{client: client}
+}
+
+func deliveriesKey(webhookId string) string {
+	return fmt.Sprintf("webhook_deliveries:%s", webhookId)
+}
+
+func (wr *webhookRepo) Get(webhookId string) (*Webhook, error_utils.MessageErr) {
+	data, err := wr.client.HGet(ctx, webhooksKey, webhookId).Result()
+	if err == redis.Nil {
+		return nil, error_utils.NewNotFoundError("webhook not found").WithCode(error_utils.CodeWebhookNotFound)
+	} else if err != nil {
+		return nil, error_formats.Translate(err, "redis get")
+	}
+
+	var webhook Webhook
+	if err := json.Unmarshal([]byte(data), &webhook); err != nil {
+		return nil, error_formats.Translate(err, "json unmarshal")
+	}
+	return &webhook, nil
+}
+
+func (wr *webhookRepo) GetAll() ([]Webhook, error_utils.MessageErr) {
+	values, err := wr.client.HGetAll(ctx, webhooksKey).Result()
+	if err != nil {
+		return nil, error_formats.Translate(err, "redis webhooks")
+	}
+
+	webhooks := make([]Webhook, 0, len(values))
+	for _, data := range values {
+		var webhook Webhook
+		if err := json.Unmarshal([]byte(data), &webhook); err != nil {
+			continue
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+func (wr *webhookRepo) Save(webhook *Webhook) error_utils.MessageErr {
+	data, err := json.Marshal(webhook)
+	if err != nil {
+		return error_formats.Translate(err, "json marshal")
+	}
+	if err := wr.client.HSet(ctx, webhooksKey, webhook.Id, data).Err(); err != nil {
+		return error_formats.Translate(err, "redis save")
+	}
+	return nil
+}
+
+func (wr *webhookRepo) Delete(webhookId string) error_utils.MessageErr {
+	removed, err := wr.client.HDel(ctx, webhooksKey, webhookId).Result()
+	if err != nil {
+		return error_formats.Translate(err, "redis delete")
+	}
+	if removed == 0 {
+		return error_utils.NewNotFoundError("webhook not found").WithCode(error_utils.CodeWebhookNotFound)
+	}
+	if err := wr.client.Del(ctx, deliveriesKey(webhookId)).Err(); err != nil {
+		return error_formats.Translate(err, "redis delete")
+	}
+	return nil
+}
+
+func (wr *webhookRepo) LogDelivery(delivery *WebhookDelivery) error_utils.MessageErr {
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return error_formats.Translate(err, "json marshal")
+	}
+	key := deliveriesKey(delivery.WebhookId)
+	if err := wr.client.LPush(ctx, key, data).Err(); err != nil {
+		return error_formats.Translate(err, "redis save")
+	}
+	if err := wr.client.LTrim(ctx, key, 0, deliveryLogLength-1).Err(); err != nil {
+		return error_formats.Translate(err, "redis trim")
+	}
+	return nil
+}
+
+// GetDeliveries returns the retained delivery attempts, newest first.
+func (wr *webhookRepo) GetDeliveries(webhookId string) ([]WebhookDelivery, error_utils.MessageErr) {
+	values, err := wr.client.LRange(ctx, deliveriesKey(webhookId), 0, -1).Result()
+	if err != nil {
+		return nil, error_formats.Translate(err, "redis deliveries")
+	}
+
+	deliveries := make([]WebhookDelivery, 0, len(values))
+	for _, data := range values {
+		var delivery WebhookDelivery
+		if err := json.Unmarshal([]byte(data), &delivery); err != nil {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// ScheduleRetry stores retry for an attempt at the given time, replacing the
+// retry with the same id.
+func (wr *webhookRepo) ScheduleRetry(retry *WebhookRetry, at time.Time) error_utils.MessageErr {
+	data, err := json.Marshal(retry)
+	if err != nil {
+		return error_formats.Translate(err, "json marshal")
+	}
+	_, err = wr.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, webhookRetriesDataKey, retry.Id, data)
+		pipe.ZAdd(ctx, webhookRetriesKey, &redis.Z{Score: float64(at.UnixMilli()), Member: retry.Id})
+		return nil
+	})
+	if err != nil {
+		return error_formats.Translate(err, "redis save")
+	}
+	return nil
+}
+
+// ClaimRetries returns up to limit retries due at now. They stay stored and
+// become due again after lease, unless they are completed or scheduled
+// again first.
+func (wr *webhookRepo) ClaimRetries(now time.Time, lease time.Duration, limit int) ([]WebhookRetry, error_utils.MessageErr) {
+	values, err := claimRetriesScript.Run(ctx, wr.client, []string{webhookRetriesKey, webhookRetriesDataKey},
+		now.UnixMilli(), limit, now.Add(lease).UnixMilli()).Slice()
+	if err != nil {
+		return nil, error_formats.Translate(err, "redis claim")
+	}
+
+	retries := make([]WebhookRetry, 0, len(values))
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var retry WebhookRetry
+		if err := json.Unmarshal([]byte(data), &retry); err != nil {
+			continue
+		}
+		retries = append(retries, retry)
+	}
+	return retries, nil
+}
+
+func (wr *webhookRepo) CompleteRetry(retryId string) error_utils.MessageErr {
+	_, err := wr.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, webhookRetriesKey, retryId)
+		pipe.HDel(ctx, webhookRetriesDataKey, retryId)
+		return nil
+	})
+	if err != nil {
+		return error_formats.Translate(err, "redis delete")
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+// Webhook is a subscriber that is notified about message changes. An empty
+// Events list subscribes to every event type and an empty Tenant to the
+// changes of every tenant.
+type Webhook struct {
+	Id        string    `json:"id"`
+	Url       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookDelivery records a single delivery attempt of a change to a webhook.
+type WebhookDelivery struct {
+	Id          string    `json:"id"`
+	WebhookId   string    `json:"webhook_id"`
+	ChangeId    string    `json:"change_id"`
+	Event       string    `json:"event"`
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Success     bool      `json:"success"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// WebhookRetry is a failed delivery waiting in Redis for its next attempt,
+// with the body that is sent again unchanged.
+type WebhookRetry struct {
+	Id        string          `json:"id"`
+	WebhookId string          `json:"webhook_id"`
+	ChangeId  string          `json:"change_id"`
+	Event     string          `json:"event"`
+	Body      json.RawMessage `json:"body"`
+	Attempt   int             `json:"attempt"`
+}
+
+func (w *Webhook) Validate() error_utils.MessageErr {
+	w.Url = strings.TrimSpace(w.Url)
+	u, err := url.Parse(w.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return error_utils.NewUnprocessibleEntityError("Please enter a valid http(s) url").WithCode(error_utils.CodeInvalidWebhook)
+	}
+	if w.Tenant != "" {
+		if _, err := NormalizeTenant(w.Tenant); err != nil {
+			return error_utils.NewUnprocessibleEntityError("Please enter a valid tenant").WithCode(error_utils.CodeInvalidWebhook)
+		}
+	}
+	for _, event := range w.Events {
+		if event != EventCreated && event != EventUpdated && event != EventDeleted && event != EventRestored {
+			return error_utils.NewUnprocessibleEntityError("Unknown event type: " + event).WithCode(error_utils.CodeInvalidWebhook)
+		}
+	}
+	return nil
+}
+
+func (w *Webhook) Accepts(change *MessageChange) bool {
+	if w.Tenant != "" && w.Tenant != change.Tenant {
+		return false
+	}
+	return w.acceptsEvent(change.Event)
+}
+
+func (w *Webhook) acceptsEvent(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package domain_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/go-redis/redismock/v8"
+	"github.com/stretchr/testify/assert"
+	"testing-project/domain"
+)
+
+func TestGetWebhook_Success(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	repo := domain.NewWebhookRepository(db)
+
+	webhook := domain.Webhook{Id: "abc", Url: "http://example.com/hook", Events: []string{"created"}}
+	data, _ := json.Marshal(webhook)
+	mock.ExpectHGet("webhooks", "abc").SetVal(string(data))
+
+	result, err := repo.Get("abc")
+
+	assert.Nil(t, err)
+	assert.Equal(t, webhook.Url, result.Url)
+	assert.Equal(t, webhook.Events, result.Events)
+}
+
+func TestGetWebhook_NotFound(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	repo := domain.NewWebhookRepository(db)
+
+	mock.ExpectHGet("webhooks", "abc").RedisNil()
+
+	result, err := repo.Get("abc")
+
+	assert.Nil(t, result)
+	assert.Equal(t, "webhook not found", err.Message())
+}
+
+func TestSaveWebhook_Success(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	repo := domain.NewWebhookRepository(db)
+
+	webhook := &domain.Webhook{Id: "abc", Url: "http://example.com/hook"}
+	data, _ := json.Marshal(webhook)
+	mock.ExpectHSet("webhooks", "abc", data).SetVal(1)
+
+	err := repo.Save(webhook)
+
+	assert.Nil(t, err)
+}
+
+func TestDeleteWebhook_NotFound(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	repo := domain.NewWebhookRepository(db)
+
+	mock.ExpectHDel("webhooks", "abc").SetVal(0)
+
+	err := repo.Delete("abc")
+
+	assert.Equal(t, "webhook not found", err.Message())
+}
+
+func TestLogDelivery_Success(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	repo := domain.NewWebhookRepository(db)
+
+	delivery := &domain.WebhookDelivery{Id: "d1", WebhookId: "abc", Attempt: 1, Success: true}
+	data, _ := json.Marshal(delivery)
+	mock.ExpectLPush("webhook_deliveries:abc", data).SetVal(1)
+	mock.ExpectLTrim("webhook_deliveries:abc", 0, 99).SetVal("OK")
+
+	err := repo.LogDelivery(delivery)
+
+	assert.Nil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRetries(t *testing.T) {
+	server := miniredis.RunT(t)
+	repo := domain.NewWebhookRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	now := time.Now()
+	due := &domain.WebhookRetry{Id: "r1", WebhookId: "abc", ChangeId: "7-0", Event: "created", Body: json.RawMessage(`{"id":"7-0"}`), Attempt: 2}
+	later := &domain.WebhookRetry{Id: "r2", WebhookId: "abc", ChangeId: "8-0", Event: "deleted", Body: json.RawMessage(`{}`), Attempt: 2}
+	assert.Nil(t, repo.ScheduleRetry(due, now.Add(-time.Second)))
+	assert.Nil(t, repo.ScheduleRetry(later, now.Add(time.Minute)))
+
+	claimed, err := repo.ClaimRetries(now, 30*time.Second, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.WebhookRetry{*due}, claimed)
+
+	claimed, err = repo.ClaimRetries(now, 30*time.Second, 10)
+	assert.Nil(t, err)
+	assert.Empty(t, claimed)
+
+	claimed, err = repo.ClaimRetries(now.Add(31*time.Second), 30*time.Second, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.WebhookRetry{*due}, claimed)
+
+	assert.Nil(t, repo.CompleteRetry("r1"))
+	claimed, err = repo.ClaimRetries(now.Add(2*time.Minute), 30*time.Second, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []domain.WebhookRetry{*later}, claimed)
+}
+
+func TestWebhookValidate(t *testing.T) {
+	webhook := &domain.Webhook{Url: " https://example.com/hook ", Events: []string{"created", "deleted"}}
+	assert.Nil(t, webhook.Validate())
+	assert.Equal(t, "https://example.com/hook", webhook.Url)
+
+	webhook = &domain.Webhook{Url: "ftp://example.com"}
+	assert.Equal(t, "Please enter a valid http(s) url", webhook.Validate().Message())
+
+	webhook = &domain.Webhook{Url: "http://example.com", Events: []string{"renamed"}}
+	assert.Equal(t, "Unknown event type: renamed", webhook.Validate().Message())
+}
+
+func TestWebhookAccepts(t *testing.T) {
+	created := &domain.MessageChange{Event: "created", Tenant: "team-a"}
+	assert.True(t, (&domain.Webhook{}).Accepts(created))
+	assert.True(t, (&domain.Webhook{Events: []string{"created"}, Tenant: "team-a"}).Accepts(created))
+	assert.False(t, (&domain.Webhook{Events: []string{"updated"}}).Accepts(created))
+	assert.False(t, (&domain.Webhook{Tenant: "team-b"}).Accepts(created))
+}
@@ -0,0 +1,109 @@
+package events
+
+import (
+	"sync"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+// BatchHandler is an EventHandler whose events can be applied together with
+// others in one Redis transaction.
+type BatchHandler interface {
+	EventHandler
+	// Stage returns the write that applies a valid event.
+	Stage(event *Event) domain.MessageWrite
+	// Committed finishes an event whose write was committed, with the
+	// write's result; it does what Apply does after writing.
+	Committed(event *Event, result domain.MessageWriteResult)
+}
+
+// DispatchBatch handles a batch of events. Every event goes through the
+// middlewares as Dispatch does, but the writes of those that reach their
+// handler are applied together, in batch order, in one Redis transaction,
+// and their handlers finish them in batch order too. It returns the outcome
+// of every event. The error means the transaction did not run; it is then
+// also the outcome of every event that reached its handler.
+func (d *Dispatcher) DispatchBatch(batch []*Event) ([]error_utils.MessageErr, error_utils.MessageErr) {
+	n := len(batch)
+	writes := make([]*domain.MessageWrite, n)
+	results := make([]domain.MessageWriteResult, n)
+	var commitErr error_utils.MessageErr
+
+	// Every event is ready once it is staged or done without a write; the
+	// transaction runs when all are. Then each takes its turn to finish.
+	var ready, finished sync.WaitGroup
+	readyOnce := make([]sync.Once, n)
+	committed := make(chan struct{})
+	turns := make([]chan struct{}, n+1)
+	turnOnce := make([]sync.Once, n)
+	for i := range turns {
+		turns[i] = make(chan struct{})
+	}
+	takeTurn := func(i int, fn func()) {
+		turnOnce[i].Do(func() {
+			<-turns[i]
+			if fn != nil {
+				fn()
+			}
+			close(turns[i+1])
+		})
+	}
+
+	outcomes := make([]error_utils.MessageErr, n)
+	ready.Add(n)
+	finished.Add(n)
+	for i, event := range batch {
+		stage := func(event *Event) error_utils.MessageErr {
+			handler, err := d.prepare(event)
+			if err != nil {
+				return err
+			}
+			batchHandler, ok := handler.(BatchHandler)
+			if !ok {
+				return error_utils.NewBadRequestError("event type cannot be batched").WithCode(error_utils.CodeInvalidEvent).WithDetail(event.Type)
+			}
+			write := batchHandler.Stage(event)
+			writes[i] = &write
+			readyOnce[i].Do(ready.Done)
+
+			<-committed
+			if commitErr != nil {
+				return commitErr
+			}
+			if results[i].Err != nil {
+				return results[i].Err
+			}
+			takeTurn(i, func() { batchHandler.Committed(event, results[i]) })
+			return nil
+		}
+		go func(i int, event *Event) {
+			defer finished.Done()
+			defer takeTurn(i, nil)
+			defer readyOnce[i].Do(ready.Done)
+			outcomes[i] = d.chain(stage)(event)
+		}(i, event)
+	}
+
+	ready.Wait()
+	var staged []domain.MessageWrite
+	var stagedIndexes []int
+	for i, write := range writes {
+		if write != nil {
+			staged = append(staged, *write)
+			stagedIndexes = append(stagedIndexes, i)
+		}
+	}
+	if len(staged) > 0 {
+		stagedResults, err := domain.MessageRepo.ApplyBatch(staged)
+		commitErr = err
+		for j, i := range stagedIndexes {
+			if err == nil {
+				results[i] = stagedResults[j]
+			}
+		}
+	}
+	close(committed)
+	close(turns[0])
+	finished.Wait()
+	return outcomes, commitErr
+}
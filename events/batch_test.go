@@ -0,0 +1,116 @@
+package events
+
+import (
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+func decodeBatch(t *testing.T, body string) []*Event {
+	items, batch := DecodeBatch([]byte(body), nil, "batch-1")
+	assert.True(t, batch)
+	return items
+}
+
+func TestDecodeBatch(t *testing.T) {
+	_, batch := DecodeBatch([]byte(`{"event":"created","data":{"id":1}}`), nil, "event-1")
+	assert.False(t, batch)
+
+	items, batch := DecodeBatch([]byte(`{"events":[{"event":"created","data":{"id":1}},"not an event"]}`), map[string]interface{}{HeaderTenant: "team-a"}, "batch-1")
+	assert.True(t, batch)
+	assert.Len(t, items, 2)
+	assert.EqualValues(t, "created", items[0].Type)
+	assert.EqualValues(t, "team-a", items[0].Tenant)
+	assert.EqualValues(t, "batch-1#0", items[0].MessageId)
+	assert.EqualValues(t, `{"event":"created","data":{"id":1}}`, string(items[0].Body))
+	assert.EqualValues(t, "batch-1#1", items[1].MessageId)
+	assert.EqualValues(t, error_utils.CodeInvalidEvent, NewDispatcher().Dispatch(items[1]).Code())
+}
+
+func TestDispatchBatch(t *testing.T) {
+	d := newMessageDispatcher(t, false)
+	items := decodeBatch(t, `{"events":[
+		{"event":"created","data":{"id":1,"title":"first","body":"body"}},
+		{"event":"created","data":{"id":2,"title":"second","body":"body"}},
+		{"event":"created","data":{"id":3,"title":" ","body":"body"}},
+		{"event":"deleted","data":{"id":1}}
+	]}`)
+
+	outcomes, err := d.DispatchBatch(items)
+
+	assert.Nil(t, err)
+	assert.Nil(t, outcomes[0])
+	assert.Nil(t, outcomes[1])
+	assert.EqualValues(t, "Please enter a valid title", outcomes[2].Message())
+	assert.Nil(t, outcomes[3])
+	messages, _ := domain.MessageRepo.GetAll()
+	assert.Len(t, messages, 1)
+	assert.EqualValues(t, 2, messages[0].Id)
+	changes := publishedChanges(t)
+	assert.Len(t, changes, 3)
+	for i, want := range []string{"created", "created", "deleted"} {
+		assert.EqualValues(t, want, changes[i].Event)
+	}
+	assert.EqualValues(t, 1, changes[0].MessageId)
+	assert.EqualValues(t, 2, changes[1].MessageId)
+}
+
+func TestDispatchBatch_Redelivery_Is_Skipped(t *testing.T) {
+	d := newMessageDispatcher(t, false)
+	d.Use(Dedup(&memoryProcessed{keys: map[string]bool{}}, false))
+	body := `{"events":[{"event":"created","data":{"id":1,"title":"first","body":"body"}},{"event":"updated","data":{"id":1,"title":"second","body":"body"}}]}`
+
+	_, err := d.DispatchBatch(decodeBatch(t, body))
+	assert.Nil(t, err)
+	again := decodeBatch(t, body)
+	outcomes, err := d.DispatchBatch(again)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []error_utils.MessageErr{nil, nil}, outcomes)
+	assert.True(t, again[0].Duplicate)
+	assert.True(t, again[1].Duplicate)
+	changes := publishedChanges(t)
+	assert.Len(t, changes, 2)
+}
+
+func TestDispatchBatch_Transaction_Failure(t *testing.T) {
+	d := newMessageDispatcher(t, false)
+	processed := &memoryProcessed{keys: map[string]bool{}}
+	d.Use(Dedup(processed, false))
+	server := miniredis.RunT(t)
+	domain.MessageRepo = domain.NewMessageRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	server.Close()
+
+	outcomes, err := d.DispatchBatch(decodeBatch(t, `{"events":[
+		{"event":"created","data":{"id":1,"title":"first","body":"body"}},
+		{"event":"archived","data":{"id":2}}
+	]}`))
+
+	assert.True(t, errors.Is(err, error_utils.ErrUnavailable))
+	assert.Equal(t, err, outcomes[0])
+	assert.EqualValues(t, error_utils.CodeUnknownEventType, outcomes[1].Code())
+	assert.Empty(t, processed.keys)
+}
+
+func TestDispatchBatch_Handler_Without_Batching(t *testing.T) {
+	d := NewDispatcher()
+	handler := &recordingHandler{}
+	d.Register("created", handler)
+
+	outcomes, err := d.DispatchBatch(decodeBatch(t, `{"events":[{"event":"created","data":{"id":1}}]}`))
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, "event type cannot be batched", outcomes[0].Message())
+	assert.Empty(t, handler.applied)
+}
+
+func TestDispatchBatch_Empty(t *testing.T) {
+	outcomes, err := NewDispatcher().DispatchBatch(decodeBatch(t, `{"events":[]}`))
+
+	assert.Nil(t, err)
+	assert.Empty(t, outcomes)
+}
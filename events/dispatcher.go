@@ -0,0 +1,124 @@
+package events
+
+import (
+	"sync"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+// Labels of events that have no type of their own: those no handler is
+// registered for and those that could not be read.
+const (
+	UnknownType = "unknown"
+	InvalidType = "invalid"
+)
+
+// EventHandler applies the events of one type.
+type EventHandler interface {
+	// Validate rejects an event that can never be applied, before anything
+	// is written.
+	Validate(event *Event) error_utils.MessageErr
+	// Apply applies a valid event to the read model of event.Tenant.
+	Apply(event *Event) error_utils.MessageErr
+}
+
+// HandlerFunc handles one event, as the dispatcher and every middleware do.
+type HandlerFunc func(event *Event) error_utils.MessageErr
+
+// Middleware wraps the handling of every event, to act before the event is
+// handled, after, or instead. The events of a batch go through it
+// concurrently, so it must be safe for concurrent use.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Dispatcher hands each event to the handler registered for its type,
+// through the middlewares in the order they were added.
+type Dispatcher struct {
+	mu          sync.RWMutex
+	handlers    map[string]EventHandler
+	middlewares []Middleware
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{handlers: make(map[string]EventHandler)}
+}
+
+// Register makes handler handle the events of eventType, in place of the
+// handler registered before, if any.
+func (d *Dispatcher) Register(eventType string, handler EventHandler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[eventType] = handler
+}
+
+// Use adds middlewares; the first one added sees events first.
+func (d *Dispatcher) Use(middlewares ...Middleware) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.middlewares = append(d.middlewares, middlewares...)
+}
+
+// Label is the type an event counts under: its own when a handler is
+// registered for it, InvalidType when it could not be read and UnknownType
+// otherwise.
+func (d *Dispatcher) Label(event *Event) string {
+	if event.decodeErr != nil {
+		return InvalidType
+	}
+	if _, ok := d.handler(event.Type); ok {
+		return event.Type
+	}
+	return UnknownType
+}
+
+// Dispatch handles one event. Its tenant is normalized first; the handler
+// only applies events it validated.
+func (d *Dispatcher) Dispatch(event *Event) error_utils.MessageErr {
+	return d.chain(d.apply)(event)
+}
+
+// chain wraps last in the middlewares.
+func (d *Dispatcher) chain(last HandlerFunc) HandlerFunc {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	next := last
+	for i := len(d.middlewares) - 1; i >= 0; i-- {
+		next = d.middlewares[i](next)
+	}
+	return next
+}
+
+func (d *Dispatcher) handler(eventType string) (EventHandler, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	handler, ok := d.handlers[eventType]
+	return handler, ok
+}
+
+func (d *Dispatcher) apply(event *Event) error_utils.MessageErr {
+	handler, err := d.prepare(event)
+	if err != nil {
+		return err
+	}
+	return handler.Apply(event)
+}
+
+// prepare finds the handler of an event, normalizes its tenant and has the
+// handler validate it.
+func (d *Dispatcher) prepare(event *Event) (EventHandler, error_utils.MessageErr) {
+	if event.decodeErr != nil {
+		return nil, event.decodeErr
+	}
+	handler, ok := d.handler(event.Type)
+	if !ok {
+		return nil, error_utils.NewBadRequestError("unknown event type").WithCode(error_utils.CodeUnknownEventType).WithDetail(event.Type)
+	}
+	tenant, err := domain.NormalizeTenant(event.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	event.Tenant = tenant
+	if err := handler.Validate(event); err != nil {
+		return nil, err
+	}
+	return handler, nil
+}
@@ -0,0 +1,88 @@
+package events
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing-project/utils/error_utils"
+)
+
+type recordingHandler struct {
+	validateErr error_utils.MessageErr
+	applied     []*Event
+}
+
+func (h *recordingHandler) Validate(*Event) error_utils.MessageErr {
+	return h.validateErr
+}
+
+func (h *recordingHandler) Apply(event *Event) error_utils.MessageErr {
+	h.applied = append(h.applied, event)
+	return nil
+}
+
+func TestDispatch_Applies_With_Normalized_Tenant(t *testing.T) {
+	handler := &recordingHandler{}
+	d := NewDispatcher()
+	d.Register("created", handler)
+
+	err := d.Dispatch(&Event{Type: "created", Tenant: " team-a "})
+
+	assert.Nil(t, err)
+	assert.Len(t, handler.applied, 1)
+	assert.EqualValues(t, "team-a", handler.applied[0].Tenant)
+}
+
+func TestDispatch_Unknown_Type(t *testing.T) {
+	d := NewDispatcher()
+
+	err := d.Dispatch(&Event{Type: "archived"})
+
+	assert.True(t, errors.Is(err, error_utils.ErrBadRequest))
+	assert.EqualValues(t, error_utils.CodeUnknownEventType, err.Code())
+	assert.EqualValues(t, "archived", err.Detail())
+	assert.EqualValues(t, UnknownType, d.Label(&Event{Type: "archived"}))
+}
+
+func TestDispatch_Invalid_Event_Is_Not_Applied(t *testing.T) {
+	handler := &recordingHandler{validateErr: error_utils.NewUnprocessibleEntityError("Please enter a valid title")}
+	d := NewDispatcher()
+	d.Register("created", handler)
+
+	err := d.Dispatch(&Event{Type: "created"})
+
+	assert.EqualValues(t, "Please enter a valid title", err.Message())
+	assert.Empty(t, handler.applied)
+}
+
+func TestDispatch_Middlewares_In_Order(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(event *Event) error_utils.MessageErr {
+				calls = append(calls, name+" before")
+				err := next(event)
+				calls = append(calls, name+" after")
+				return err
+			}
+		}
+	}
+	d := NewDispatcher()
+	d.Register("created", &recordingHandler{})
+	d.Use(trace("outer"), trace("inner"))
+
+	assert.Nil(t, d.Dispatch(&Event{Type: "created"}))
+	assert.Equal(t, []string{"outer before", "inner before", "inner after", "outer after"}, calls)
+}
+
+func TestDispatch_Middleware_Can_Skip_Handler(t *testing.T) {
+	handler := &recordingHandler{}
+	d := NewDispatcher()
+	d.Register("created", handler)
+	d.Use(func(next HandlerFunc) HandlerFunc {
+		return func(*Event) error_utils.MessageErr { return nil }
+	})
+
+	assert.Nil(t, d.Dispatch(&Event{Type: "created"}))
+	assert.Empty(t, handler.applied)
+}
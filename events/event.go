@@ -0,0 +1,115 @@
+package events
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+// HeaderTenant is the AMQP header naming the tenant of an event. It takes
+// precedence over the "tenant" field of the envelope.
+const HeaderTenant = "tenant_id"
+
+// Event is one event of the queue: {"event": type, "tenant": ..., "data": ...}.
+// Data is left for the event type's handler to decode.
+type Event struct {
+	Type      string
+	Tenant    string
+	Data      json.RawMessage
+	MessageId string
+	Body      []byte
+
+	// Duplicate is set when the event was skipped as a redelivery of one
+	// already applied.
+	Duplicate bool
+
+	decodeErr error_utils.MessageErr
+	message   *domain.Message
+}
+
+// Decode reads the envelope of an event delivered with the given headers
+// and AMQP message id. The event is returned even when the envelope cannot
+// be read, with the body, message id and header tenant set; dispatching it
+// then fails with the same error.
+func Decode(body []byte, headers map[string]interface{}, messageId string) (*Event, error_utils.MessageErr) {
+	event := &Event{MessageId: messageId, Body: body}
+	var envelope struct {
+		Event  string          `json:"event"`
+		Tenant string          `json:"tenant"`
+		Data   json.RawMessage `json:"data"`
+	}
+	err := json.Unmarshal(body, &envelope)
+	if err == nil {
+		event.Type = envelope.Event
+		event.Tenant = envelope.Tenant
+		event.Data = envelope.Data
+	}
+	if value, ok := headers[HeaderTenant].(string); ok && value != "" {
+		event.Tenant = value
+	}
+	if err != nil {
+		event.decodeErr = error_utils.NewBadRequestError("invalid event body").WithCode(error_utils.CodeInvalidEvent).Wrap(err)
+		return event, event.decodeErr
+	}
+	return event, nil
+}
+
+// DecodeBatch reads a delivery holding a batch of events, {"events": [...]},
+// into its items. Each item is decoded as Decode does, with the delivery's
+// headers and, when the delivery has a message id, "<message id>#<index>" as
+// its own. batch is false when the delivery holds a single event.
+func DecodeBatch(body []byte, headers map[string]interface{}, messageId string) (items []*Event, batch bool) {
+	var envelope struct {
+		Events []json.RawMessage `json:"events"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil || envelope.Events == nil {
+		return nil, false
+	}
+	items = make([]*Event, len(envelope.Events))
+	for i, raw := range envelope.Events {
+		itemId := ""
+		if messageId != "" {
+			itemId = messageId + "#" + strconv.Itoa(i)
+		}
+		items[i], _ = Decode(raw, headers, itemId)
+	}
+	return items, true
+}
+
+// DedupKey identifies the event among its redeliveries: its AMQP message id.
+// An event published without one has no key unless hashBody, when a hash of
+// its body stands in; two events alike, such as a second "deleted" for a
+// re-created message, then share it.
+func (e *Event) DedupKey(hashBody bool) string {
+	if e.MessageId != "" {
+		return "id:" + e.MessageId
+	}
+	if !hashBody {
+		return ""
+	}
+	sum := sha256.Sum256(e.Body)
+	return "body:" + hex.EncodeToString(sum[:])
+}
+
+// Message decodes the data of an event about one message, which must at
+// least carry the message id.
+func (e *Event) Message() (*domain.Message, error_utils.MessageErr) {
+	if e.message != nil {
+		return e.message, nil
+	}
+	if len(e.Data) == 0 || string(e.Data) == "null" {
+		return nil, error_utils.NewBadRequestError("event has no data").WithCode(error_utils.CodeInvalidEvent)
+	}
+	var msg domain.Message
+	if err := json.Unmarshal(e.Data, &msg); err != nil {
+		return nil, error_utils.NewBadRequestError("invalid event data").WithCode(error_utils.CodeInvalidEvent).Wrap(err)
+	}
+	if msg.Id <= 0 {
+		return nil, error_utils.NewBadRequestError("event data has no message id").WithCode(error_utils.CodeInvalidEvent)
+	}
+	e.message = &msg
+	return e.message, nil
+}
@@ -0,0 +1,50 @@
+package events
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing-project/utils/error_utils"
+)
+
+func TestDecode(t *testing.T) {
+	event, err := Decode([]byte(`{"event":"created","tenant":"team-a","data":{"id":7,"title":"t","body":"b"}}`), nil, "event-1")
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, "created", event.Type)
+	assert.EqualValues(t, "team-a", event.Tenant)
+	assert.EqualValues(t, "event-1", event.MessageId)
+	msg, msgErr := event.Message()
+	assert.Nil(t, msgErr)
+	assert.EqualValues(t, 7, msg.Id)
+}
+
+func TestDecode_Header_Tenant_Wins(t *testing.T) {
+	event, err := Decode([]byte(`{"event":"created","tenant":"team-a"}`), map[string]interface{}{HeaderTenant: "team-b"}, "")
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, "team-b", event.Tenant)
+}
+
+func TestDecode_Invalid_Body(t *testing.T) {
+	event, err := Decode([]byte("not json"), map[string]interface{}{HeaderTenant: "team-b"}, "event-1")
+
+	assert.True(t, errors.Is(err, error_utils.ErrBadRequest))
+	assert.EqualValues(t, error_utils.CodeInvalidEvent, err.Code())
+	assert.EqualValues(t, "team-b", event.Tenant)
+	assert.EqualValues(t, "event-1", event.MessageId)
+}
+
+func TestEventMessage_Invalid(t *testing.T) {
+	for data, message := range map[string]string{
+		``:            "event has no data",
+		`null`:        "event has no data",
+		`"text"`:      "invalid event data",
+		`{"title":1}`: "invalid event data",
+		`{"id":0}`:    "event data has no message id",
+	} {
+		_, err := (&Event{Data: []byte(data)}).Message()
+		assert.EqualValues(t, message, err.Message(), data)
+		assert.EqualValues(t, error_utils.CodeInvalidEvent, err.Code(), data)
+	}
+}
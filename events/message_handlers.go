@@ -0,0 +1,140 @@
+package events
+
+import (
+	"log"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+// RegisterMessageHandlers registers the handlers of the message events.
+// With softDelete, "deleted" events mark messages instead of removing them.
+func RegisterMessageHandlers(d *Dispatcher, softDelete bool) {
+	d.Register(domain.EventCreated, saveHandler{})
+	d.Register(domain.EventUpdated, saveHandler{})
+	d.Register(domain.EventDeleted, deleteHandler{soft: softDelete})
+	d.Register(domain.EventRestored, restoreHandler{})
+}
+
+// saveHandler stores the message in the data of the event as it now is.
+type saveHandler struct{}
+
+func (saveHandler) Validate(event *Event) error_utils.MessageErr {
+	msg, err := event.Message()
+	if err != nil {
+		return err
+	}
+	return msg.Validate()
+}
+
+func (saveHandler) Apply(event *Event) error_utils.MessageErr {
+	msg, _ := event.Message()
+	if err := domain.MessageRepo.ForTenant(event.Tenant).Save(msg); err != nil {
+		return err
+	}
+	publishChange(event.Tenant, event.Type, msg.Id, msg)
+	return nil
+}
+
+func (saveHandler) Stage(event *Event) domain.MessageWrite {
+	msg, _ := event.Message()
+	return domain.MessageWrite{Op: domain.WriteSave, Tenant: event.Tenant, Message: msg}
+}
+
+func (saveHandler) Committed(event *Event, result domain.MessageWriteResult) {
+	publishChange(event.Tenant, event.Type, result.Message.Id, result.Message)
+}
+
+// deleteHandler removes, or with soft marks, the message the event names.
+type deleteHandler struct {
+	soft bool
+}
+
+func (deleteHandler) Validate(event *Event) error_utils.MessageErr {
+	_, err := event.Message()
+	return err
+}
+
+func (h deleteHandler) Apply(event *Event) error_utils.MessageErr {
+	msg, _ := event.Message()
+	repo := domain.MessageRepo.ForTenant(event.Tenant)
+	deleteMessage := repo.Delete
+	if h.soft {
+		deleteMessage = repo.SoftDelete
+	}
+	if err := deleteMessage(msg.Id); err != nil {
+		return err
+	}
+	publishChange(event.Tenant, event.Type, msg.Id, nil)
+	return nil
+}
+
+func (h deleteHandler) Stage(event *Event) domain.MessageWrite {
+	msg, _ := event.Message()
+	op := domain.WriteDelete
+	if h.soft {
+		op = domain.WriteSoftDelete
+	}
+	return domain.MessageWrite{Op: op, Tenant: event.Tenant, MessageId: msg.Id}
+}
+
+func (deleteHandler) Committed(event *Event, _ domain.MessageWriteResult) {
+	msg, _ := event.Message()
+	publishChange(event.Tenant, event.Type, msg.Id, nil)
+}
+
+// restoreHandler brings back the soft-deleted message the event names.
+type restoreHandler struct{}
+
+func (restoreHandler) Validate(event *Event) error_utils.MessageErr {
+	_, err := event.Message()
+	return err
+}
+
+func (restoreHandler) Apply(event *Event) error_utils.MessageErr {
+	msg, _ := event.Message()
+	restored, err := domain.MessageRepo.ForTenant(event.Tenant).Restore(msg.Id)
+	if err != nil {
+		return err
+	}
+	publishChange(event.Tenant, event.Type, restored.Id, restored)
+	return nil
+}
+
+func (restoreHandler) Stage(event *Event) domain.MessageWrite {
+	msg, _ := event.Message()
+	return domain.MessageWrite{Op: domain.WriteRestore, Tenant: event.Tenant, MessageId: msg.Id}
+}
+
+func (restoreHandler) Committed(event *Event, result domain.MessageWriteResult) {
+	publishChange(event.Tenant, event.Type, result.Message.Id, result.Message)
+}
+
+// publishChange invalidates cached copies of the message, notifies stream
+// clients on every replica and the webhook subscribers about an event that
+// has just been applied, and records it in the message's history. A failure
+// here does not undo the applied change.
+func publishChange(tenant, event string, messageId int64, msg *domain.Message) {
+	services.MessageCache.Invalidate(tenant, messageId)
+	change := &domain.MessageChange{
+		Event:     event,
+		Tenant:    tenant,
+		MessageId: messageId,
+		Data:      msg,
+		AppliedAt: time.Now().UTC(),
+	}
+	if err := domain.ChangeFeed.Publish(change); err != nil {
+		log.Printf("Failed to publish change: %s", err.Message())
+	}
+	version := &domain.MessageVersion{
+		Event:     event,
+		ChangeId:  change.Id,
+		Data:      msg,
+		AppliedAt: change.AppliedAt,
+	}
+	if err := domain.HistoryRepo.Append(tenant, messageId, version); err != nil {
+		log.Printf("Failed to record message history: %s", err.Message())
+	}
+	services.WebhooksService.Dispatch(*change)
+}
@@ -0,0 +1,95 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+// feedClient is the Redis of the latest newMessageDispatcher.
+var feedClient *redis.Client
+
+func newMessageDispatcher(t *testing.T, softDelete bool) *Dispatcher {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	feedClient = client
+	domain.MessageRepo = domain.NewMessageRepository(client)
+	domain.ChangeFeed = domain.NewChangeFeed(client, 0)
+	domain.HistoryRepo = domain.NewHistoryRepository(client, 0, 0)
+	domain.WebhookRepo = domain.NewWebhookRepository(client)
+	domain.CacheInvalidations = domain.NewCacheInvalidations(client)
+	d := NewDispatcher()
+	RegisterMessageHandlers(d, softDelete)
+	return d
+}
+
+// publishedChanges returns every change in the feed.
+func publishedChanges(t *testing.T) []domain.MessageChange {
+	entries, err := feedClient.XRange(context.Background(), "messages:changes", "-", "+").Result()
+	assert.Nil(t, err)
+	changes := make([]domain.MessageChange, len(entries))
+	for i, entry := range entries {
+		assert.Nil(t, json.Unmarshal([]byte(entry.Values["change"].(string)), &changes[i]))
+	}
+	return changes
+}
+
+func dispatch(d *Dispatcher, body string) error_utils.MessageErr {
+	event, err := Decode([]byte(body), nil, "")
+	if err != nil {
+		return err
+	}
+	return d.Dispatch(event)
+}
+
+func TestMessageHandlers_Created_Then_Deleted(t *testing.T) {
+	d := newMessageDispatcher(t, false)
+
+	assert.Nil(t, dispatch(d, `{"event":"created","tenant":"team-a","data":{"id":1,"title":"hello","body":"world"}}`))
+	msg, err := domain.MessageRepo.ForTenant("team-a").Get(1)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "hello", msg.Title)
+	changes := publishedChanges(t)
+	assert.Len(t, changes, 1)
+
+	assert.Nil(t, dispatch(d, `{"event":"deleted","tenant":"team-a","data":{"id":1}}`))
+	_, err = domain.MessageRepo.ForTenant("team-a").Get(1)
+	assert.True(t, errors.Is(err, error_utils.ErrNotFound))
+	assert.Len(t, publishedChanges(t), 2)
+	versions, err := domain.HistoryRepo.GetAll("team-a", 1)
+	assert.Nil(t, err)
+	assert.Len(t, versions, 2)
+	assert.EqualValues(t, domain.EventDeleted, versions[1].Event)
+	assert.Nil(t, versions[1].Data)
+}
+
+func TestMessageHandlers_Soft_Delete_And_Restore(t *testing.T) {
+	d := newMessageDispatcher(t, true)
+	assert.Nil(t, dispatch(d, `{"event":"created","data":{"id":1,"title":"hello","body":"world"}}`))
+
+	assert.Nil(t, dispatch(d, `{"event":"deleted","data":{"id":1}}`))
+	msg, err := domain.MessageRepo.Get(1)
+	assert.Nil(t, err)
+	assert.True(t, msg.IsDeleted())
+
+	assert.Nil(t, dispatch(d, `{"event":"restored","data":{"id":1}}`))
+	msg, err = domain.MessageRepo.Get(1)
+	assert.Nil(t, err)
+	assert.False(t, msg.IsDeleted())
+}
+
+func TestMessageHandlers_Created_Without_Title(t *testing.T) {
+	d := newMessageDispatcher(t, false)
+
+	err := dispatch(d, `{"event":"created","data":{"id":1,"title":" ","body":"world"}}`)
+
+	assert.EqualValues(t, "Please enter a valid title", err.Message())
+	_, getErr := domain.MessageRepo.Get(1)
+	assert.True(t, errors.Is(getErr, error_utils.ErrNotFound))
+}
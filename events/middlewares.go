@@ -0,0 +1,60 @@
+package events
+
+import (
+	"log"
+	"testing-project/utils/error_utils"
+)
+
+// Logging logs every event and whether it was applied.
+func Logging() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(event *Event) error_utils.MessageErr {
+			log.Printf("Received: %s", event.Body)
+			err := next(event)
+			if event.Duplicate {
+				log.Printf("Skipped duplicate %s event %s", event.Type, event.DedupKey(true))
+			} else if err != nil {
+				log.Printf("Failed to apply %s event: %s: %s", event.Type, err.Message(), err.Detail())
+			} else {
+				log.Printf("Applied %s event for tenant %q", event.Type, event.Tenant)
+			}
+			return err
+		}
+	}
+}
+
+// ProcessedEvents remembers which events were applied.
+type ProcessedEvents interface {
+	Seen(key string) (bool, error_utils.MessageErr)
+	Mark(key string) error_utils.MessageErr
+}
+
+// Dedup skips events processed has seen applied, flagging them Duplicate,
+// and records those applied. Events without a message id are always applied
+// unless hashBodies, which keys them by their body. When processed cannot be
+// reached the event is applied anyway: delivery is at least once.
+func Dedup(processed ProcessedEvents, hashBodies bool) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(event *Event) error_utils.MessageErr {
+			key := event.DedupKey(hashBodies)
+			if key == "" {
+				return next(event)
+			}
+			seen, err := processed.Seen(key)
+			if err != nil {
+				log.Printf("Failed to check event %s for duplicates: %s: %s", key, err.Message(), err.Detail())
+			}
+			if seen {
+				event.Duplicate = true
+				return nil
+			}
+			if err := next(event); err != nil {
+				return err
+			}
+			if err := processed.Mark(key); err != nil {
+				log.Printf("Failed to record event %s as processed: %s: %s", key, err.Message(), err.Detail())
+			}
+			return nil
+		}
+	}
+}
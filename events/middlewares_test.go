@@ -0,0 +1,89 @@
+package events
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"testing-project/utils/error_utils"
+)
+
+type memoryProcessed struct {
+	mu      sync.Mutex
+	keys    map[string]bool
+	seenErr error_utils.MessageErr
+}
+
+func (m *memoryProcessed) Seen(key string) (bool, error_utils.MessageErr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.keys[key], m.seenErr
+}
+
+func (m *memoryProcessed) Mark(key string) error_utils.MessageErr {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[key] = true
+	return nil
+}
+
+func TestDedup_Skips_Redelivery(t *testing.T) {
+	handler := &recordingHandler{}
+	d := NewDispatcher()
+	d.Register("created", handler)
+	d.Use(Dedup(&memoryProcessed{keys: map[string]bool{}}, false))
+
+	first := &Event{Type: "created", MessageId: "event-1"}
+	again := &Event{Type: "created", MessageId: "event-1"}
+	assert.Nil(t, d.Dispatch(first))
+	assert.Nil(t, d.Dispatch(again))
+
+	assert.Len(t, handler.applied, 1)
+	assert.False(t, first.Duplicate)
+	assert.True(t, again.Duplicate)
+}
+
+func TestDedup_Failed_Event_Is_Not_Marked(t *testing.T) {
+	processed := &memoryProcessed{keys: map[string]bool{}}
+	d := NewDispatcher()
+	d.Register("created", &recordingHandler{validateErr: error_utils.NewServiceUnavailableError("redis unavailable")})
+	d.Use(Dedup(processed, false))
+
+	assert.NotNil(t, d.Dispatch(&Event{Type: "created", MessageId: "event-1"}))
+	assert.Empty(t, processed.keys)
+}
+
+func TestDedup_Applies_When_Store_Fails(t *testing.T) {
+	handler := &recordingHandler{}
+	d := NewDispatcher()
+	d.Register("created", handler)
+	d.Use(Dedup(&memoryProcessed{keys: map[string]bool{}, seenErr: error_utils.NewServiceUnavailableError("redis unavailable")}, false))
+
+	assert.Nil(t, d.Dispatch(&Event{Type: "created", MessageId: "event-1"}))
+	assert.Len(t, handler.applied, 1)
+}
+
+func TestDedup_Without_Message_Id(t *testing.T) {
+	handler := &recordingHandler{}
+	d := NewDispatcher()
+	d.Register("created", handler)
+	d.Use(Dedup(&memoryProcessed{keys: map[string]bool{}}, false))
+
+	assert.Nil(t, d.Dispatch(&Event{Type: "created", Body: []byte("{}")}))
+	assert.Nil(t, d.Dispatch(&Event{Type: "created", Body: []byte("{}")}))
+	assert.Len(t, handler.applied, 2)
+
+	d = NewDispatcher()
+	d.Register("created", handler)
+	d.Use(Dedup(&memoryProcessed{keys: map[string]bool{}}, true))
+	again := &Event{Type: "created", Body: []byte("{}")}
+	assert.Nil(t, d.Dispatch(&Event{Type: "created", Body: []byte("{}")}))
+	assert.Nil(t, d.Dispatch(again))
+	assert.Len(t, handler.applied, 3)
+	assert.True(t, again.Duplicate)
+}
+
+func TestEventDedupKey(t *testing.T) {
+	assert.EqualValues(t, "id:event-1", (&Event{MessageId: "event-1", Body: []byte("{}")}).DedupKey(false))
+	assert.EqualValues(t, "", (&Event{Body: []byte("{}")}).DedupKey(false))
+	assert.EqualValues(t, "body:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a", (&Event{Body: []byte("{}")}).DedupKey(true))
+}
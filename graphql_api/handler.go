@@ -0,0 +1,51 @@
+package graphql_api
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"net/http"
+	"testing-project/middlewares"
+	"testing-project/utils/error_utils"
+)
+
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Handler serves GraphQL queries sent as a JSON POST body or, for simple
+// queries, as GET query parameters.
+func Handler(c *gin.Context) {
+	var req graphqlRequest
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				theErr := error_utils.NewBadRequestError("invalid variables")
+				middlewares.WriteError(c, theErr)
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		theErr := error_utils.NewBadRequestError("invalid json body")
+		middlewares.WriteError(c, theErr)
+		return
+	}
+	if req.Query == "" {
+		theErr := error_utils.NewBadRequestError("query is required")
+		middlewares.WriteError(c, theErr)
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         Schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        withRequest(c.Request.Context(), middlewares.TenantFrom(c)),
+	})
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,182 @@
+package graphql_api
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+var (
+	getAllMessagesService func() ([]domain.Message, error_utils.MessageErr)
+	getMessagesService    func(msgIds []int64) ([]domain.Message, error_utils.MessageErr)
+)
+
+type serviceMock struct{}
+
+func (sm *serviceMock) GetMessage(tenant string, msgId int64, includeDeleted bool) (*domain.Message, error_utils.MessageErr) {
+	return nil, error_utils.NewInternalServerError("GetMessage should not be called")
+}
+func (sm *serviceMock) GetAllMessages(tenant string, filter domain.MessageFilter, includeDeleted bool) ([]domain.Message, error_utils.MessageErr) {
+	return getAllMessagesService()
+}
+func (sm *serviceMock) GetMessages(tenant string, msgIds []int64) ([]domain.Message, error_utils.MessageErr) {
+	return getMessagesService(msgIds)
+}
+func (sm *serviceMock) EachMessageByCreated(tenant string, filter domain.MessageFilter, includeDeleted bool, fn func(*domain.Message) error) error_utils.MessageErr {
+	messages, err := getAllMessagesService()
+	if err != nil {
+		return err
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return domain.PositionOf(&messages[i]).Before(domain.PositionOf(&messages[j]))
+	})
+	for i := range messages {
+		if !filter.Matches(&messages[i]) {
+			continue
+		}
+		if err := fn(&messages[i]); err != nil {
+			return error_utils.NewInternalServerError(err.Error()).Wrap(err)
+		}
+	}
+	return nil
+}
+func (sm *serviceMock) EachMessage(tenant string, filter domain.MessageFilter, includeDeleted bool, fn func(*domain.Message) error) error_utils.MessageErr {
+	return error_utils.NewInternalServerError("EachMessage should not be called")
+}
+
+type statsServiceMock struct {
+	total int64
+}
+
+func (sm *statsServiceMock) GetStats(tenant, bucket string, from, to time.Time) (*domain.MessageStats, error_utils.MessageErr) {
+	return &domain.MessageStats{Total: sm.total}, nil
+}
+
+type graphqlResponse struct {
+	Data   map[string]interface{}   `json:"data"`
+	Errors []map[string]interface{} `json:"errors"`
+}
+
+func doQuery(t *testing.T, query string) graphqlResponse {
+	body, _ := json.Marshal(map[string]interface{}{"query": query})
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	rr := httptest.NewRecorder()
+	r.POST("/graphql", Handler)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	var resp graphqlResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid graphql response: %v", err)
+	}
+	return resp
+}
+
+func TestMessage_Batched(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	calls := 0
+	var requested []int64
+	getMessagesService = func(msgIds []int64) ([]domain.Message, error_utils.MessageErr) {
+		calls++
+		requested = msgIds
+		return []domain.Message{{Id: 1, Title: "first"}, {Id: 2, Title: "second"}}, nil
+	}
+
+	resp := doQuery(t, `{ a: message(id: "1") { id title } b: message(id: "2") { title } c: message(id: "3") { title } }`)
+
+	assert.Empty(t, resp.Errors)
+	assert.EqualValues(t, 1, calls)
+	assert.ElementsMatch(t, []int64{1, 2, 3}, requested)
+	assert.EqualValues(t, map[string]interface{}{"id": "1", "title": "first"}, resp.Data["a"])
+	assert.EqualValues(t, "second", resp.Data["b"].(map[string]interface{})["title"])
+	assert.Nil(t, resp.Data["c"])
+}
+
+func TestMessages_Paginated_And_Filtered(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	tm := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	getAllMessagesService = func() ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{
+			{Id: 3, Title: "Gamma", CreatedAt: tm.Add(3 * time.Hour)},
+			{Id: 1, Title: "Alpha", CreatedAt: tm.Add(time.Hour)},
+			{Id: 2, Title: "Beta", Body: "has alpha inside", CreatedAt: tm.Add(2 * time.Hour)},
+			{Id: 4, Title: "Delta", CreatedAt: tm.Add(-time.Hour)},
+		}, nil
+	}
+
+	resp := doQuery(t, `{ messages(first: 1, search: "ALPHA", createdAfter: "2024-01-01T00:00:00Z") {
+		totalCount edges { cursor node { id } } pageInfo { hasNextPage endCursor } } }`)
+
+	assert.Empty(t, resp.Errors)
+	connection := resp.Data["messages"].(map[string]interface{})
+	assert.EqualValues(t, 2, connection["totalCount"])
+	edges := connection["edges"].([]interface{})
+	assert.Len(t, edges, 1)
+	assert.EqualValues(t, "1", edges[0].(map[string]interface{})["node"].(map[string]interface{})["id"])
+	pageInfo := connection["pageInfo"].(map[string]interface{})
+	assert.EqualValues(t, true, pageInfo["hasNextPage"])
+
+	resp = doQuery(t, `{ messages(first: 5, search: "alpha", after: "`+pageInfo["endCursor"].(string)+`") {
+		edges { node { id } } pageInfo { hasNextPage hasPreviousPage } } }`)
+
+	assert.Empty(t, resp.Errors)
+	connection = resp.Data["messages"].(map[string]interface{})
+	edges = connection["edges"].([]interface{})
+	assert.Len(t, edges, 1)
+	assert.EqualValues(t, "2", edges[0].(map[string]interface{})["node"].(map[string]interface{})["id"])
+	assert.EqualValues(t, false, connection["pageInfo"].(map[string]interface{})["hasNextPage"])
+	assert.EqualValues(t, true, connection["pageInfo"].(map[string]interface{})["hasPreviousPage"])
+}
+
+func TestMessages_Stops_After_Page(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	services.StatsService = &statsServiceMock{total: 42}
+	tm := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	getAllMessagesService = func() ([]domain.Message, error_utils.MessageErr) {
+		messages := make([]domain.Message, 10)
+		for i := range messages {
+			messages[i] = domain.Message{Id: int64(i + 1), CreatedAt: tm.Add(time.Duration(i) * time.Minute)}
+		}
+		return messages, nil
+	}
+
+	resp := doQuery(t, `{ messages(first: 2) { totalCount edges { node { id } } pageInfo { hasNextPage } } }`)
+
+	assert.Empty(t, resp.Errors)
+	connection := resp.Data["messages"].(map[string]interface{})
+	assert.EqualValues(t, 42, connection["totalCount"])
+	assert.Len(t, connection["edges"], 2)
+	assert.EqualValues(t, true, connection["pageInfo"].(map[string]interface{})["hasNextPage"])
+}
+
+func TestMessages_Invalid_Cursor(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+
+	resp := doQuery(t, `{ messages(after: "nope") { totalCount } }`)
+
+	assert.NotEmpty(t, resp.Errors)
+	assert.EqualValues(t, "invalid cursor", resp.Errors[0]["message"])
+}
+
+func TestHandler_Missing_Query(t *testing.T) {
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/graphql", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/graphql", Handler)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+	assert.EqualValues(t, "query is required", apiErr.Message())
+}
@@ -0,0 +1,94 @@
+package graphql_api
+
+import (
+	"context"
+	"sync"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+type loaderKey struct{}
+
+type tenantKey struct{}
+
+// messageLoader collects every message id requested while one level of the
+// query is being resolved and fetches them with a single batched call the
+// first time any of the deferred results is needed.
+type messageLoader struct {
+	mu      sync.Mutex
+	tenant  string
+	current *loaderBatch
+}
+
+type loaderBatch struct {
+	once     sync.Once
+	ids      []int64
+	messages map[int64]*domain.Message
+	err      error_utils.MessageErr
+}
+
+// withRequest stores the tenant and a fresh loader for one GraphQL request.
+// Loaders never outlive a request, so cached results cannot leak between
+// tenants.
+func withRequest(ctx context.Context, tenant string) context.Context {
+	ctx = context.WithValue(ctx, tenantKey{}, tenant)
+	return context.WithValue(ctx, loaderKey{}, &messageLoader{tenant: tenant})
+}
+
+func tenantFrom(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+		return tenant
+	}
+	return domain.DefaultTenant
+}
+
+func loaderFrom(ctx context.Context) *messageLoader {
+	if loader, ok := ctx.Value(loaderKey{}).(*messageLoader); ok {
+		return loader
+	}
+	return &messageLoader{tenant: tenantFrom(ctx)}
+}
+
+// Load schedules id for the pending batch and returns a thunk that graphql-go
+// resolves after all sibling fields have been collected.
+func (l *messageLoader) Load(id int64) func() (interface{}, error) {
+	l.mu.Lock()
+	batch := l.current
+	if batch == nil {
+		batch = &loaderBatch{}
+		l.current = batch
+	}
+	batch.ids = append(batch.ids, id)
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		if l.current == batch {
+			l.current = nil
+		}
+		l.mu.Unlock()
+
+		batch.once.Do(func() { batch.fetch(l.tenant) })
+		if batch.err != nil {
+			return nil, batch.err
+		}
+		message, ok := batch.messages[id]
+		if !ok {
+			return nil, nil
+		}
+		return message, nil
+	}
+}
+
+func (b *loaderBatch) fetch(tenant string) {
+	messages, err := services.MessagesService.GetMessages(tenant, b.ids)
+	if err != nil {
+		b.err = err
+		return
+	}
+	b.messages = make(map[int64]*domain.Message, len(messages))
+	for i := range messages {
+		b.messages[messages[i].Id] = &messages[i]
+	}
+}
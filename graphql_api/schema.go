@@ -0,0 +1,236 @@
+package graphql_api
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/graphql-go/graphql"
+	"strconv"
+	"strings"
+	"testing-project/domain"
+	"testing-project/services"
+	"time"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	cursorPrefix    = "message:"
+)
+
+var messageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Message",
+	Fields: graphql.Fields{
+		"id": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.ID),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return strconv.FormatInt(p.Source.(*domain.Message).Id, 10), nil
+			},
+		},
+		"title": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*domain.Message).Title, nil
+			},
+		},
+		"body": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*domain.Message).Body, nil
+			},
+		},
+		"createdAt": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.DateTime),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(*domain.Message).CreatedAt, nil
+			},
+		},
+	},
+})
+
+var pageInfoType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PageInfo",
+	Fields: graphql.Fields{
+		"hasNextPage":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"hasPreviousPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"startCursor":     &graphql.Field{Type: graphql.String},
+		"endCursor":       &graphql.Field{Type: graphql.String},
+	},
+})
+
+var messageEdgeType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "MessageEdge",
+	Fields: graphql.Fields{
+		"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"node":   &graphql.Field{Type: graphql.NewNonNull(messageType)},
+	},
+})
+
+var messageConnectionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "MessageConnection",
+	Fields: graphql.Fields{
+		"edges":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(messageEdgeType)))},
+		"pageInfo":   &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+		"totalCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: resolveTotalCount},
+	},
+})
+
+var queryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Query",
+	Fields: graphql.Fields{
+		"message": &graphql.Field{
+			Type: messageType,
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+			},
+			Resolve: resolveMessage,
+		},
+		"messages": &graphql.Field{
+			Type: graphql.NewNonNull(messageConnectionType),
+			Args: graphql.FieldConfigArgument{
+				"first":        &graphql.ArgumentConfig{Type: graphql.Int},
+				"after":        &graphql.ArgumentConfig{Type: graphql.String},
+				"createdAfter": &graphql.ArgumentConfig{Type: graphql.DateTime},
+				"search":       &graphql.ArgumentConfig{Type: graphql.String},
+			},
+			Resolve: resolveMessages,
+		},
+	},
+})
+
+var Schema = newSchema()
+
+// newSchema builds the schema, which only fails when the types above are
+// wrong, so a broken schema stops the service from starting.
+func newSchema() graphql.Schema {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+	if err != nil {
+		panic("invalid GraphQL schema: " + err.Error())
+	}
+	return schema
+}
+
+// errPageFull stops reading messages once a page has all it needs.
+var errPageFull = errors.New("page full")
+
+func resolveMessage(p graphql.ResolveParams) (interface{}, error) {
+	id, err := strconv.ParseInt(p.Args["id"].(string), 10, 64)
+	if err != nil {
+		return nil, errors.New("message id should be a number")
+	}
+	return loaderFrom(p.Context).Load(id), nil
+}
+
+// resolveMessages reads the page in the order of the created-at index,
+// from the cursor on, and stops one message past it to know whether there
+// is a next page.
+func resolveMessages(p graphql.ResolveParams) (interface{}, error) {
+	first := defaultPageSize
+	if value, ok := p.Args["first"].(int); ok {
+		if value < 0 || value > maxPageSize {
+			return nil, errors.New("first should be between 0 and " + strconv.Itoa(maxPageSize))
+		}
+		first = value
+	}
+	var filter domain.MessageFilter
+	if createdAfter, ok := p.Args["createdAfter"].(time.Time); ok {
+		filter.CreatedAfter = &createdAfter
+	}
+	if search, ok := p.Args["search"].(string); ok {
+		filter.Search = strings.TrimSpace(search)
+	}
+	paged := filter
+	if value, ok := p.Args["after"].(string); ok && value != "" {
+		after, err := decodeCursor(value)
+		if err != nil {
+			return nil, err
+		}
+		paged.After = &after
+	}
+
+	messages := make([]domain.Message, 0, first+1)
+	err := services.MessagesService.EachMessageByCreated(tenantFrom(p.Context), paged, false, func(message *domain.Message) error {
+		messages = append(messages, *message)
+		if len(messages) > first {
+			return errPageFull
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errPageFull) {
+		return nil, err
+	}
+	hasNextPage := len(messages) > first
+	if hasNextPage {
+		messages = messages[:first]
+	}
+
+	edges := make([]map[string]interface{}, 0, len(messages))
+	for i := range messages {
+		edges = append(edges, map[string]interface{}{
+			"cursor": encodeCursor(domain.PositionOf(&messages[i])),
+			"node":   &messages[i],
+		})
+	}
+	pageInfo := map[string]interface{}{
+		"hasNextPage":     hasNextPage,
+		"hasPreviousPage": paged.After != nil,
+	}
+	if len(edges) > 0 {
+		pageInfo["startCursor"] = edges[0]["cursor"]
+		pageInfo["endCursor"] = edges[len(edges)-1]["cursor"]
+	}
+	return map[string]interface{}{
+		"edges":    edges,
+		"pageInfo": pageInfo,
+		"filter":   filter,
+	}, nil
+}
+
+// resolveTotalCount counts the messages of the listing only when the query
+// asks for it: from the statistics counters when it is not filtered and by
+// walking the index, without keeping the messages, when it is.
+func resolveTotalCount(p graphql.ResolveParams) (interface{}, error) {
+	tenant := tenantFrom(p.Context)
+	filter := p.Source.(map[string]interface{})["filter"].(domain.MessageFilter)
+	if filter.IsEmpty() {
+		now := time.Now()
+		stats, err := services.StatsService.GetStats(tenant, domain.StatsBucketDay, now, now)
+		if err != nil {
+			return nil, err
+		}
+		return int(stats.Total), nil
+	}
+
+	count := 0
+	err := services.MessagesService.EachMessageByCreated(tenant, filter, false, func(*domain.Message) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return count, nil
+}
+
+func encodeCursor(position domain.MessagePosition) string {
+	raw := cursorPrefix + strconv.FormatInt(position.CreatedAt, 10) + ":" + strconv.FormatInt(position.Id, 10)
+	return base64.StdEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (domain.MessagePosition, error) {
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return domain.MessagePosition{}, errors.New("invalid cursor")
+	}
+	createdAt, id, ok := strings.Cut(strings.TrimPrefix(string(raw), cursorPrefix), ":")
+	var position domain.MessagePosition
+	if ok {
+		position.CreatedAt, err = strconv.ParseInt(createdAt, 10, 64)
+		if err == nil {
+			position.Id, err = strconv.ParseInt(id, 10, 64)
+		}
+	}
+	if !ok || err != nil {
+		return domain.MessagePosition{}, errors.New("invalid cursor")
+	}
+	return position, nil
+}
@@ -0,0 +1,71 @@
+package grpc_api
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"net/http"
+	"net/url"
+	"testing-project/middlewares"
+	"testing-project/utils/error_utils"
+)
+
+// credentialHeaders are the metadata keys handed to the REST authenticators.
+var credentialHeaders = []string{"authorization", "x-api-key"}
+
+type principalKey struct{}
+
+// unaryAuth and streamAuth authenticate every call with the same
+// authenticators and scope as the REST read API.
+func unaryAuth(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := authenticate(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return handler(ctx, req)
+}
+
+func streamAuth(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := authenticate(stream.Context())
+	if err != nil {
+		return toStatus(err)
+	}
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate returns ctx carrying the caller's principal. Calls pass
+// untouched while authentication is disabled.
+func authenticate(ctx context.Context) (context.Context, error_utils.MessageErr) {
+	if !middlewares.AuthEnabled() {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	r := &http.Request{Header: http.Header{}, URL: &url.URL{}}
+	for _, key := range credentialHeaders {
+		if values := md.Get(key); len(values) > 0 {
+			r.Header.Set(key, values[0])
+		}
+	}
+	principal, err := middlewares.Authenticate(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := middlewares.Authorize(principal, middlewares.ScopeMessagesRead); err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, principalKey{}, principal), nil
+}
+
+func principalFrom(ctx context.Context) *middlewares.Principal {
+	principal, _ := ctx.Value(principalKey{}).(*middlewares.Principal)
+	return principal
+}
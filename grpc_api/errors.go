@@ -0,0 +1,47 @@
+package grpc_api
+
+import (
+	"errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"testing-project/utils/error_utils"
+)
+
+// codesByKind is checked in order; the first kind the error matches wins.
+var codesByKind = []struct {
+	kind error
+	code codes.Code
+}{
+	{error_utils.ErrBadRequest, codes.InvalidArgument},
+	{error_utils.ErrInvalid, codes.InvalidArgument},
+	{error_utils.ErrUnauthorized, codes.Unauthenticated},
+	{error_utils.ErrForbidden, codes.PermissionDenied},
+	{error_utils.ErrNotFound, codes.NotFound},
+	{error_utils.ErrNotAcceptable, codes.InvalidArgument},
+	{error_utils.ErrConflict, codes.AlreadyExists},
+	{error_utils.ErrRateLimited, codes.ResourceExhausted},
+	{error_utils.ErrUnavailable, codes.Unavailable},
+	{error_utils.ErrTimeout, codes.DeadlineExceeded},
+	{error_utils.ErrInternal, codes.Internal},
+}
+
+// toStatus converts a MessageErr into a gRPC status so that REST and gRPC
+// clients see the same classification of every failure. The internal detail
+// is logged, not sent.
+func toStatus(err error_utils.MessageErr) error {
+	if detail := err.Detail(); detail != "" {
+		log.Printf("gRPC request failed: %s: %s", err.Message(), detail)
+	}
+	return status.Error(CodeFromError(err), err.Message())
+}
+
+// CodeFromError returns the gRPC code for the kind of err.
+func CodeFromError(err error) codes.Code {
+	for _, mapping := range codesByKind {
+		if errors.Is(err, mapping.kind) {
+			return mapping.code
+		}
+	}
+	return codes.Unknown
+}
@@ -0,0 +1,124 @@
+//go:generate protoc -I ../proto --go_out=../proto/messagespb --go_opt=paths=source_relative --go-grpc_out=../proto/messagespb --go-grpc_opt=paths=source_relative messages.proto
+
+package grpc_api
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing-project/domain"
+	"testing-project/middlewares"
+	"testing-project/proto/messagespb"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+const (
+	maxBatchSize = 1000
+	TenantHeader = "x-tenant-id"
+)
+
+type messagesReaderServer struct {
+	messagespb.UnimplementedMessagesReaderServer
+}
+
+func NewServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryAuth),
+		grpc.ChainStreamInterceptor(streamAuth),
+	}, opts...)
+	server := grpc.NewServer(opts...)
+	messagespb.RegisterMessagesReaderServer(server, &messagesReaderServer{})
+	return server
+}
+
+func (s *messagesReaderServer) GetMessage(ctx context.Context, req *messagespb.GetMessageRequest) (*messagespb.Message, error) {
+	tenant, err := tenantFrom(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	message, err := services.MessagesService.GetMessage(tenant, req.GetId(), false)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toProto(message), nil
+}
+
+func (s *messagesReaderServer) ListMessages(req *messagespb.ListMessagesRequest, stream messagespb.MessagesReader_ListMessagesServer) error {
+	tenant, err := tenantFrom(stream.Context())
+	if err != nil {
+		return toStatus(err)
+	}
+	// Each message is sent as it is read, so the set never has to be held in
+	// memory.
+	var sendErr error
+	err = services.MessagesService.EachMessage(tenant, domain.MessageFilter{}, false, func(message *domain.Message) error {
+		sendErr = stream.Send(toProto(message))
+		return sendErr
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		// An empty read model is an empty stream, not an error.
+		if errors.Is(err, error_utils.ErrNotFound) {
+			return nil
+		}
+		return toStatus(err)
+	}
+	return nil
+}
+
+func (s *messagesReaderServer) BatchGetMessages(ctx context.Context, req *messagespb.BatchGetMessagesRequest) (*messagespb.BatchGetMessagesResponse, error) {
+	ids := req.GetIds()
+	if len(ids) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d ids can be requested at once", maxBatchSize)
+	}
+	tenant, err := tenantFrom(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	messages, err := services.MessagesService.GetMessages(tenant, ids)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	found := make(map[int64]*domain.Message, len(messages))
+	for i := range messages {
+		found[messages[i].Id] = &messages[i]
+	}
+	resp := &messagespb.BatchGetMessagesResponse{}
+	for _, id := range ids {
+		if message, ok := found[id]; ok {
+			resp.Messages = append(resp.Messages, toProto(message))
+		} else {
+			resp.NotFoundIds = append(resp.NotFoundIds, id)
+		}
+	}
+	return resp, nil
+}
+
+// tenantFrom picks the tenant for the caller's principal, honouring the
+// x-tenant-id request metadata under the same rules as REST.
+func tenantFrom(ctx context.Context) (string, error_utils.MessageErr) {
+	var requested string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(TenantHeader); len(values) > 0 {
+			requested = values[0]
+		}
+	}
+	return middlewares.TenantFor(principalFrom(ctx), requested)
+}
+
+func toProto(message *domain.Message) *messagespb.Message {
+	return &messagespb.Message{
+		Id:        message.Id,
+		Title:     message.Title,
+		Body:      message.Body,
+		CreatedAt: timestamppb.New(message.CreatedAt),
+	}
+}
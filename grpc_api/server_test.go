@@ -0,0 +1,191 @@
+package grpc_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"testing"
+	"testing-project/domain"
+	"testing-project/middlewares"
+	"testing-project/proto/messagespb"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+var (
+	getMessageService     func(msgId int64) (*domain.Message, error_utils.MessageErr)
+	getAllMessagesService func() ([]domain.Message, error_utils.MessageErr)
+	getMessagesService    func(msgIds []int64) ([]domain.Message, error_utils.MessageErr)
+)
+
+type serviceMock struct{}
+
+func (sm *serviceMock) GetMessage(tenant string, msgId int64, includeDeleted bool) (*domain.Message, error_utils.MessageErr) {
+	return getMessageService(msgId)
+}
+func (sm *serviceMock) GetAllMessages(tenant string, filter domain.MessageFilter, includeDeleted bool) ([]domain.Message, error_utils.MessageErr) {
+	return nil, error_utils.NewInternalServerError("GetAllMessages should not be called")
+}
+func (sm *serviceMock) GetMessages(tenant string, msgIds []int64) ([]domain.Message, error_utils.MessageErr) {
+	return getMessagesService(msgIds)
+}
+func (sm *serviceMock) EachMessageByCreated(tenant string, filter domain.MessageFilter, includeDeleted bool, fn func(*domain.Message) error) error_utils.MessageErr {
+	return error_utils.NewInternalServerError("EachMessageByCreated should not be called")
+}
+func (sm *serviceMock) EachMessage(tenant string, filter domain.MessageFilter, includeDeleted bool, fn func(*domain.Message) error) error_utils.MessageErr {
+	messages, err := getAllMessagesService()
+	if err != nil {
+		return err
+	}
+	for i := range messages {
+		if err := fn(&messages[i]); err != nil {
+			return error_utils.NewInternalServerError(err.Error()).Wrap(err)
+		}
+	}
+	return nil
+}
+
+func newTestClient(t *testing.T) messagespb.MessagesReaderClient {
+	services.MessagesService = &serviceMock{}
+	listener := bufconn.Listen(1024 * 1024)
+	server := NewServer()
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial bufnet: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return messagespb.NewMessagesReaderClient(conn)
+}
+
+func TestGetMessage_Success(t *testing.T) {
+	client := newTestClient(t)
+	getMessageService = func(msgId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: msgId, Title: "the title", Body: "the body"}, nil
+	}
+
+	message, err := client.GetMessage(context.Background(), &messagespb.GetMessageRequest{Id: 7})
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 7, message.GetId())
+	assert.EqualValues(t, "the title", message.GetTitle())
+}
+
+func TestGetMessage_Requires_Credentials(t *testing.T) {
+	assert.Nil(t, middlewares.InitializeAuth(middlewares.HashApiKey("key")+"="+middlewares.ScopeMessagesRead, "", ""))
+	t.Cleanup(func() { middlewares.Authenticators = nil })
+	client := newTestClient(t)
+	getMessageService = func(msgId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: msgId}, nil
+	}
+
+	_, err := client.GetMessage(context.Background(), &messagespb.GetMessageRequest{Id: 7})
+	assert.EqualValues(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "key")
+	message, err := client.GetMessage(ctx, &messagespb.GetMessageRequest{Id: 7})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 7, message.GetId())
+}
+
+func TestListMessages_Tenant_Not_Allowed(t *testing.T) {
+	assert.Nil(t, middlewares.InitializeAuth(middlewares.HashApiKey("key")+"="+middlewares.ScopeMessagesRead, "", ""))
+	t.Cleanup(func() { middlewares.Authenticators = nil })
+	client := newTestClient(t)
+	getAllMessagesService = func() ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{{Id: 1}}, nil
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "key", TenantHeader, "team-a")
+	stream, err := client.ListMessages(ctx, &messagespb.ListMessagesRequest{})
+	assert.Nil(t, err)
+	_, err = stream.Recv()
+	assert.EqualValues(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestGetMessage_NotFound(t *testing.T) {
+	client := newTestClient(t)
+	getMessageService = func(msgId int64) (*domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewNotFoundError("message not found")
+	}
+
+	message, err := client.GetMessage(context.Background(), &messagespb.GetMessageRequest{Id: 7})
+
+	assert.Nil(t, message)
+	assert.EqualValues(t, codes.NotFound, status.Code(err))
+	assert.EqualValues(t, "message not found", status.Convert(err).Message())
+}
+
+func TestListMessages_Streams_All(t *testing.T) {
+	client := newTestClient(t)
+	getAllMessagesService = func() ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{{Id: 1}, {Id: 2}, {Id: 3}}, nil
+	}
+
+	stream, err := client.ListMessages(context.Background(), &messagespb.ListMessagesRequest{})
+	assert.Nil(t, err)
+
+	var ids []int64
+	for {
+		message, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		ids = append(ids, message.GetId())
+	}
+	assert.EqualValues(t, []int64{1, 2, 3}, ids)
+}
+
+func TestListMessages_Empty(t *testing.T) {
+	client := newTestClient(t)
+	getAllMessagesService = func() ([]domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewNotFoundError("no messages found")
+	}
+
+	stream, err := client.ListMessages(context.Background(), &messagespb.ListMessagesRequest{})
+	assert.Nil(t, err)
+
+	_, err = stream.Recv()
+	assert.EqualValues(t, io.EOF, err)
+}
+
+func TestBatchGetMessages_Reports_Missing(t *testing.T) {
+	client := newTestClient(t)
+	getMessagesService = func(msgIds []int64) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{{Id: 3}, {Id: 1}}, nil
+	}
+
+	resp, err := client.BatchGetMessages(context.Background(), &messagespb.BatchGetMessagesRequest{Ids: []int64{1, 2, 3}})
+
+	assert.Nil(t, err)
+	assert.Len(t, resp.GetMessages(), 2)
+	assert.EqualValues(t, 1, resp.GetMessages()[0].GetId())
+	assert.EqualValues(t, 3, resp.GetMessages()[1].GetId())
+	assert.EqualValues(t, []int64{2}, resp.GetNotFoundIds())
+}
+
+func TestCodeFromError(t *testing.T) {
+	assert.EqualValues(t, codes.InvalidArgument, CodeFromError(error_utils.NewBadRequestError("bad")))
+	assert.EqualValues(t, codes.NotFound, CodeFromError(error_utils.NewNotFoundError("missing")))
+	assert.EqualValues(t, codes.Internal, CodeFromError(error_utils.NewInternalServerError("broken")))
+	assert.EqualValues(t, codes.Unavailable, CodeFromError(error_utils.NewServiceUnavailableError("down")))
+	assert.EqualValues(t, codes.NotFound, CodeFromError(fmt.Errorf("lookup: %w", error_utils.NewNotFoundError("missing"))))
+	assert.EqualValues(t, codes.Unknown, CodeFromError(errors.New("plain")))
+}
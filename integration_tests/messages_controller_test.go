@@ -19,6 +19,9 @@ type mockMessageRepo struct {
 	mock.Mock
 }
 
+func (m *mockMessageRepo) ForTenant(tenant string) domain.MessageRepository {
+	return m
+}
 func (m *mockMessageRepo) Get(id int64) (*domain.Message, error_utils.MessageErr) {
 	args := m.Called(id)
 
@@ -49,14 +52,101 @@ func (m *mockMessageRepo) GetAll() ([]domain.Message, error_utils.MessageErr) {
 
 	return messages, err
 }
+func (m *mockMessageRepo) Each(fn func(*domain.Message) error) error_utils.MessageErr {
+	args := m.Called()
+
+	if args.Get(0) != nil {
+		for _, msg := range args.Get(0).([]domain.Message) {
+			msg := msg
+			if err := fn(&msg); err != nil {
+				return error_utils.NewInternalServerError(err.Error())
+			}
+		}
+	}
+
+	var err error_utils.MessageErr
+	if args.Get(1) != nil {
+		err = args.Get(1).(error_utils.MessageErr)
+	}
+
+	return err
+}
+func (m *mockMessageRepo) GetMany(ids []int64) ([]domain.Message, error_utils.MessageErr) {
+	args := m.Called(ids)
+
+	var messages []domain.Message
+	if args.Get(0) != nil {
+		messages = args.Get(0).([]domain.Message)
+	}
+
+	var err error_utils.MessageErr
+	if args.Get(1) != nil {
+		err = args.Get(1).(error_utils.MessageErr)
+	}
+
+	return messages, err
+}
+func (m *mockMessageRepo) Find(filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr) {
+	args := m.Called(filter)
+
+	var messages []domain.Message
+	if args.Get(0) != nil {
+		messages = args.Get(0).([]domain.Message)
+	}
+
+	var err error_utils.MessageErr
+	if args.Get(1) != nil {
+		err = args.Get(1).(error_utils.MessageErr)
+	}
+
+	return messages, err
+}
+func (m *mockMessageRepo) EachMatching(filter domain.MessageFilter, fn func(*domain.Message) error) error_utils.MessageErr {
+	args := m.Called(filter, fn)
+	if args.Get(0) != nil {
+		return args.Get(0).(error_utils.MessageErr)
+	}
+	return nil
+}
+func (m *mockMessageRepo) EachByCreated(filter domain.MessageFilter, fn func(*domain.Message) error) error_utils.MessageErr {
+	args := m.Called(filter, fn)
+	if args.Get(0) != nil {
+		return args.Get(0).(error_utils.MessageErr)
+	}
+	return nil
+}
 func (m *mockMessageRepo) Save(msg *domain.Message) error_utils.MessageErr {
 	args := m.Called(msg)
 	return args.Get(0).(error_utils.MessageErr)
 }
+func (m *mockMessageRepo) SaveIfAbsent(msg *domain.Message) (bool, error_utils.MessageErr) {
+	args := m.Called(msg)
+	return args.Bool(0), args.Get(1).(error_utils.MessageErr)
+}
 func (m *mockMessageRepo) Delete(id int64) error_utils.MessageErr {
 	args := m.Called(id)
 	return args.Get(0).(error_utils.MessageErr)
 }
+func (m *mockMessageRepo) SoftDelete(id int64) error_utils.MessageErr {
+	args := m.Called(id)
+	return args.Get(0).(error_utils.MessageErr)
+}
+func (m *mockMessageRepo) Restore(id int64) (*domain.Message, error_utils.MessageErr) {
+	args := m.Called(id)
+	return args.Get(0).(*domain.Message), args.Get(1).(error_utils.MessageErr)
+}
+func (m *mockMessageRepo) ApplyBatch(writes []domain.MessageWrite) ([]domain.MessageWriteResult, error_utils.MessageErr) {
+	args := m.Called(writes)
+	return args.Get(0).([]domain.MessageWriteResult), args.Get(1).(error_utils.MessageErr)
+}
+func (m *mockMessageRepo) PurgeDeleted(before time.Time) (map[string][]int64, error_utils.MessageErr) {
+	args := m.Called(before)
+	return args.Get(0).(map[string][]int64), args.Get(1).(error_utils.MessageErr)
+}
+func (m *mockMessageRepo) Reindex() (int, error_utils.MessageErr) {
+	args := m.Called()
+	return args.Int(0), args.Get(1).(error_utils.MessageErr)
+}
 func (m *mockMessageRepo) Initialize(a, b, c string) *redis.Client { return nil }
 
 func TestGetMessage_Success(t *testing.T) {
@@ -142,7 +232,7 @@ func TestGetAllMessages_Success(t *testing.T) {
 		},
 	}
 
-	mockRepo.On("GetAll").Return(expectedMessages, nil)
+	mockRepo.On("Each").Return(expectedMessages, nil)
 	domain.MessageRepo = mockRepo
 
 	req, _ := http.NewRequest(http.MethodGet, "/messages", nil)
@@ -168,7 +258,7 @@ func TestGetAllMessages_NotFound(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	mockRepo := new(mockMessageRepo)
-	mockRepo.On("GetAll").Return(nil, error_utils.NewNotFoundError("no messages found"))
+	mockRepo.On("Each").Return(nil, nil)
 	domain.MessageRepo = mockRepo
 
 	req, _ := http.NewRequest(http.MethodGet, "/messages", nil)
@@ -2,10 +2,14 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"testing-project/app"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		os.Exit(app.RunVerify(os.Args[2:]))
+	}
 	fmt.Println("Welcome to the app")
 	app.StartApp()
 }
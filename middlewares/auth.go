@@ -0,0 +1,307 @@
+package middlewares
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"log"
+	"net/http"
+	"strings"
+	"testing-project/utils/error_utils"
+)
+
+const (
+	ScopeMessagesRead = "messages:read"
+	ScopeAdmin        = "messages:admin"
+	// ScopeAnyTenant lets a principal that is not bound to a tenant pick one
+	// with X-Tenant-ID.
+	ScopeAnyTenant = "tenants:any"
+
+	principalKey = "principal"
+	tenantScope  = "tenant:"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject string
+	Method  string
+	Scopes  []string
+	Tenant  string
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator recognises one kind of credential. It returns (nil, nil) when
+// the request does not carry that kind of credential at all, so the next
+// authenticator can try.
+type Authenticator interface {
+	Authenticate(*http.Request) (*Principal, error_utils.MessageErr)
+}
+
+var (
+	Authenticators []Authenticator
+)
+
+// InitializeAuth configures the authenticators from their settings. When
+// nothing is configured the read API stays public, as it was before.
+func InitializeAuth(apiKeys, jwtSecret, jwksFile string) error {
+	Authenticators = nil
+	if apiKeys != "" {
+		authenticator, err := NewApiKeyAuthenticator(apiKeys)
+		if err != nil {
+			return err
+		}
+		Authenticators = append(Authenticators, authenticator)
+	}
+	if jwtSecret != "" || jwksFile != "" {
+		authenticator, err := NewJwtAuthenticator(jwtSecret, jwksFile)
+		if err != nil {
+			return err
+		}
+		Authenticators = append(Authenticators, authenticator)
+	}
+	if len(Authenticators) == 0 {
+		log.Print("No API keys or JWT settings configured, authentication is disabled and the admin routes refuse every request")
+	}
+	return nil
+}
+
+// RequireScopes rejects requests that are not authenticated (401) or whose
+// principal lacks one of the scopes (403). With authentication disabled,
+// requests pass unless ScopeAdmin is required: nobody can be told apart from
+// an admin then, so the admin routes fail closed.
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !AuthEnabled() {
+			for _, scope := range scopes {
+				if !openScope(scope) {
+					abortWithError(c, error_utils.NewUnauthorizedError("authentication is not configured").
+						WithDetail("scope "+scope+" needs API keys or JWT settings"))
+					return
+				}
+			}
+			c.Next()
+			return
+		}
+
+		principal, err := Authenticate(c.Request)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		if err := Authorize(principal, scopes...); err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// AuthEnabled reports whether any authenticator is configured.
+func AuthEnabled() bool {
+	return len(Authenticators) > 0
+}
+
+// Authenticate returns the principal of the first authenticator that
+// recognises the credentials of r, or a 401 when none does. It is shared by
+// the REST middleware and the gRPC interceptors.
+func Authenticate(r *http.Request) (*Principal, error_utils.MessageErr) {
+	for _, authenticator := range Authenticators {
+		principal, err := authenticator.Authenticate(r)
+		if err != nil {
+			return nil, err
+		}
+		if principal != nil {
+			return principal, nil
+		}
+	}
+	return nil, error_utils.NewUnauthorizedError("missing credentials")
+}
+
+// Authorize returns a 403 unless principal holds every one of scopes.
+func Authorize(principal *Principal, scopes ...string) error_utils.MessageErr {
+	for _, scope := range scopes {
+		if !principal.HasScope(scope) {
+			return error_utils.NewForbiddenError(fmt.Sprintf("missing scope %s", scope))
+		}
+	}
+	return nil
+}
+
+// PrincipalFrom returns the caller authenticated by RequireScopes, if any.
+func PrincipalFrom(c *gin.Context) *Principal {
+	if value, ok := c.Get(principalKey); ok {
+		return value.(*Principal)
+	}
+	return nil
+}
+
+// HasScope reports whether the caller holds scope. While authentication is
+// disabled every caller holds all scopes but ScopeAdmin.
+func HasScope(c *gin.Context, scope string) bool {
+	if !AuthEnabled() {
+		return openScope(scope)
+	}
+	principal := PrincipalFrom(c)
+	return principal != nil && principal.HasScope(scope)
+}
+
+// openScope tells whether every caller holds scope while authentication is
+// disabled, which is all but ScopeAdmin.
+func openScope(scope string) bool {
+	return scope != ScopeAdmin
+}
+
+func abortWithError(c *gin.Context, err error_utils.MessageErr) {
+	if errors.Is(err, error_utils.ErrUnauthorized) {
+		c.Header("WWW-Authenticate", `Bearer realm="reading-service"`)
+	}
+	WriteError(c, err)
+}
+
+// apiKeyAuthenticator accepts static keys sent as "X-API-Key: <key>". Only
+// SHA-256 hashes of the keys are kept in memory.
+type apiKeyAuthenticator struct {
+	keys map[string]apiKey
+}
+
+type apiKey struct {
+	scopes []string
+	tenant string
+}
+
+// NewApiKeyAuthenticator parses "<sha256 hex>=<scope> <scope>,..." entries.
+// A "tenant:<id>" entry among the scopes binds the key to that tenant.
+func NewApiKeyAuthenticator(config string) (Authenticator, error) {
+	keys := make(map[string]apiKey)
+	for _, entry := range strings.Split(config, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		hash, scopes, _ := strings.Cut(entry, "=")
+		hash = strings.ToLower(strings.TrimSpace(hash))
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("invalid api key hash %q", hash)
+		}
+		var key apiKey
+		for _, scope := range strings.Fields(scopes) {
+			if strings.HasPrefix(scope, tenantScope) {
+				key.tenant = strings.TrimPrefix(scope, tenantScope)
+			} else {
+				key.scopes = append(key.scopes, scope)
+			}
+		}
+		keys[hash] = key
+	}
+	return &apiKeyAuthenticator{keys: keys}, nil
+}
+
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *apiKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error_utils.MessageErr) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		return nil, nil
+	}
+	hash := HashApiKey(key)
+	for known, entry := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(known), []byte(hash)) == 1 {
+			return &Principal{Subject: "apikey:" + known[:8], Method: "api_key", Scopes: entry.scopes, Tenant: entry.tenant}, nil
+		}
+	}
+	return nil, error_utils.NewUnauthorizedError("invalid api key")
+}
+
+// jwtAuthenticator accepts "Authorization: Bearer <jwt>" signed either with
+// the shared HMAC secret or with a key from the local JWKS file. EventSource
+// and WebSocket clients that cannot set headers may pass ?access_token=.
+type jwtAuthenticator struct {
+	secret []byte
+	jwks   map[string]interface{}
+}
+
+func NewJwtAuthenticator(secret, jwksFile string) (Authenticator, error) {
+	authenticator := &jwtAuthenticator{secret: []byte(secret)}
+	if jwksFile != "" {
+		keys, err := loadJwks(jwksFile)
+		if err != nil {
+			return nil, err
+		}
+		authenticator.jwks = keys
+	}
+	return authenticator, nil
+}
+
+func (a *jwtAuthenticator) Authenticate(r *http.Request) (*Principal, error_utils.MessageErr) {
+	raw := ""
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		raw = strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	} else if token := r.URL.Query().Get("access_token"); token != "" {
+		raw = token
+	}
+	if raw == "" {
+		return nil, nil
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, a.keyFor,
+		jwt.WithValidMethods([]string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, error_utils.NewUnauthorizedError("invalid token")
+	}
+
+	subject, _ := claims.GetSubject()
+	tenant, _ := claims["tenant"].(string)
+	return &Principal{Subject: subject, Method: "jwt", Scopes: scopesFromClaims(claims), Tenant: tenant}, nil
+}
+
+func (a *jwtAuthenticator) keyFor(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if len(a.secret) == 0 {
+			return nil, fmt.Errorf("hmac tokens are not accepted")
+		}
+		return a.secret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	key, ok := a.jwks[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// scopesFromClaims reads the OAuth2 space-separated "scope" claim or the
+// "scp" array used by some identity providers.
+func scopesFromClaims(claims jwt.MapClaims) []string {
+	if scope, ok := claims["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+	var scopes []string
+	if scp, ok := claims["scp"].([]interface{}); ok {
+		for _, s := range scp {
+			if str, ok := s.(string); ok {
+				scopes = append(scopes, str)
+			}
+		}
+	}
+	return scopes
+}
@@ -0,0 +1,153 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+func serveWithAuth(t *testing.T, configure func(*http.Request)) *httptest.ResponseRecorder {
+	r := gin.Default()
+	r.GET("/messages", RequireScopes(ScopeMessagesRead), func(c *gin.Context) {
+		c.String(http.StatusOK, PrincipalFrom(c).Subject)
+	})
+	req, _ := http.NewRequest(http.MethodGet, "/messages", nil)
+	configure(req)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func assertApiErr(t *testing.T, rr *httptest.ResponseRecorder, status int, message string) {
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, status, apiErr.Status())
+	assert.EqualValues(t, message, apiErr.Message())
+}
+
+func signHS256(t *testing.T, secret string, claims jwt.MapClaims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestRequireScopes_Disabled(t *testing.T) {
+	Authenticators = nil
+	r := gin.Default()
+	r.GET("/messages", RequireScopes(ScopeMessagesRead), func(c *gin.Context) { c.Status(http.StatusOK) })
+	req, _ := http.NewRequest(http.MethodGet, "/messages", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+}
+
+func TestRequireScopes_Disabled_Admin(t *testing.T) {
+	Authenticators = nil
+	r := gin.Default()
+	r.GET("/admin/webhooks", RequireScopes(ScopeAdmin), func(c *gin.Context) { c.Status(http.StatusOK) })
+	req, _ := http.NewRequest(http.MethodGet, "/admin/webhooks", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestRequireScopes_Missing_Credentials(t *testing.T) {
+	assert.Nil(t, InitializeAuth(HashApiKey("key")+"="+ScopeMessagesRead, "", ""))
+
+	rr := serveWithAuth(t, func(req *http.Request) {})
+
+	assertApiErr(t, rr, http.StatusUnauthorized, "missing credentials")
+	assert.NotEmpty(t, rr.Header().Get("WWW-Authenticate"))
+}
+
+func TestRequireScopes_ApiKey(t *testing.T) {
+	assert.Nil(t, InitializeAuth(HashApiKey("reader")+"="+ScopeMessagesRead+","+HashApiKey("nobody")+"=", "", ""))
+
+	rr := serveWithAuth(t, func(req *http.Request) { req.Header.Set("X-API-Key", "reader") })
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+
+	rr = serveWithAuth(t, func(req *http.Request) { req.Header.Set("X-API-Key", "nobody") })
+	assertApiErr(t, rr, http.StatusForbidden, "missing scope messages:read")
+
+	rr = serveWithAuth(t, func(req *http.Request) { req.Header.Set("X-API-Key", "wrong") })
+	assertApiErr(t, rr, http.StatusUnauthorized, "invalid api key")
+}
+
+func TestInitializeAuth_Invalid_ApiKey_Hash(t *testing.T) {
+	assert.NotNil(t, InitializeAuth("not-a-hash="+ScopeMessagesRead, "", ""))
+}
+
+func TestRequireScopes_Jwt_Secret(t *testing.T) {
+	assert.Nil(t, InitializeAuth("", "s3cret", ""))
+
+	token := signHS256(t, "s3cret", jwt.MapClaims{
+		"sub":   "frontend",
+		"scope": "profile messages:read",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	})
+	rr := serveWithAuth(t, func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+token) })
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "frontend", rr.Body.String())
+
+	rr = serveWithAuth(t, func(req *http.Request) { req.URL.RawQuery = "access_token=" + token })
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+
+	expired := signHS256(t, "s3cret", jwt.MapClaims{
+		"scope": "messages:read",
+		"exp":   time.Now().Add(-time.Minute).Unix(),
+	})
+	rr = serveWithAuth(t, func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+expired) })
+	assertApiErr(t, rr, http.StatusUnauthorized, "invalid token")
+
+	forged := signHS256(t, "other", jwt.MapClaims{
+		"scope": "messages:read",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+	})
+	rr = serveWithAuth(t, func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+forged) })
+	assertApiErr(t, rr, http.StatusUnauthorized, "invalid token")
+}
+
+func TestRequireScopes_Jwt_Jwks(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	jwks, _ := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+		"kid": "k1",
+		"kty": "RSA",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.Nil(t, os.WriteFile(path, jwks, 0o600))
+	assert.Nil(t, InitializeAuth("", "", path))
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"sub": "backend",
+		"scp": []string{"messages:read"},
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	token.Header["kid"] = "k1"
+	signed, _ := token.SignedString(key)
+
+	rr := serveWithAuth(t, func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+signed) })
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "backend", rr.Body.String())
+
+	hmacToken := signHS256(t, "", jwt.MapClaims{"scope": "messages:read", "exp": time.Now().Add(time.Minute).Unix()})
+	rr = serveWithAuth(t, func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+hmacToken) })
+	assertApiErr(t, rr, http.StatusUnauthorized, "invalid token")
+}
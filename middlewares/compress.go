@@ -0,0 +1,174 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// supportedEncodings is in order of preference when the client accepts
+// several with the same quality.
+var supportedEncodings = []string{encodingBrotli, encodingGzip}
+
+// Compress compresses responses with brotli or gzip, whichever the client
+// prefers in Accept-Encoding. Bodies shorter than minSize bytes are sent as
+// they are, since compressing them costs more than it saves; a response that
+// is flushed before reaching minSize is a stream and is compressed from then
+// on. WebSocket upgrades are left alone.
+func Compress(minSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead || c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+
+		writer := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, minSize: minSize}
+		c.Writer = writer
+		defer writer.finish()
+		c.Next()
+	}
+}
+
+// negotiateEncoding returns the supported encoding the Accept-Encoding header
+// prefers, or "" when none is acceptable.
+func negotiateEncoding(header string) string {
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		qualities[coding] = quality
+	}
+
+	candidates := make([]string, 0, len(supportedEncodings))
+	for _, encoding := range supportedEncodings {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality, ok = qualities["*"]
+		}
+		if ok && quality > 0 {
+			qualities[encoding] = quality
+			candidates = append(candidates, encoding)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return qualities[candidates[i]] > qualities[candidates[j]]
+	})
+	return candidates[0]
+}
+
+// compressWriter holds the body back until it knows whether to compress it:
+// once minSize bytes have been written or the handler flushes, it compresses;
+// if the handler finishes first, the body goes out uncompressed.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	minSize  int
+	pending  []byte
+	decided  bool
+	out      compressor
+}
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.pending = append(w.pending, data...)
+		if len(w.pending) < w.minSize {
+			return len(data), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	}
+	if w.out != nil {
+		return w.out.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			return
+		}
+	}
+	if w.out != nil {
+		w.out.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide settles whether the body is compressed and writes out what was held
+// back. Responses that already carry an encoding, or have no body by their
+// status, are never compressed.
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	status := w.ResponseWriter.Status()
+	header := w.ResponseWriter.Header()
+	if header.Get("Content-Encoding") != "" || status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		compress = false
+	}
+	if compress {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		if w.encoding == encodingBrotli {
+			w.out = brotli.NewWriterLevel(w.ResponseWriter, brotli.DefaultCompression)
+		} else {
+			w.out, _ = gzip.NewWriterLevel(w.ResponseWriter, gzip.DefaultCompression)
+		}
+	}
+	pending := w.pending
+	w.pending = nil
+	if len(pending) == 0 {
+		return nil
+	}
+	_, err := w.Write(pending)
+	return err
+}
+
+// finish sends a body that stayed under minSize as it is, or ends the
+// compressed stream.
+func (w *compressWriter) finish() {
+	if !w.decided {
+		w.decide(false)
+		return
+	}
+	if w.out != nil {
+		w.out.Close()
+	}
+}
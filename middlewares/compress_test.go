@@ -0,0 +1,89 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveCompressed(acceptEncoding string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	r := gin.Default()
+	r.Use(Compress(64))
+	r.GET("/messages", handler)
+	req, _ := http.NewRequest(http.MethodGet, "/messages", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	cases := map[string]string{
+		"":                       "",
+		"identity":               "",
+		"gzip":                   "gzip",
+		"gzip, deflate, br":      "br",
+		"br;q=0.5, gzip":         "gzip",
+		"*":                      "br",
+		"*, br;q=0":              "gzip",
+		"GZIP;q=0.8, br;q=0.001": "gzip",
+	}
+	for header, encoding := range cases {
+		assert.Equal(t, encoding, negotiateEncoding(header), header)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("message ", 100)
+	handler := func(c *gin.Context) { c.String(http.StatusOK, body) }
+
+	rr := serveCompressed("gzip", handler)
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	assert.Contains(t, rr.Header().Values("Vary"), "Accept-Encoding")
+	reader, err := gzip.NewReader(rr.Body)
+	assert.Nil(t, err)
+	decoded, _ := io.ReadAll(reader)
+	assert.Equal(t, body, string(decoded))
+
+	rr = serveCompressed("br", handler)
+	assert.Equal(t, "br", rr.Header().Get("Content-Encoding"))
+	decoded, _ = io.ReadAll(brotli.NewReader(rr.Body))
+	assert.Equal(t, body, string(decoded))
+
+	rr = serveCompressed("", handler)
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, body, rr.Body.String())
+}
+
+func TestCompress_Below_Min_Size(t *testing.T) {
+	rr := serveCompressed("gzip", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "message not found"})
+	})
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, `{"message":"message not found"}`, rr.Body.String())
+}
+
+func TestCompress_Flushed_Stream(t *testing.T) {
+	rr := serveCompressed("gzip", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+		c.Writer.WriteString("first\n")
+		c.Writer.Flush()
+		c.Writer.WriteString("second\n")
+	})
+
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	reader, err := gzip.NewReader(rr.Body)
+	assert.Nil(t, err)
+	decoded, _ := io.ReadAll(reader)
+	assert.Equal(t, "first\nsecond\n", string(decoded))
+}
@@ -0,0 +1,38 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"testing-project/utils/encoders"
+	"testing-project/utils/error_utils"
+)
+
+// WriteError answers the request with err and stops the handler chain. The
+// legacy {message, status, error} body is the default; clients that list
+// application/problem+json in Accept get an RFC 7807 problem instead. The
+// internal detail of err is logged with the request id, never written.
+func WriteError(c *gin.Context, err error_utils.MessageErr) {
+	if detail := err.Detail(); detail != "" {
+		log.Printf("Request %s failed: %s: %s", RequestIdFrom(c), err.Message(), detail)
+	}
+	if !encoders.Accepts(c.GetHeader("Accept"), error_utils.ProblemContentType) {
+		c.AbortWithStatusJSON(err.Status(), err)
+		return
+	}
+	c.Header("Content-Type", error_utils.ProblemContentType)
+	c.AbortWithStatusJSON(err.Status(), error_utils.NewProblem(err, c.Request.URL.RequestURI(), RequestIdFrom(c)))
+}
+
+// Recovery answers a request whose handler panicked with a 500, as
+// gin.Recovery does, but hands http.ErrAbortHandler on to the server, which
+// then drops the connection: a response cut short midway must not reach the
+// client as a complete one.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, err interface{}) {
+		if err == http.ErrAbortHandler {
+			panic(err)
+		}
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
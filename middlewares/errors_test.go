@@ -0,0 +1,65 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing-project/utils/error_utils"
+)
+
+func serveError(configure func(*http.Request)) *httptest.ResponseRecorder {
+	r := gin.Default()
+	r.Use(RequestId())
+	r.GET("/messages/:message_id", func(c *gin.Context) {
+		WriteError(c, error_utils.NewNotFoundError("message not found").WithCode(error_utils.CodeMessageNotFound))
+	})
+	req, _ := http.NewRequest(http.MethodGet, "/messages/7?include_deleted=true", nil)
+	configure(req)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestWriteError_Legacy_By_Default(t *testing.T) {
+	rr := serveError(func(req *http.Request) {
+		req.Header.Set("Accept", "*/*")
+	})
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Header().Get("Content-Type"), "application/json")
+	assert.JSONEq(t, `{"message":"message not found","status":404,"error":"not_found"}`, rr.Body.String())
+	assert.Len(t, rr.Header().Get(RequestIdHeader), 32)
+}
+
+func TestWriteError_Problem(t *testing.T) {
+	rr := serveError(func(req *http.Request) {
+		req.Header.Set("Accept", "application/json, application/problem+json")
+		req.Header.Set(RequestIdHeader, "req-42")
+	})
+
+	var problem error_utils.Problem
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, error_utils.ProblemContentType, rr.Header().Get("Content-Type"))
+	assert.Equal(t, "req-42", rr.Header().Get(RequestIdHeader))
+	assert.Equal(t, error_utils.Problem{
+		Type:      "urn:problem-type:message-not-found",
+		Title:     "Not Found",
+		Status:    http.StatusNotFound,
+		Detail:    "message not found",
+		Instance:  "/messages/7?include_deleted=true",
+		Code:      "MESSAGE_NOT_FOUND",
+		RequestId: "req-42",
+	}, problem)
+}
+
+func TestRequestId_Replaces_Unusable_Ids(t *testing.T) {
+	rr := serveError(func(req *http.Request) {
+		req.Header.Set(RequestIdHeader, "bad id\nwith newline")
+	})
+
+	assert.Len(t, rr.Header().Get(RequestIdHeader), 32)
+}
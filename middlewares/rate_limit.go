@@ -0,0 +1,197 @@
+package middlewares
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+const defaultRoute = "default"
+
+// Limit allows Requests per Period on average, with bursts of up to Burst
+// requests (Requests when Burst is zero).
+type Limit struct {
+	Requests int64
+	Period   time.Duration
+	Burst    int64
+}
+
+func (l Limit) capacity() int64 {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+func (l Limit) perSecond() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+var (
+	RateLimits = map[string]Limit{}
+
+	// TrustedProxies are the networks whose X-Forwarded-For is believed.
+	TrustedProxies []*net.IPNet
+)
+
+// InitializeRateLimits parses "<route>=<requests>/<period>[:<burst>]" entries
+// separated by commas, e.g. "messages.list=60/1m:10,default=600/1m". The
+// "default" entry applies to routes without their own limit.
+func InitializeRateLimits(config string) error {
+	limits := map[string]Limit{}
+	for _, entry := range strings.Split(config, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, spec, found := strings.Cut(entry, "=")
+		if !found {
+			return fmt.Errorf("invalid rate limit %q", entry)
+		}
+		limit, err := ParseLimit(spec)
+		if err != nil {
+			return err
+		}
+		limits[strings.TrimSpace(route)] = limit
+	}
+	RateLimits = limits
+	return nil
+}
+
+// InitializeTrustedProxies parses the IP addresses or CIDR ranges, separated
+// by commas, of the proxies in front of the service. Only requests relayed
+// by them have their client address taken from X-Forwarded-For.
+func InitializeTrustedProxies(config string) error {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(config, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		cidr := entry
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q", entry)
+		}
+		proxies = append(proxies, network)
+	}
+	TrustedProxies = proxies
+	return nil
+}
+
+func ParseLimit(spec string) (Limit, error) {
+	var limit Limit
+	rate, burst, hasBurst := strings.Cut(strings.TrimSpace(spec), ":")
+	requests, period, found := strings.Cut(rate, "/")
+	if !found {
+		return limit, fmt.Errorf("invalid rate limit %q", spec)
+	}
+	var err error
+	if limit.Requests, err = strconv.ParseInt(requests, 10, 64); err != nil || limit.Requests <= 0 {
+		return limit, fmt.Errorf("invalid request count in %q", spec)
+	}
+	if limit.Period, err = time.ParseDuration(period); err != nil || limit.Period <= 0 {
+		return limit, fmt.Errorf("invalid period in %q", spec)
+	}
+	if hasBurst {
+		if limit.Burst, err = strconv.ParseInt(burst, 10, 64); err != nil || limit.Burst <= 0 {
+			return limit, fmt.Errorf("invalid burst in %q", spec)
+		}
+	}
+	return limit, nil
+}
+
+// RateLimit throttles each client of route with a token bucket kept in Redis,
+// so the limit holds across replicas. If Redis is unavailable requests are
+// let through rather than failing the API.
+func RateLimit(route string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, ok := RateLimits[route]
+		if !ok {
+			limit, ok = RateLimits[defaultRoute]
+		}
+		if !ok {
+			c.Next()
+			return
+		}
+
+		result, err := domain.RateLimitRepo.Take(route+":"+clientKey(c), limit.capacity(), limit.perSecond())
+		if err != nil {
+			log.Printf("Rate limiting skipped: %s", err.Message())
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.FormatInt(limit.capacity(), 10))
+		c.Header("RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+		c.Header("RateLimit-Reset", strconv.FormatInt(ceilSeconds(result.ResetAfter), 10))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, int64(limit.Period.Seconds())))
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.FormatInt(ceilSeconds(result.RetryAfter), 10))
+			theErr := error_utils.NewTooManyRequestsError("rate limit exceeded")
+			WriteError(c, theErr)
+			return
+		}
+		c.Next()
+	}
+}
+
+// clientKey identifies the caller by authenticated subject, or else by
+// client address. Nothing the client merely claims counts: a made-up API key
+// or X-Forwarded-For would get a fresh bucket each time.
+func clientKey(c *gin.Context) string {
+	if principal := PrincipalFrom(c); principal != nil && principal.Subject != "" {
+		return "sub:" + principal.Subject
+	}
+	return "ip:" + clientIP(c.Request)
+}
+
+// clientIP is the address r came from. When that is a trusted proxy, it is
+// the nearest X-Forwarded-For address that is not one.
+func clientIP(r *http.Request) string {
+	addr, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		addr = strings.TrimSpace(r.RemoteAddr)
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0 && trustedProxy(addr); i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		addr = hop
+	}
+	return addr
+}
+
+func trustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,150 @@
+package middlewares
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+var (
+	takeToken func(key string, capacity int64, perSecond float64) (*domain.RateLimitResult, error_utils.MessageErr)
+)
+
+type rateLimitRepoMock struct{}
+
+func (m *rateLimitRepoMock) Take(key string, capacity int64, perSecond float64) (*domain.RateLimitResult, error_utils.MessageErr) {
+	return takeToken(key, capacity, perSecond)
+}
+func (m *rateLimitRepoMock) Initialize(*redis.Client) {}
+
+func serveRateLimited(route string, configure func(*http.Request)) *httptest.ResponseRecorder {
+	r := gin.Default()
+	r.GET("/messages", RateLimit(route), func(c *gin.Context) { c.Status(http.StatusOK) })
+	req, _ := http.NewRequest(http.MethodGet, "/messages", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	configure(req)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("60/1m:10")
+	assert.Nil(t, err)
+	assert.EqualValues(t, Limit{Requests: 60, Period: time.Minute, Burst: 10}, limit)
+	assert.EqualValues(t, 10, limit.capacity())
+	assert.EqualValues(t, 1, limit.perSecond())
+
+	for _, spec := range []string{"60", "x/1m", "60/soon", "60/1m:0", "-1/1s"} {
+		_, err := ParseLimit(spec)
+		assert.NotNil(t, err, spec)
+	}
+}
+
+func TestRateLimit_Allowed(t *testing.T) {
+	Authenticators = nil
+	assert.Nil(t, InitializeRateLimits("messages.list=120/1m:20"))
+	domain.RateLimitRepo = &rateLimitRepoMock{}
+	var takenKey string
+	takeToken = func(key string, capacity int64, perSecond float64) (*domain.RateLimitResult, error_utils.MessageErr) {
+		takenKey = key
+		assert.EqualValues(t, 20, capacity)
+		assert.EqualValues(t, 2, perSecond)
+		return &domain.RateLimitResult{Allowed: true, Remaining: 19, ResetAfter: 500 * time.Millisecond}, nil
+	}
+
+	rr := serveRateLimited("messages.list", func(req *http.Request) {})
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "messages.list:ip:10.0.0.1", takenKey)
+	assert.EqualValues(t, "20", rr.Header().Get("RateLimit-Limit"))
+	assert.EqualValues(t, "19", rr.Header().Get("RateLimit-Remaining"))
+	assert.EqualValues(t, "1", rr.Header().Get("RateLimit-Reset"))
+	assert.EqualValues(t, "120;w=60", rr.Header().Get("RateLimit-Policy"))
+}
+
+func TestRateLimit_Exceeded(t *testing.T) {
+	assert.Nil(t, InitializeRateLimits("default=1/1s"))
+	domain.RateLimitRepo = &rateLimitRepoMock{}
+	var takenKey string
+	takeToken = func(key string, capacity int64, perSecond float64) (*domain.RateLimitResult, error_utils.MessageErr) {
+		takenKey = key
+		return &domain.RateLimitResult{Allowed: false, RetryAfter: 1500 * time.Millisecond, ResetAfter: 2 * time.Second}, nil
+	}
+
+	rr := serveRateLimited("messages.get", func(req *http.Request) {})
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusTooManyRequests, apiErr.Status())
+	assert.EqualValues(t, "rate limit exceeded", apiErr.Message())
+	assert.EqualValues(t, "too_many_requests", apiErr.Error())
+	assert.EqualValues(t, "2", rr.Header().Get("Retry-After"))
+	assert.EqualValues(t, "messages.get:ip:10.0.0.1", takenKey)
+}
+
+func TestRateLimit_Client_Key_Ignores_Claims(t *testing.T) {
+	Authenticators = nil
+	assert.Nil(t, InitializeRateLimits("default=1/1s"))
+	assert.Nil(t, InitializeTrustedProxies(""))
+	domain.RateLimitRepo = &rateLimitRepoMock{}
+	var takenKeys []string
+	takeToken = func(key string, capacity int64, perSecond float64) (*domain.RateLimitResult, error_utils.MessageErr) {
+		takenKeys = append(takenKeys, key)
+		return &domain.RateLimitResult{Allowed: len(takenKeys) == 1}, nil
+	}
+
+	for i, forwarded := range []string{"", "203.0.113.1", "203.0.113.2"} {
+		rr := serveRateLimited("messages.get", func(req *http.Request) {
+			req.Header.Set("X-API-Key", fmt.Sprintf("made-up-%d", i))
+			req.Header.Set("X-Forwarded-For", forwarded)
+		})
+		if i > 0 {
+			assert.EqualValues(t, http.StatusTooManyRequests, rr.Code)
+		}
+	}
+
+	assert.Equal(t, []string{"messages.get:ip:10.0.0.1", "messages.get:ip:10.0.0.1", "messages.get:ip:10.0.0.1"}, takenKeys)
+}
+
+func TestClientIP_Trusted_Proxies(t *testing.T) {
+	assert.Nil(t, InitializeTrustedProxies("10.0.0.0/8, 192.0.2.7"))
+	t.Cleanup(func() { TrustedProxies = nil })
+	ip := func(remote, forwarded string) string {
+		req, _ := http.NewRequest(http.MethodGet, "/messages", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-For", forwarded)
+		return clientIP(req)
+	}
+
+	assert.Equal(t, "203.0.113.9", ip("10.0.0.1:1234", "198.51.100.1, 203.0.113.9"))
+	assert.Equal(t, "203.0.113.9", ip("10.0.0.1:1234", "203.0.113.9, 192.0.2.7"))
+	assert.Equal(t, "10.0.0.1", ip("10.0.0.1:1234", ""))
+	assert.Equal(t, "198.51.100.5", ip("198.51.100.5:1234", "203.0.113.9"))
+	assert.NotNil(t, InitializeTrustedProxies("not-an-ip"))
+}
+
+func TestRateLimit_Unlimited_And_Fail_Open(t *testing.T) {
+	assert.Nil(t, InitializeRateLimits(""))
+	domain.RateLimitRepo = &rateLimitRepoMock{}
+	takeToken = func(key string, capacity int64, perSecond float64) (*domain.RateLimitResult, error_utils.MessageErr) {
+		t.Fatal("unlimited routes should not touch redis")
+		return nil, nil
+	}
+	assert.EqualValues(t, http.StatusOK, serveRateLimited("messages.list", func(req *http.Request) {}).Code)
+
+	assert.Nil(t, InitializeRateLimits("messages.list=1/1s"))
+	takeToken = func(key string, capacity int64, perSecond float64) (*domain.RateLimitResult, error_utils.MessageErr) {
+		return nil, error_utils.NewInternalServerError("redis rate limit error")
+	}
+	rr := serveRateLimited("messages.list", func(req *http.Request) {})
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+}
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"regexp"
+)
+
+const (
+	RequestIdHeader = "X-Request-ID"
+
+	requestIdKey = "request_id"
+)
+
+// validRequestId limits the ids taken from clients to something safe to log
+// and echo back.
+var validRequestId = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestId gives every request an id, the client's X-Request-ID when it
+// sends a usable one, and echoes it in the response.
+func RequestId() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIdHeader)
+		if !validRequestId.MatchString(id) {
+			id = newRequestId()
+		}
+		c.Set(requestIdKey, id)
+		c.Header(RequestIdHeader, id)
+		c.Next()
+	}
+}
+
+// RequestIdFrom returns the id RequestId gave the request, or "" outside it.
+func RequestIdFrom(c *gin.Context) string {
+	if value, ok := c.Get(requestIdKey); ok {
+		return value.(string)
+	}
+	return ""
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
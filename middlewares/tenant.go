@@ -0,0 +1,65 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+const (
+	TenantHeader = "X-Tenant-ID"
+
+	tenantKey = "tenant"
+)
+
+// ResolveTenant decides which tenant a request reads from, see TenantFor.
+func ResolveTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant, err := TenantFor(PrincipalFrom(c), c.GetHeader(TenantHeader))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.Set(tenantKey, tenant)
+		c.Next()
+	}
+}
+
+// TenantFor returns the tenant principal reads from when it asks for
+// requested (empty when it asks for none). A tenant bound to the credentials
+// (JWT "tenant" claim or an API key's "tenant:<id>" entry) always wins.
+// Otherwise only a principal holding ScopeAdmin or ScopeAnyTenant may pick a
+// tenant; everyone else, including anonymous callers while authentication is
+// disabled, reads the default tenant and is refused any other.
+func TenantFor(principal *Principal, requested string) (string, error_utils.MessageErr) {
+	if principal != nil && principal.Tenant != "" {
+		if requested != "" && requested != principal.Tenant {
+			return "", errTenantNotAllowed()
+		}
+		requested = principal.Tenant
+	}
+
+	tenant, err := domain.NormalizeTenant(requested)
+	if err != nil {
+		return "", err
+	}
+	if tenant == domain.DefaultTenant || principal != nil && principal.Tenant != "" {
+		return tenant, nil
+	}
+	if principal == nil || !principal.HasScope(ScopeAdmin) && !principal.HasScope(ScopeAnyTenant) {
+		return "", errTenantNotAllowed()
+	}
+	return tenant, nil
+}
+
+func errTenantNotAllowed() error_utils.MessageErr {
+	return error_utils.NewForbiddenError("tenant not allowed for these credentials").WithCode(error_utils.CodeTenantNotAllowed)
+}
+
+// TenantFrom returns the tenant chosen by ResolveTenant.
+func TenantFrom(c *gin.Context) string {
+	if value, ok := c.Get(tenantKey); ok {
+		return value.(string)
+	}
+	return domain.DefaultTenant
+}
@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+func TestTenantFor(t *testing.T) {
+	unbound := &Principal{Scopes: []string{ScopeMessagesRead}}
+	anyTenant := &Principal{Scopes: []string{ScopeMessagesRead, ScopeAnyTenant}}
+	admin := &Principal{Scopes: []string{ScopeAdmin}}
+	bound := &Principal{Scopes: []string{ScopeMessagesRead}, Tenant: "team-a"}
+
+	tests := []struct {
+		name      string
+		principal *Principal
+		requested string
+		tenant    string
+		allowed   bool
+	}{
+		{"anonymous default", nil, "", domain.DefaultTenant, true},
+		{"anonymous override", nil, "team-a", "", false},
+		{"unbound default", unbound, "", domain.DefaultTenant, true},
+		{"unbound explicit default", unbound, domain.DefaultTenant, domain.DefaultTenant, true},
+		{"unbound override", unbound, "team-a", "", false},
+		{"any tenant override", anyTenant, "team-b", "team-b", true},
+		{"admin override", admin, "team-b", "team-b", true},
+		{"bound", bound, "", "team-a", true},
+		{"bound same", bound, "team-a", "team-a", true},
+		{"bound other", bound, "team-b", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenant, err := TenantFor(tt.principal, tt.requested)
+			if tt.allowed {
+				assert.Nil(t, err)
+				assert.EqualValues(t, tt.tenant, tenant)
+			} else {
+				assert.NotNil(t, err)
+				assert.EqualValues(t, error_utils.CodeTenantNotAllowed, err.Code())
+			}
+		})
+	}
+}
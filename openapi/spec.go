@@ -0,0 +1,79 @@
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// source is the API contract. Every route registered in app/routes.go must
+// have an operation in it and the other way around, which CheckRoutes
+// enforces at startup.
+//
+//go:embed openapi.yaml
+var source []byte
+
+var ginParam = regexp.MustCompile(`:([^/]+)`)
+
+// Load parses and validates the embedded document.
+func Load() (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Handler serves doc as JSON.
+func Handler(doc *openapi3.T) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+// CheckRoutes reports routes that doc does not describe and operations in doc
+// that no route serves.
+func CheckRoutes(doc *openapi3.T, routes gin.RoutesInfo) error {
+	documented := map[string]bool{}
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+path] = true
+		}
+	}
+
+	var undocumented []string
+	for _, route := range routes {
+		key := route.Method + " " + ginParam.ReplaceAllString(route.Path, "{$1}")
+		if !documented[key] {
+			undocumented = append(undocumented, key)
+		}
+		delete(documented, key)
+	}
+	var unrouted []string
+	for key := range documented {
+		unrouted = append(unrouted, key)
+	}
+	sort.Strings(undocumented)
+	sort.Strings(unrouted)
+
+	var problems []string
+	if len(undocumented) > 0 {
+		problems = append(problems, "routes missing from the OpenAPI document: "+strings.Join(undocumented, ", "))
+	}
+	if len(unrouted) > 0 {
+		problems = append(problems, "OpenAPI operations without a route: "+strings.Join(unrouted, ", "))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package openapi
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing-project/utils/error_utils"
+)
+
+func TestCheckRoutes(t *testing.T) {
+	doc, err := Load()
+	assert.Nil(t, err)
+
+	err = CheckRoutes(doc, gin.RoutesInfo{
+		{Method: http.MethodGet, Path: "/messages/:message_id"},
+		{Method: http.MethodGet, Path: "/messages/:message_id/archive"},
+	})
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "routes missing from the OpenAPI document: GET /messages/{message_id}/archive")
+	assert.Contains(t, err.Error(), "OpenAPI operations without a route: ")
+	assert.NotContains(t, err.Error(), "GET /messages/{message_id},")
+}
+
+func serveValidated(t *testing.T, path, accept string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	doc, err := Load()
+	assert.Nil(t, err)
+	validator, err := NewValidator(doc)
+	assert.Nil(t, err)
+
+	r := gin.Default()
+	r.Use(validator.Middleware())
+	r.GET("/messages/:message_id", handler)
+	r.GET("/messages", handler)
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestValidator_Conforming(t *testing.T) {
+	rr := serveValidated(t, "/messages/1", "", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"id": 1, "title": "the title", "body": "the body", "created_at": "2024-01-01T00:00:00Z"})
+	})
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "the title")
+
+	rr = serveValidated(t, "/messages/1", "", func(c *gin.Context) {
+		theErr := error_utils.NewNotFoundError("message not found")
+		c.JSON(theErr.Status(), theErr)
+	})
+	assert.EqualValues(t, http.StatusNotFound, rr.Code)
+
+	rr = serveValidated(t, "/messages", "application/x-ndjson", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/x-ndjson", []byte("{\"id\":1,\"title\":\"t\",\"body\":\"b\",\"created_at\":\"2024-01-01T00:00:00Z\"}\n"))
+	})
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+}
+
+func TestValidator_Nonconforming_Response(t *testing.T) {
+	rr := serveValidated(t, "/messages/1", "", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"id": "1", "title": "the title"})
+	})
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, apiErr.Message(), "response does not match the API specification")
+
+	rr = serveValidated(t, "/messages", "application/x-ndjson", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/x-ndjson", []byte("{\"id\":1}\n"))
+	})
+	assert.EqualValues(t, http.StatusInternalServerError, rr.Code)
+}
+
+func TestValidator_Nonconforming_Request(t *testing.T) {
+	called := false
+	rr := serveValidated(t, "/messages?created_after=yesterday", "", func(c *gin.Context) {
+		called = true
+	})
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.False(t, called)
+	assert.EqualValues(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, apiErr.Message(), "request does not match the API specification")
+}
@@ -0,0 +1,164 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log"
+	"net/http"
+	"testing-project/middlewares"
+	"testing-project/utils/error_utils"
+)
+
+func init() {
+	openapi3filter.RegisterBodyDecoder("application/x-ndjson", ndjsonBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/msgpack", openapi3filter.FileBodyDecoder)
+}
+
+// ndjsonBodyDecoder decodes newline-delimited JSON as an array of its
+// documents.
+func ndjsonBodyDecoder(body io.Reader, _ http.Header, _ *openapi3.SchemaRef, _ openapi3filter.EncodingFn) (interface{}, error) {
+	documents := make([]interface{}, 0)
+	decoder := json.NewDecoder(body)
+	decoder.UseNumber()
+	for {
+		var document interface{}
+		if err := decoder.Decode(&document); err == io.EOF {
+			return documents, nil
+		} else if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+}
+
+// Validator checks requests and responses against an OpenAPI document.
+// Authentication is left to the auth middleware.
+type Validator struct {
+	router  routers.Router
+	options *openapi3filter.Options
+}
+
+func NewValidator(doc *openapi3.T) (*Validator, error) {
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{
+		router:  router,
+		options: &openapi3filter.Options{AuthenticationFunc: openapi3filter.NoopAuthenticationFunc},
+	}, nil
+}
+
+func (v *Validator) input(req *http.Request) (*openapi3filter.RequestValidationInput, error) {
+	route, pathParams, err := v.router.FindRoute(req)
+	if err != nil {
+		return nil, err
+	}
+	return &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    v.options,
+	}, nil
+}
+
+// CheckRequest reports how req departs from the document.
+func (v *Validator) CheckRequest(req *http.Request) error {
+	input, err := v.input(req)
+	if err != nil {
+		return err
+	}
+	return openapi3filter.ValidateRequest(req.Context(), input)
+}
+
+// CheckResponse reports how the response to req departs from the document.
+func (v *Validator) CheckResponse(req *http.Request, status int, header http.Header, body []byte) error {
+	input, err := v.input(req)
+	if err != nil {
+		return err
+	}
+	responseInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 status,
+		Header:                 header,
+		Options:                v.options,
+	}
+	return openapi3filter.ValidateResponse(req.Context(), responseInput.SetBodyBytes(body))
+}
+
+// Middleware rejects requests that do not match the document with 400 and
+// replaces responses that do not match it with 500, so tests fail loudly when
+// the service and its contract drift apart. Responses are held back until
+// they are complete; streamed ones, which flush, and WebSocket upgrades are
+// not checked. Requests for paths the document does not know pass through.
+// It is meant for test environments.
+func (v *Validator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+		input, err := v.input(c.Request)
+		if err != nil {
+			c.Next()
+			return
+		}
+		if err := openapi3filter.ValidateRequest(c.Request.Context(), input); err != nil {
+			middlewares.WriteError(c, error_utils.NewBadRequestError("request does not match the API specification: "+err.Error()).WithCode(error_utils.CodeSpecificationViolation))
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		if writer.streaming {
+			return
+		}
+
+		err = v.CheckResponse(c.Request, writer.Status(), writer.Header(), writer.body.Bytes())
+		if err == nil {
+			writer.ResponseWriter.Write(writer.body.Bytes())
+			return
+		}
+		log.Printf("Response to %s %s does not match the API specification: %s", c.Request.Method, c.Request.URL.Path, err)
+		theErr := error_utils.NewInternalServerError("response does not match the API specification: " + err.Error())
+		data, _ := json.Marshal(theErr)
+		writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+		writer.ResponseWriter.WriteHeader(theErr.Status())
+		writer.ResponseWriter.Write(data)
+	}
+}
+
+// recordingWriter holds the response body back for validation until the
+// handler flushes, after which it passes everything through.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	streaming bool
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	if w.streaming {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *recordingWriter) Flush() {
+	if !w.streaming {
+		w.streaming = true
+		w.ResponseWriter.Write(w.body.Bytes())
+		w.body.Reset()
+	}
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,209 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: messages.proto
+
+package messagespb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	MessagesReader_GetMessage_FullMethodName       = "/messages.v1.MessagesReader/GetMessage"
+	MessagesReader_ListMessages_FullMethodName     = "/messages.v1.MessagesReader/ListMessages"
+	MessagesReader_BatchGetMessages_FullMethodName = "/messages.v1.MessagesReader/BatchGetMessages"
+)
+
+// MessagesReaderClient is the client API for MessagesReader service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// MessagesReader exposes the Redis read model to backend services.
+type MessagesReaderClient interface {
+	GetMessage(ctx context.Context, in *GetMessageRequest, opts ...grpc.CallOption) (*Message, error)
+	// ListMessages streams every message one by one so large sets never have
+	// to fit into a single response.
+	ListMessages(ctx context.Context, in *ListMessagesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Message], error)
+	BatchGetMessages(ctx context.Context, in *BatchGetMessagesRequest, opts ...grpc.CallOption) (*BatchGetMessagesResponse, error)
+}
+
+type messagesReaderClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMessagesReaderClient(cc grpc.ClientConnInterface) MessagesReaderClient {
+	return &messagesReaderClient{cc}
+}
+
+func (c *messagesReaderClient) GetMessage(ctx context.Context, in *GetMessageRequest, opts ...grpc.CallOption) (*Message, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Message)
+	err := c.cc.Invoke(ctx, MessagesReader_GetMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *messagesReaderClient) ListMessages(ctx context.Context, in *ListMessagesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Message], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MessagesReader_ServiceDesc.Streams[0], MessagesReader_ListMessages_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ListMessagesRequest, Message]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MessagesReader_ListMessagesClient = grpc.ServerStreamingClient[Message]
+
+func (c *messagesReaderClient) BatchGetMessages(ctx context.Context, in *BatchGetMessagesRequest, opts ...grpc.CallOption) (*BatchGetMessagesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchGetMessagesResponse)
+	err := c.cc.Invoke(ctx, MessagesReader_BatchGetMessages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MessagesReaderServer is the server API for MessagesReader service.
+// All implementations must embed UnimplementedMessagesReaderServer
+// for forward compatibility.
+//
+// MessagesReader exposes the Redis read model to backend services.
+type MessagesReaderServer interface {
+	GetMessage(context.Context, *GetMessageRequest) (*Message, error)
+	// ListMessages streams every message one by one so large sets never have
+	// to fit into a single response.
+	ListMessages(*ListMessagesRequest, grpc.ServerStreamingServer[Message]) error
+	BatchGetMessages(context.Context, *BatchGetMessagesRequest) (*BatchGetMessagesResponse, error)
+	mustEmbedUnimplementedMessagesReaderServer()
+}
+
+// UnimplementedMessagesReaderServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMessagesReaderServer struct{}
+
+func (UnimplementedMessagesReaderServer) GetMessage(context.Context, *GetMessageRequest) (*Message, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMessage not implemented")
+}
+func (UnimplementedMessagesReaderServer) ListMessages(*ListMessagesRequest, grpc.ServerStreamingServer[Message]) error {
+	return status.Errorf(codes.Unimplemented, "method ListMessages not implemented")
+}
+func (UnimplementedMessagesReaderServer) BatchGetMessages(context.Context, *BatchGetMessagesRequest) (*BatchGetMessagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetMessages not implemented")
+}
+func (UnimplementedMessagesReaderServer) mustEmbedUnimplementedMessagesReaderServer() {}
+func (UnimplementedMessagesReaderServer) testEmbeddedByValue()                        {}
+
+// UnsafeMessagesReaderServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MessagesReaderServer will
+// result in compilation errors.
+type UnsafeMessagesReaderServer interface {
+	mustEmbedUnimplementedMessagesReaderServer()
+}
+
+func RegisterMessagesReaderServer(s grpc.ServiceRegistrar, srv MessagesReaderServer) {
+	// If the following call pancis, it indicates UnimplementedMessagesReaderServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&MessagesReader_ServiceDesc, srv)
+}
+
+func _MessagesReader_GetMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessagesReaderServer).GetMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MessagesReader_GetMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessagesReaderServer).GetMessage(ctx, req.(*GetMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MessagesReader_ListMessages_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListMessagesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MessagesReaderServer).ListMessages(m, &grpc.GenericServerStream[ListMessagesRequest, Message]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MessagesReader_ListMessagesServer = grpc.ServerStreamingServer[Message]
+
+func _MessagesReader_BatchGetMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MessagesReaderServer).BatchGetMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MessagesReader_BatchGetMessages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MessagesReaderServer).BatchGetMessages(ctx, req.(*BatchGetMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// MessagesReader_ServiceDesc is the grpc.ServiceDesc for MessagesReader service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MessagesReader_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "messages.v1.MessagesReader",
+	HandlerType: (*MessagesReaderServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetMessage",
+			Handler:    _MessagesReader_GetMessage_Handler,
+		},
+		{
+			MethodName: "BatchGetMessages",
+			Handler:    _MessagesReader_BatchGetMessages_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListMessages",
+			Handler:       _MessagesReader_ListMessages_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "messages.proto",
+}
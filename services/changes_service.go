@@ -0,0 +1,125 @@
+package services
+
+import (
+	"sync"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+const subscriberBuffer = 64
+
+var (
+	ChangesService changesServiceInterface = &changesService{}
+)
+
+type changesServiceInterface interface {
+	Subscribe(string) (<-chan domain.MessageChange, func())
+	Replay(string, string) ([]domain.MessageChange, error_utils.MessageErr)
+}
+
+// changesService shares one Redis subscription between all local stream
+// clients and only hands each client the changes of its own tenant. A client
+// that cannot keep up is disconnected instead of silently losing events, so
+// it can resume with its last event id.
+type changesService struct {
+	mu          sync.Mutex
+	subscribers map[chan domain.MessageChange]string
+	stopSource  func()
+	generation  int
+}
+
+func (s *changesService) Subscribe(tenant string) (<-chan domain.MessageChange, func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.subscribers == nil {
+		s.subscribers = make(map[chan domain.MessageChange]string)
+	}
+	if s.stopSource == nil {
+		source, stop := domain.ChangeFeed.Subscribe()
+		s.stopSource = stop
+		s.generation++
+		go s.broadcast(source, s.generation)
+	}
+
+	ch := make(chan domain.MessageChange, subscriberBuffer)
+	s.subscribers[ch] = tenant
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() { s.unsubscribe(ch) })
+	}
+}
+
+func (s *changesService) Replay(tenant, lastId string) ([]domain.MessageChange, error_utils.MessageErr) {
+	if lastId == "" {
+		return nil, nil
+	}
+	changes, err := domain.ChangeFeed.Since(lastId)
+	if err != nil {
+		return nil, err
+	}
+	own := make([]domain.MessageChange, 0, len(changes))
+	for _, change := range changes {
+		if change.Tenant == tenant || change.Event == domain.EventReset {
+			own = append(own, change)
+		}
+	}
+	return own, nil
+}
+
+func (s *changesService) unsubscribe(ch chan domain.MessageChange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscribers[ch]; !ok {
+		return
+	}
+	delete(s.subscribers, ch)
+	close(ch)
+
+	if len(s.subscribers) == 0 && s.stopSource != nil {
+		s.stopSource()
+		s.stopSource = nil
+	}
+}
+
+func (s *changesService) broadcast(source <-chan domain.MessageChange, generation int) {
+	for change := range source {
+		s.mu.Lock()
+		if s.generation != generation {
+			s.mu.Unlock()
+			continue
+		}
+		for ch, tenant := range s.subscribers {
+			if change.Tenant != tenant {
+				continue
+			}
+			select {
+			case ch <- change:
+			default:
+				delete(s.subscribers, ch)
+				close(ch)
+			}
+		}
+		// Dropping slow subscribers may have left nobody to listen.
+		if len(s.subscribers) == 0 && s.stopSource != nil {
+			s.stopSource()
+			s.stopSource = nil
+		}
+		s.mu.Unlock()
+	}
+
+	// The source is gone, either because the last subscriber left or because
+	// the Redis connection dropped. Disconnect everyone so clients reconnect.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.generation != generation {
+		return
+	}
+	for ch := range s.subscribers {
+		delete(s.subscribers, ch)
+		close(ch)
+	}
+	s.stopSource = nil
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+type changeFeedMock struct {
+	source  chan domain.MessageChange
+	stopped bool
+}
+
+func (m *changeFeedMock) Publish(*domain.MessageChange) error_utils.MessageErr {
+	return nil
+}
+func (m *changeFeedMock) Since(lastId string) ([]domain.MessageChange, error_utils.MessageErr) {
+	return []domain.MessageChange{{Id: "2-0", Tenant: "team-a"}, {Id: "3-0", Tenant: "team-b"}}, nil
+}
+func (m *changeFeedMock) Subscribe() (<-chan domain.MessageChange, func()) {
+	return m.source, func() {
+		m.stopped = true
+		close(m.source)
+	}
+}
+func (m *changeFeedMock) Initialize(*redis.Client, int64) {}
+
+func TestChangesService_Subscribe_FanOut(t *testing.T) {
+	feed := &changeFeedMock{source: make(chan domain.MessageChange)}
+	domain.ChangeFeed = feed
+	service := &changesService{}
+
+	first, unsubscribeFirst := service.Subscribe("team-a")
+	second, unsubscribeSecond := service.Subscribe("team-a")
+	other, unsubscribeOther := service.Subscribe("team-b")
+	defer unsubscribeOther()
+
+	feed.source <- domain.MessageChange{Id: "1-0", Event: "created", Tenant: "team-a"}
+	feed.source <- domain.MessageChange{Id: "2-0", Event: "created", Tenant: "team-b"}
+
+	assert.EqualValues(t, "1-0", (<-first).Id)
+	assert.EqualValues(t, "1-0", (<-second).Id)
+	assert.EqualValues(t, "2-0", (<-other).Id)
+
+	unsubscribeFirst()
+	_, open := <-first
+	assert.False(t, open)
+	assert.False(t, feed.stopped)
+
+	unsubscribeSecond()
+	assert.False(t, feed.stopped)
+	unsubscribeOther()
+	assert.True(t, feed.stopped)
+}
+
+func TestChangesService_Subscribe_Slow_Subscriber_Dropped(t *testing.T) {
+	feed := &changeFeedMock{source: make(chan domain.MessageChange)}
+	domain.ChangeFeed = feed
+	service := &changesService{}
+
+	slow, unsubscribe := service.Subscribe(domain.DefaultTenant)
+	defer unsubscribe()
+	for i := 0; i <= subscriberBuffer; i++ {
+		feed.source <- domain.MessageChange{Event: "updated", Tenant: domain.DefaultTenant}
+	}
+
+	received := 0
+	for range slow {
+		received++
+	}
+	assert.EqualValues(t, subscriberBuffer, received)
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	assert.True(t, feed.stopped)
+	assert.Nil(t, service.stopSource)
+}
+
+func TestChangesService_Replay(t *testing.T) {
+	domain.ChangeFeed = &changeFeedMock{}
+	service := &changesService{}
+
+	changes, err := service.Replay("team-a", "")
+	assert.Nil(t, err)
+	assert.Nil(t, changes)
+
+	changes, err = service.Replay("team-a", "1-0")
+	assert.Nil(t, err)
+	assert.Len(t, changes, 1)
+	assert.EqualValues(t, "2-0", changes[0].Id)
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+// maxDeadLetters bounds how many dead-lettered events one listing returns.
+const maxDeadLetters = 500
+
+var (
+	// ConsumerService controls the event consumer. The app installs the
+	// running consumer; until then every call fails.
+	ConsumerService consumerServiceInterface = &stoppedConsumer{}
+)
+
+type consumerServiceInterface interface {
+	GetState() domain.ConsumerState
+	Pause() (domain.ConsumerState, error_utils.MessageErr)
+	Resume() (domain.ConsumerState, error_utils.MessageErr)
+	Reconnect() (domain.ConsumerState, error_utils.MessageErr)
+	GetDeadLetters(limit int) ([]domain.DeadLetter, error_utils.MessageErr)
+	Requeue(ids []string) (*domain.RequeueResult, error_utils.MessageErr)
+}
+
+// ValidateDeadLetterLimit checks the number of dead-lettered events asked
+// for, 1 to maxDeadLetters.
+func ValidateDeadLetterLimit(limit int) error_utils.MessageErr {
+	if limit < 1 || limit > maxDeadLetters {
+		return error_utils.NewBadRequestError("limit must be between 1 and 500").WithCode(error_utils.CodeInvalidDeadLetterLimit)
+	}
+	return nil
+}
+
+func consumerNotRunning() error_utils.MessageErr {
+	return error_utils.NewServiceUnavailableError("the consumer is not running").WithCode(error_utils.CodeConsumerNotRunning)
+}
+
+type stoppedConsumer struct{}
+
+func (s *stoppedConsumer) GetState() domain.ConsumerState {
+	return domain.ConsumerState{Events: map[string]*domain.EventCounters{}}
+}
+
+func (s *stoppedConsumer) Pause() (domain.ConsumerState, error_utils.MessageErr) {
+	return domain.ConsumerState{}, consumerNotRunning()
+}
+
+func (s *stoppedConsumer) Resume() (domain.ConsumerState, error_utils.MessageErr) {
+	return domain.ConsumerState{}, consumerNotRunning()
+}
+
+func (s *stoppedConsumer) Reconnect() (domain.ConsumerState, error_utils.MessageErr) {
+	return domain.ConsumerState{}, consumerNotRunning()
+}
+
+func (s *stoppedConsumer) GetDeadLetters(int) ([]domain.DeadLetter, error_utils.MessageErr) {
+	return nil, consumerNotRunning()
+}
+
+func (s *stoppedConsumer) Requeue([]string) (*domain.RequeueResult, error_utils.MessageErr) {
+	return nil, consumerNotRunning()
+}
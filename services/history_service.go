@@ -0,0 +1,58 @@
+package services
+
+import (
+	"errors"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+var (
+	HistoryService historyServiceInterface = &historyService{}
+)
+
+type historyService struct{}
+
+type historyServiceInterface interface {
+	GetHistory(string, int64, bool) ([]domain.MessageVersion, error_utils.MessageErr)
+	GetVersion(string, int64, int64, bool) (*domain.MessageVersion, error_utils.MessageErr)
+}
+
+func (h *historyService) GetHistory(tenant string, msgId int64, includeDeleted bool) ([]domain.MessageVersion, error_utils.MessageErr) {
+	if err := h.checkVisible(tenant, msgId, includeDeleted); err != nil {
+		return nil, err
+	}
+	return domain.HistoryRepo.GetAll(tenant, msgId)
+}
+
+func (h *historyService) GetVersion(tenant string, msgId int64, n int64, includeDeleted bool) (*domain.MessageVersion, error_utils.MessageErr) {
+	if err := h.checkVisible(tenant, msgId, includeDeleted); err != nil {
+		return nil, err
+	}
+	return domain.HistoryRepo.Get(tenant, msgId, n)
+}
+
+// checkVisible hides the history of a deleted message unless includeDeleted
+// is set, as GetMessage hides the message itself. A hard-deleted message is
+// gone, but its history is kept and ends with the deletion.
+func (h *historyService) checkVisible(tenant string, msgId int64, includeDeleted bool) error_utils.MessageErr {
+	if includeDeleted {
+		return nil
+	}
+	message, err := domain.MessageRepo.ForTenant(tenant).Get(msgId)
+	if errors.Is(err, error_utils.ErrNotFound) {
+		latest, err := domain.HistoryRepo.Latest(tenant, msgId)
+		if err != nil {
+			return err
+		}
+		if latest != nil && latest.Event == domain.EventDeleted {
+			return error_utils.NewNotFoundError("message not found").WithCode(error_utils.CodeMessageNotFound)
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if message.IsDeleted() {
+		return error_utils.NewNotFoundError("message not found").WithCode(error_utils.CodeMessageNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+func TestHistoryService_Hides_Deleted(t *testing.T) {
+	server := miniredis.RunT(t)
+	domain.HistoryRepo = domain.NewHistoryRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}), 0, 0)
+	assert.Nil(t, domain.HistoryRepo.Append(domain.DefaultTenant, 1, &domain.MessageVersion{Event: domain.EventCreated, Data: &domain.Message{Id: 1}}))
+	domain.MessageRepo = &getDBMock{}
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: 1, DeletedAt: &tm}, nil
+	}
+
+	versions, err := HistoryService.GetHistory(domain.DefaultTenant, 1, false)
+	assert.Nil(t, versions)
+	assert.EqualValues(t, http.StatusNotFound, err.Status())
+	version, err := HistoryService.GetVersion(domain.DefaultTenant, 1, 1, false)
+	assert.Nil(t, version)
+	assert.EqualValues(t, http.StatusNotFound, err.Status())
+
+	versions, err = HistoryService.GetHistory(domain.DefaultTenant, 1, true)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, len(versions))
+	version, err = HistoryService.GetVersion(domain.DefaultTenant, 1, 1, true)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, version.Version)
+}
+
+func TestHistoryService_Hides_Hard_Deleted(t *testing.T) {
+	server := miniredis.RunT(t)
+	domain.HistoryRepo = domain.NewHistoryRepository(redis.NewClient(&redis.Options{Addr: server.Addr()}), 0, 0)
+	assert.Nil(t, domain.HistoryRepo.Append(domain.DefaultTenant, 1, &domain.MessageVersion{Event: domain.EventCreated, Data: &domain.Message{Id: 1}}))
+	assert.Nil(t, domain.HistoryRepo.Append(domain.DefaultTenant, 1, &domain.MessageVersion{Event: domain.EventDeleted}))
+	domain.MessageRepo = &getDBMock{}
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewNotFoundError("message not found")
+	}
+
+	versions, err := HistoryService.GetHistory(domain.DefaultTenant, 1, false)
+	assert.Nil(t, versions)
+	assert.EqualValues(t, http.StatusNotFound, err.Status())
+
+	versions, err = HistoryService.GetHistory(domain.DefaultTenant, 1, true)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, len(versions))
+}
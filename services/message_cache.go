@@ -0,0 +1,179 @@
+package services
+
+import (
+	"container/list"
+	"fmt"
+	"log"
+	"sync"
+	"testing-project/domain"
+	"time"
+)
+
+var (
+	MessageCache messageCacheInterface = &messageCache{}
+)
+
+type messageCacheInterface interface {
+	Get(string, int64) (*domain.Message, bool)
+	Set(string, int64, *domain.Message, uint64)
+	Epoch() uint64
+	Invalidate(string, int64)
+	Clear()
+	Listen()
+	Stats() domain.CacheStats
+	Initialize(int, time.Duration)
+}
+
+type cacheEntry struct {
+	key       string
+	message   *domain.Message
+	expiresAt time.Time
+}
+
+// messageCache is a per-replica LRU of recently read messages whose entries
+// also expire after ttl (0 = never). A capacity of 0 disables it. Every
+// invalidation bumps the epoch, and a load only stores its result if the
+// epoch has not moved since it started, so a value read before an
+// invalidation cannot be cached after it.
+type messageCache struct {
+	mu            sync.Mutex
+	capacity      int
+	ttl           time.Duration
+	entries       map[string]*list.Element
+	order         *list.List
+	epoch         uint64
+	hits          uint64
+	misses        uint64
+	evictions     uint64
+	invalidations uint64
+}
+
+func (c *messageCache) Initialize(capacity int, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.capacity = capacity
+	c.ttl = ttl
+	c.entries = make(map[string]*list.Element)
+	c.order = list.New()
+}
+
+func NewMessageCache(capacity int, ttl time.Duration) messageCacheInterface {
+	c := &messageCache{}
+	c.Initialize(capacity, ttl)
+	return c
+}
+
+func cacheKey(tenant string, msgId int64) string {
+	return fmt.Sprintf("%s:%d", tenant, msgId)
+}
+
+func (c *messageCache) Get(tenant string, msgId int64) (*domain.Message, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capacity <= 0 {
+		return nil, false
+	}
+
+	element, ok := c.entries[cacheKey(tenant, msgId)]
+	if ok && c.ttl > 0 && time.Now().After(element.Value.(*cacheEntry).expiresAt) {
+		c.remove(element)
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).message, true
+}
+
+// Set stores a message read at the given epoch.
+func (c *messageCache) Set(tenant string, msgId int64, message *domain.Message, epoch uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.capacity <= 0 || epoch != c.epoch {
+		return
+	}
+
+	key := cacheKey(tenant, msgId)
+	entry := &cacheEntry{key: key, message: message, expiresAt: time.Now().Add(c.ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+		c.evictions++
+	}
+}
+
+func (c *messageCache) Epoch() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.epoch
+}
+
+// Invalidate drops a message on this replica and tells the other replicas
+// to do the same.
+func (c *messageCache) Invalidate(tenant string, msgId int64) {
+	c.evict(tenant, msgId)
+	if err := domain.CacheInvalidations.Publish(domain.CacheInvalidation{Tenant: tenant, MessageId: msgId}); err != nil {
+		log.Printf("Failed to publish cache invalidation: %s", err.Message())
+	}
+}
+
+// Clear drops every message cached on this replica.
+func (c *messageCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	c.invalidations += uint64(len(c.entries))
+	c.entries = make(map[string]*list.Element)
+	c.order = list.New()
+}
+
+// Listen applies the invalidations published by every replica until the
+// subscription ends.
+func (c *messageCache) Listen() {
+	invalidations, stop := domain.CacheInvalidations.Subscribe()
+	defer stop()
+	for invalidation := range invalidations {
+		c.evict(invalidation.Tenant, invalidation.MessageId)
+	}
+}
+
+func (c *messageCache) evict(tenant string, msgId int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	if element, ok := c.entries[cacheKey(tenant, msgId)]; ok {
+		c.remove(element)
+		c.invalidations++
+	}
+}
+
+func (c *messageCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).key)
+}
+
+func (c *messageCache) Stats() domain.CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := domain.CacheStats{
+		Enabled:       c.capacity > 0,
+		Capacity:      c.capacity,
+		Size:          len(c.entries),
+		Hits:          c.hits,
+		Misses:        c.misses,
+		Evictions:     c.evictions,
+		Invalidations: c.invalidations,
+	}
+	if total := c.hits + c.misses; total > 0 {
+		stats.HitRatio = float64(c.hits) / float64(total)
+	}
+	return stats
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+func TestMessageCache_Lru_Eviction(t *testing.T) {
+	cache := NewMessageCache(2, 0)
+	cache.Set("default", 1, &domain.Message{Id: 1}, 0)
+	cache.Set("default", 2, &domain.Message{Id: 2}, 0)
+	_, ok := cache.Get("default", 1)
+	assert.True(t, ok)
+	cache.Set("default", 3, &domain.Message{Id: 3}, 0)
+
+	_, ok = cache.Get("default", 2)
+	assert.False(t, ok)
+	_, ok = cache.Get("default", 1)
+	assert.True(t, ok)
+
+	stats := cache.Stats()
+	assert.EqualValues(t, 2, stats.Size)
+	assert.EqualValues(t, 2, stats.Hits)
+	assert.EqualValues(t, 1, stats.Misses)
+	assert.EqualValues(t, 1, stats.Evictions)
+}
+
+func TestMessageCache_Ttl(t *testing.T) {
+	cache := NewMessageCache(10, time.Millisecond)
+	cache.Set("default", 1, &domain.Message{Id: 1}, 0)
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := cache.Get("default", 1)
+	assert.False(t, ok)
+}
+
+func TestMessageCache_Clear(t *testing.T) {
+	cache := NewMessageCache(10, 0)
+	cache.Set("team-a", 1, &domain.Message{Id: 1}, 0)
+	staleEpoch := cache.Epoch()
+
+	cache.Clear()
+
+	_, ok := cache.Get("team-a", 1)
+	assert.False(t, ok)
+	cache.Set("team-a", 1, &domain.Message{Id: 1}, staleEpoch)
+	_, ok = cache.Get("team-a", 1)
+	assert.False(t, ok)
+	assert.EqualValues(t, 1, cache.Stats().Invalidations)
+}
+
+func TestMessageCache_Invalidate_Across_Replicas(t *testing.T) {
+	server := miniredis.RunT(t)
+	domain.CacheInvalidations = domain.NewCacheInvalidations(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+	local := NewMessageCache(10, 0)
+	remote := NewMessageCache(10, 0)
+	go remote.Listen()
+	time.Sleep(20 * time.Millisecond)
+
+	local.Set("team-a", 1, &domain.Message{Id: 1}, 0)
+	remote.Set("team-a", 1, &domain.Message{Id: 1}, 0)
+	staleEpoch := local.Epoch()
+	local.Invalidate("team-a", 1)
+
+	_, ok := local.Get("team-a", 1)
+	assert.False(t, ok)
+	assert.Eventually(t, func() bool {
+		_, ok := remote.Get("team-a", 1)
+		return !ok
+	}, time.Second, 5*time.Millisecond)
+
+	local.Set("team-a", 1, &domain.Message{Id: 1}, staleEpoch)
+	_, ok = local.Get("team-a", 1)
+	assert.False(t, ok)
+}
+
+func TestMessagesService_GetMessage_Cached(t *testing.T) {
+	MessageCache = NewMessageCache(10, time.Minute)
+	defer func() { MessageCache = NewMessageCache(0, 0) }()
+	domain.MessageRepo = &getDBMock{}
+	calls := 0
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		calls++
+		return &domain.Message{Id: messageId, Title: "the title"}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		msg, err := MessagesService.GetMessage(domain.DefaultTenant, 1, false)
+		assert.Nil(t, err)
+		assert.EqualValues(t, "the title", msg.Title)
+	}
+
+	assert.EqualValues(t, 1, calls)
+	assert.EqualValues(t, 2, MessageCache.Stats().Hits)
+}
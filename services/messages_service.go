@@ -1,6 +1,10 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"golang.org/x/sync/singleflight"
+	"log"
 	"testing-project/domain"
 	"testing-project/utils/error_utils"
 )
@@ -9,25 +13,162 @@ var (
 	MessagesService messageServiceInterface = &messagesService{}
 )
 
-type messagesService struct{}
+type messagesService struct {
+	fetches singleflight.Group
+}
 
+// Every read is scoped to the tenant passed in by the caller, so one tenant
+// can never read another tenant's messages. Soft-deleted messages are hidden
+// unless includeDeleted is set.
 type messageServiceInterface interface {
-	GetMessage(int64) (*domain.Message, error_utils.MessageErr)
-	GetAllMessages() ([]domain.Message, error_utils.MessageErr)
+	GetMessage(string, int64, bool) (*domain.Message, error_utils.MessageErr)
+	GetAllMessages(string, domain.MessageFilter, bool) ([]domain.Message, error_utils.MessageErr)
+	GetMessages(string, []int64) ([]domain.Message, error_utils.MessageErr)
+	EachMessage(string, domain.MessageFilter, bool, func(*domain.Message) error) error_utils.MessageErr
+	EachMessageByCreated(string, domain.MessageFilter, bool, func(*domain.Message) error) error_utils.MessageErr
 }
 
-func (m *messagesService) GetMessage(msgId int64) (*domain.Message, error_utils.MessageErr) {
-	message, err := domain.MessageRepo.Get(msgId)
+func (m *messagesService) GetMessage(tenant string, msgId int64, includeDeleted bool) (*domain.Message, error_utils.MessageErr) {
+	message, err := m.load(tenant, msgId)
 	if err != nil {
 		return nil, err
 	}
+	if message.IsDeleted() && !includeDeleted {
+		return nil, error_utils.NewNotFoundError("message not found").WithCode(error_utils.CodeMessageNotFound)
+	}
 	return message, nil
 }
 
-func (m *messagesService) GetAllMessages() ([]domain.Message, error_utils.MessageErr) {
-	messages, err := domain.MessageRepo.GetAll()
+// GetAllMessages lists a tenant's messages. A filtered listing with no
+// matches is empty rather than not found.
+func (m *messagesService) GetAllMessages(tenant string, filter domain.MessageFilter, includeDeleted bool) ([]domain.Message, error_utils.MessageErr) {
+	if !filter.IsEmpty() {
+		messages, err := domain.MessageRepo.ForTenant(tenant).Find(filter)
+		if err != nil {
+			return nil, err
+		}
+		if !includeDeleted {
+			messages = withoutDeleted(messages)
+		}
+		return messages, nil
+	}
+
+	messages, err := domain.MessageRepo.ForTenant(tenant).GetAll()
 	if err != nil {
 		return nil, err
 	}
+	if !includeDeleted {
+		messages = withoutDeleted(messages)
+		if len(messages) == 0 {
+			return nil, error_utils.NewNotFoundError("no messages found").WithCode(error_utils.CodeNoMessagesFound)
+		}
+	}
 	return messages, nil
 }
+
+// EachMessage is GetAllMessages for callers that stream the listing: fn is
+// called for each message as it is read. An unfiltered listing is still not
+// found when it has no messages, which is only known once fn was never called.
+func (m *messagesService) EachMessage(tenant string, filter domain.MessageFilter, includeDeleted bool, fn func(*domain.Message) error) error_utils.MessageErr {
+	if !filter.IsEmpty() {
+		return domain.MessageRepo.ForTenant(tenant).EachMatching(filter, func(message *domain.Message) error {
+			if message.IsDeleted() && !includeDeleted {
+				return nil
+			}
+			return fn(message)
+		})
+	}
+
+	found := false
+	err := domain.MessageRepo.ForTenant(tenant).Each(func(message *domain.Message) error {
+		if message.IsDeleted() && !includeDeleted {
+			return nil
+		}
+		found = true
+		return fn(message)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return error_utils.NewNotFoundError("no messages found").WithCode(error_utils.CodeNoMessagesFound)
+	}
+	return nil
+}
+
+// EachMessageByCreated is EachMessage in the order of the created-at index,
+// for callers that page through a listing with filter.After. An empty
+// listing is not an error.
+func (m *messagesService) EachMessageByCreated(tenant string, filter domain.MessageFilter, includeDeleted bool, fn func(*domain.Message) error) error_utils.MessageErr {
+	return domain.MessageRepo.ForTenant(tenant).EachByCreated(filter, func(message *domain.Message) error {
+		if message.IsDeleted() && !includeDeleted {
+			return nil
+		}
+		return fn(message)
+	})
+}
+
+func (m *messagesService) GetMessages(tenant string, msgIds []int64) ([]domain.Message, error_utils.MessageErr) {
+	messages, err := domain.MessageRepo.ForTenant(tenant).GetMany(msgIds)
+	if err != nil {
+		return nil, err
+	}
+	return withoutDeleted(messages), nil
+}
+
+// load reads a message through the in-process cache. Concurrent misses for
+// the same message share one Redis lookup, and one upstream fetch when the
+// message is missing from Redis too.
+func (m *messagesService) load(tenant string, msgId int64) (*domain.Message, error_utils.MessageErr) {
+	if message, ok := MessageCache.Get(tenant, msgId); ok {
+		return message, nil
+	}
+	result, err, _ := m.fetches.Do(fmt.Sprintf("%s:%d", tenant, msgId), func() (interface{}, error) {
+		epoch := MessageCache.Epoch()
+		message, getErr := domain.MessageRepo.ForTenant(tenant).Get(msgId)
+		if errors.Is(getErr, error_utils.ErrNotFound) && domain.Upstream.Enabled() {
+			message, getErr = readThrough(tenant, msgId)
+		}
+		if getErr != nil {
+			return nil, getErr
+		}
+		MessageCache.Set(tenant, msgId, message, epoch)
+		return message, nil
+	})
+	if err != nil {
+		return nil, err.(error_utils.MessageErr)
+	}
+	return result.(*domain.Message), nil
+}
+
+// readThrough fetches a message missing from Redis from the upstream writer
+// and stores it in Redis, unless an event stored the message while it was
+// being fetched: that version is newer and is returned instead.
+func readThrough(tenant string, msgId int64) (*domain.Message, error_utils.MessageErr) {
+	message, err := domain.Upstream.Fetch(tenant, msgId)
+	if err != nil {
+		return nil, err
+	}
+	repo := domain.MessageRepo.ForTenant(tenant)
+	saved, saveErr := repo.SaveIfAbsent(message)
+	if saveErr != nil {
+		log.Printf("Failed to cache upstream message: %s", saveErr.Message())
+		return message, nil
+	}
+	if !saved {
+		if stored, getErr := repo.Get(msgId); getErr == nil {
+			return stored, nil
+		}
+	}
+	return message, nil
+}
+
+func withoutDeleted(messages []domain.Message) []domain.Message {
+	live := messages[:0]
+	for _, message := range messages {
+		if !message.IsDeleted() {
+			live = append(live, message)
+		}
+	}
+	return live
+}
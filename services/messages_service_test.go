@@ -1,9 +1,14 @@
 package services
 
 import (
+	"encoding/json"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"testing-project/domain"
 	"testing-project/utils/error_utils"
@@ -11,28 +16,87 @@ import (
 )
 
 var (
-	tm                   = time.Now()
-	getMessageDomain     func(messageId int64) (*domain.Message, error_utils.MessageErr)
-	getAllMessagesDomain func() ([]domain.Message, error_utils.MessageErr)
+	tm                    = time.Now()
+	getMessageDomain      func(messageId int64) (*domain.Message, error_utils.MessageErr)
+	getAllMessagesDomain  func() ([]domain.Message, error_utils.MessageErr)
+	getManyMessagesDomain func(messageIds []int64) ([]domain.Message, error_utils.MessageErr)
+	findMessagesDomain    func(filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr)
 )
 
-type getDBMock struct{}
+type getDBMock struct {
+	tenant string
+}
 
+func (m *getDBMock) ForTenant(tenant string) domain.MessageRepository {
+	m.tenant = tenant
+	return m
+}
 func (m *getDBMock) Get(messageId int64) (*domain.Message, error_utils.MessageErr) {
 	return getMessageDomain(messageId)
 }
 func (m *getDBMock) GetAll() ([]domain.Message, error_utils.MessageErr) {
 	return getAllMessagesDomain()
 }
+func (m *getDBMock) Each(fn func(*domain.Message) error) error_utils.MessageErr {
+	messages, err := getAllMessagesDomain()
+	if err != nil {
+		return err
+	}
+	for i := range messages {
+		if err := fn(&messages[i]); err != nil {
+			return error_utils.NewInternalServerError(err.Error())
+		}
+	}
+	return nil
+}
+func (m *getDBMock) GetMany(messageIds []int64) ([]domain.Message, error_utils.MessageErr) {
+	return getManyMessagesDomain(messageIds)
+}
+func (m *getDBMock) Find(filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr) {
+	return findMessagesDomain(filter)
+}
+func (m *getDBMock) EachByCreated(filter domain.MessageFilter, fn func(*domain.Message) error) error_utils.MessageErr {
+	messages, err := findMessagesDomain(filter)
+	if err != nil {
+		return err
+	}
+	for i := range messages {
+		if err := fn(&messages[i]); err != nil {
+			return error_utils.NewInternalServerError(err.Error()).Wrap(err)
+		}
+	}
+	return nil
+}
+func (m *getDBMock) EachMatching(filter domain.MessageFilter, fn func(*domain.Message) error) error_utils.MessageErr {
+	return m.EachByCreated(filter, fn)
+}
 func (m *getDBMock) Save(*domain.Message) error_utils.MessageErr {
 	return nil
 }
+func (m *getDBMock) SaveIfAbsent(*domain.Message) (bool, error_utils.MessageErr) {
+	return true, nil
+}
 func (m *getDBMock) Update(*domain.Message) error_utils.MessageErr {
 	return nil
 }
 func (m *getDBMock) Delete(int64) error_utils.MessageErr {
 	return nil
 }
+func (m *getDBMock) SoftDelete(int64) error_utils.MessageErr {
+	return nil
+}
+func (m *getDBMock) Restore(int64) (*domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (m *getDBMock) ApplyBatch([]domain.MessageWrite) ([]domain.MessageWriteResult, error_utils.MessageErr) {
+	return nil, nil
+}
+func (m *getDBMock) PurgeDeleted(time.Time) (map[string][]int64, error_utils.MessageErr) {
+	return nil, nil
+}
+func (m *getDBMock) Reindex() (int, error_utils.MessageErr) {
+	return 0, nil
+}
 func (m *getDBMock) Initialize(a, b, c string) *redis.Client {
 	return nil
 }
@@ -49,7 +113,7 @@ func TestMessagesService_GetMessage_Success(t *testing.T) {
 			CreatedAt: tm,
 		}, nil
 	}
-	msg, err := MessagesService.GetMessage(1)
+	msg, err := MessagesService.GetMessage(domain.DefaultTenant, 1, false)
 	assert.NotNil(t, msg)
 	assert.Nil(t, err)
 	assert.EqualValues(t, 1, msg.Id)
@@ -63,7 +127,7 @@ func TestMessagesService_GetMessage_NotFoundID(t *testing.T) {
 	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
 		return nil, error_utils.NewNotFoundError("the id is not found")
 	}
-	msg, err := MessagesService.GetMessage(1)
+	msg, err := MessagesService.GetMessage(domain.DefaultTenant, 1, false)
 	assert.Nil(t, msg)
 	assert.NotNil(t, err)
 	assert.EqualValues(t, http.StatusNotFound, err.Status())
@@ -71,6 +135,74 @@ func TestMessagesService_GetMessage_NotFoundID(t *testing.T) {
 	assert.EqualValues(t, "not_found", err.Error())
 }
 
+func TestMessagesService_GetMessage_Deleted(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: 1, DeletedAt: &tm}, nil
+	}
+	msg, err := MessagesService.GetMessage(domain.DefaultTenant, 1, false)
+	assert.Nil(t, msg)
+	assert.EqualValues(t, http.StatusNotFound, err.Status())
+
+	msg, err = MessagesService.GetMessage(domain.DefaultTenant, 1, true)
+	assert.Nil(t, err)
+	assert.True(t, msg.IsDeleted())
+}
+
+func TestMessagesService_GetMessage_Read_Through(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	var calls int32
+	release := make(chan struct{})
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		json.NewEncoder(w).Encode(domain.Message{Id: 9, Title: "from upstream"})
+	}))
+	defer stub.Close()
+	domain.MessageRepo = domain.NewMessageRepository(client)
+	domain.Upstream = domain.NewUpstream(client, stub.URL, time.Second, time.Minute)
+	defer func() { domain.Upstream = domain.NewUpstream(nil, "", 0, 0) }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msg, err := MessagesService.GetMessage(domain.DefaultTenant, 9, false)
+			assert.Nil(t, err)
+			assert.EqualValues(t, "from upstream", msg.Title)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+	assert.True(t, server.Exists("message:9"))
+}
+
+func TestMessagesService_GetMessage_Read_Through_Keeps_Applied_Event(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	domain.MessageRepo = domain.NewMessageRepository(client)
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// An event stores the message while the writer answers.
+		assert.Nil(t, domain.MessageRepo.Save(&domain.Message{Id: 10, Title: "from event"}))
+		json.NewEncoder(w).Encode(domain.Message{Id: 10, Title: "from upstream"})
+	}))
+	defer stub.Close()
+	domain.Upstream = domain.NewUpstream(client, stub.URL, time.Second, time.Minute)
+	defer func() { domain.Upstream = domain.NewUpstream(nil, "", 0, 0) }()
+
+	msg, err := MessagesService.GetMessage(domain.DefaultTenant, 10, false)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, "from event", msg.Title)
+	stored, _ := domain.MessageRepo.Get(10)
+	assert.EqualValues(t, "from event", stored.Title)
+}
+
 // "GetAllMessages" test cases
 
 func TestMessagesService_GetAllMessages(t *testing.T) {
@@ -81,7 +213,7 @@ func TestMessagesService_GetAllMessages(t *testing.T) {
 			{Id: 2, Title: "second title", Body: "second body"},
 		}, nil
 	}
-	messages, err := MessagesService.GetAllMessages()
+	messages, err := MessagesService.GetAllMessages(domain.DefaultTenant, domain.MessageFilter{}, false)
 	assert.Nil(t, err)
 	assert.NotNil(t, messages)
 	assert.EqualValues(t, 2, len(messages))
@@ -98,10 +230,93 @@ func TestMessagesService_GetAllMessages_Error_Getting_Messages(t *testing.T) {
 	getAllMessagesDomain = func() ([]domain.Message, error_utils.MessageErr) {
 		return nil, error_utils.NewInternalServerError("error getting messages")
 	}
-	messages, err := MessagesService.GetAllMessages()
+	messages, err := MessagesService.GetAllMessages(domain.DefaultTenant, domain.MessageFilter{}, false)
 	assert.NotNil(t, err)
 	assert.Nil(t, messages)
 	assert.EqualValues(t, http.StatusInternalServerError, err.Status())
 	assert.EqualValues(t, "error getting messages", err.Message())
 	assert.EqualValues(t, "server_error", err.Error())
 }
+
+func TestMessagesService_GetAllMessages_Deleted(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	getAllMessagesDomain = func() ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{{Id: 1}, {Id: 2, DeletedAt: &tm}}, nil
+	}
+	messages, err := MessagesService.GetAllMessages(domain.DefaultTenant, domain.MessageFilter{}, false)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 1, len(messages))
+	assert.EqualValues(t, 1, messages[0].Id)
+
+	messages, err = MessagesService.GetAllMessages(domain.DefaultTenant, domain.MessageFilter{}, true)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, len(messages))
+}
+
+func TestMessagesService_GetAllMessages_Filtered(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	var requested domain.MessageFilter
+	findMessagesDomain = func(filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr) {
+		requested = filter
+		return []domain.Message{{Id: 2, DeletedAt: &tm}}, nil
+	}
+	messages, err := MessagesService.GetAllMessages(domain.DefaultTenant, domain.MessageFilter{TitlePrefix: "rel"}, false)
+	assert.Nil(t, err)
+	assert.NotNil(t, messages)
+	assert.EqualValues(t, 0, len(messages))
+	assert.EqualValues(t, "rel", requested.TitlePrefix)
+}
+
+// "EachMessage" test cases
+
+func TestMessagesService_EachMessage(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	getAllMessagesDomain = func() ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{{Id: 1}, {Id: 2, DeletedAt: &tm}, {Id: 3}}, nil
+	}
+	var ids []int64
+	err := MessagesService.EachMessage(domain.DefaultTenant, domain.MessageFilter{}, false, func(message *domain.Message) error {
+		ids = append(ids, message.Id)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.EqualValues(t, []int64{1, 3}, ids)
+}
+
+func TestMessagesService_EachMessage_NotFound(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	getAllMessagesDomain = func() ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{{Id: 2, DeletedAt: &tm}}, nil
+	}
+	err := MessagesService.EachMessage(domain.DefaultTenant, domain.MessageFilter{}, false, func(message *domain.Message) error {
+		return nil
+	})
+	assert.EqualValues(t, http.StatusNotFound, err.Status())
+	assert.EqualValues(t, "no messages found", err.Message())
+
+	findMessagesDomain = func(filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{}, nil
+	}
+	err = MessagesService.EachMessage(domain.DefaultTenant, domain.MessageFilter{TitlePrefix: "rel"}, false, func(message *domain.Message) error {
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+// "GetMessages" test cases
+
+func TestMessagesService_GetMessages(t *testing.T) {
+	repo := &getDBMock{}
+	domain.MessageRepo = repo
+	var requested []int64
+	getManyMessagesDomain = func(messageIds []int64) ([]domain.Message, error_utils.MessageErr) {
+		requested = messageIds
+		return []domain.Message{{Id: 3, Title: "third title"}}, nil
+	}
+	messages, err := MessagesService.GetMessages("team-a", []int64{3, 4})
+	assert.Nil(t, err)
+	assert.EqualValues(t, "team-a", repo.tenant)
+	assert.EqualValues(t, []int64{3, 4}, requested)
+	assert.EqualValues(t, 1, len(messages))
+	assert.EqualValues(t, "third title", messages[0].Title)
+}
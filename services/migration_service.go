@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+var (
+	MigrationService migrationServiceInterface = &migrationService{}
+)
+
+type migrationService struct{}
+
+type migrationServiceInterface interface {
+	GetStatus() domain.MigrationStatus
+	SetReadFrom(string) (domain.MigrationStatus, error_utils.MessageErr)
+	StartBackfill() (domain.MigrationStatus, error_utils.MessageErr)
+	Verify() (*domain.MigrationReport, error_utils.MessageErr)
+}
+
+func migrationDisabled() error_utils.MessageErr {
+	return error_utils.NewNotFoundError("migration mode is not enabled").WithCode(error_utils.CodeMigrationDisabled)
+}
+
+func (s *migrationService) GetStatus() domain.MigrationStatus {
+	return domain.Migration.Status()
+}
+
+// SetReadFrom switches reads on every replica and drops the cached messages
+// of this one, which were read from the other store.
+func (s *migrationService) SetReadFrom(source string) (domain.MigrationStatus, error_utils.MessageErr) {
+	if !domain.Migration.Enabled() {
+		return domain.MigrationStatus{}, migrationDisabled()
+	}
+	if err := domain.Migration.SetReadFrom(source); err != nil {
+		return domain.MigrationStatus{}, err
+	}
+	MessageCache.Clear()
+	return domain.Migration.Status(), nil
+}
+
+func (s *migrationService) StartBackfill() (domain.MigrationStatus, error_utils.MessageErr) {
+	if !domain.Migration.Enabled() {
+		return domain.MigrationStatus{}, migrationDisabled()
+	}
+	if err := domain.Migration.StartBackfill(); err != nil {
+		return domain.MigrationStatus{}, err
+	}
+	return domain.Migration.Status(), nil
+}
+
+func (s *migrationService) Verify() (*domain.MigrationReport, error_utils.MessageErr) {
+	if !domain.Migration.Enabled() {
+		return nil, migrationDisabled()
+	}
+	return domain.Migration.Verify()
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+// reconcileBatchSize is how many source messages are looked up in Redis
+// with one GetMany.
+const reconcileBatchSize = 100
+
+var (
+	ReconcileService reconcileServiceInterface = &reconcileService{}
+)
+
+// MessageSource calls fn for every message of an authoritative source, such
+// as a snapshot of the writer's database, until fn fails.
+type MessageSource func(fn func(*domain.Message) error_utils.MessageErr) error_utils.MessageErr
+
+// RepairFunc applies the event that brings one message in Redis in line with
+// the source: domain.EventUpdated with the message as the source has it, or
+// domain.EventDeleted with an extra message. It is meant to go through the
+// handlers of the event queue, so a repair is announced and recorded like
+// any other change.
+type RepairFunc func(tenant, event string, msg *domain.Message) error_utils.MessageErr
+
+type reconcileService struct{}
+
+type reconcileServiceInterface interface {
+	Reconcile(tenant string, source MessageSource, asOf time.Time, repair RepairFunc, report func(domain.MessageDrift)) (*domain.ReconcileSummary, error_utils.MessageErr)
+}
+
+// Reconcile compares the messages of tenant in Redis with source and calls
+// report for every difference. Soft-deleted messages count as absent on
+// both sides, as they do for readers. When asOf is set, the time the source
+// was taken, a difference in a message Redis created, changed or deleted
+// after it is the source being behind; it counts as newer and is neither
+// reported nor repaired. A message deleted for good after asOf leaves no
+// trace, so it is reported missing. With repair, missing and mismatched
+// messages are updated to what the source has and extra ones are deleted.
+func (s *reconcileService) Reconcile(tenant string, source MessageSource, asOf time.Time, repair RepairFunc, report func(domain.MessageDrift)) (*domain.ReconcileSummary, error_utils.MessageErr) {
+	repo := domain.MessageRepo.ForTenant(tenant)
+	summary := &domain.ReconcileSummary{}
+	found := func(drift domain.MessageDrift, stored *domain.Message, event string, msg *domain.Message) error_utils.MessageErr {
+		newer, err := changedAfter(tenant, drift.MessageId, stored, asOf)
+		if err != nil {
+			return err
+		}
+		if newer {
+			summary.Newer++
+			return nil
+		}
+		switch drift.Kind {
+		case domain.DriftMissing:
+			summary.Missing++
+		case domain.DriftExtra:
+			summary.Extra++
+		case domain.DriftMismatched:
+			summary.Mismatched++
+		}
+		if repair != nil {
+			if err := repair(tenant, event, msg); err != nil {
+				summary.RepairFailed++
+				drift.RepairError = err.Message()
+			} else {
+				summary.Repaired++
+				drift.Repaired = true
+			}
+		}
+		report(drift)
+		return nil
+	}
+
+	// live holds the ids the source has live messages for.
+	live := make(map[int64]struct{})
+	var batch []domain.Message
+	compare := func() error_utils.MessageErr {
+		ids := make([]int64, len(batch))
+		for i := range batch {
+			ids[i] = batch[i].Id
+		}
+		stored, err := repo.GetMany(ids)
+		if err != nil {
+			return err
+		}
+		byId := make(map[int64]*domain.Message, len(stored))
+		for i := range stored {
+			byId[stored[i].Id] = &stored[i]
+		}
+		for i := range batch {
+			want := &batch[i]
+			summary.Checked++
+			drift := domain.MessageDrift{Tenant: tenant, MessageId: want.Id}
+			got := byId[want.Id]
+			if got == nil || got.IsDeleted() {
+				drift.Kind = domain.DriftMissing
+			} else if drift.Fields = domain.DiffMessages(want, got); len(drift.Fields) > 0 {
+				drift.Kind = domain.DriftMismatched
+			} else {
+				continue
+			}
+			if err := found(drift, got, domain.EventUpdated, want); err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	err := source(func(msg *domain.Message) error_utils.MessageErr {
+		if msg.IsDeleted() {
+			return nil
+		}
+		live[msg.Id] = struct{}{}
+		batch = append(batch, *msg)
+		if len(batch) < reconcileBatchSize {
+			return nil
+		}
+		return compare()
+	})
+	if err == nil && len(batch) > 0 {
+		err = compare()
+	}
+	if err != nil {
+		return summary, err
+	}
+
+	err = repo.Each(func(msg *domain.Message) error {
+		if _, ok := live[msg.Id]; ok || msg.IsDeleted() {
+			return nil
+		}
+		summary.Checked++
+		if err := found(domain.MessageDrift{Tenant: tenant, MessageId: msg.Id, Kind: domain.DriftExtra}, msg, domain.EventDeleted, msg); err != nil {
+			return err
+		}
+		return nil
+	})
+	return summary, err
+}
+
+// changedAfter tells whether the stored copy of a message, which may be nil,
+// was created, changed or deleted after asOf. Nothing is when asOf is zero.
+func changedAfter(tenant string, messageId int64, stored *domain.Message, asOf time.Time) (bool, error_utils.MessageErr) {
+	if asOf.IsZero() {
+		return false, nil
+	}
+	if stored != nil && (stored.CreatedAt.After(asOf) || stored.IsDeleted() && stored.DeletedAt.After(asOf)) {
+		return true, nil
+	}
+	latest, err := domain.HistoryRepo.Latest(tenant, messageId)
+	if err != nil {
+		return false, err
+	}
+	return latest != nil && latest.AppliedAt.After(asOf), nil
+}
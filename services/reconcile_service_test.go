@@ -0,0 +1,113 @@
+package services
+
+import (
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+func sliceSource(messages ...domain.Message) MessageSource {
+	return func(fn func(*domain.Message) error_utils.MessageErr) error_utils.MessageErr {
+		for i := range messages {
+			if err := fn(&messages[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// repairWith applies repairs straight to the repository and records them as
+// "<event> <id>".
+func repairWith(applied *[]string) RepairFunc {
+	return func(tenant, event string, msg *domain.Message) error_utils.MessageErr {
+		*applied = append(*applied, fmt.Sprintf("%s %d", event, msg.Id))
+		repo := domain.MessageRepo.ForTenant(tenant)
+		if event == domain.EventDeleted {
+			return repo.Delete(msg.Id)
+		}
+		return repo.Save(msg)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	domain.MessageRepo = domain.NewMessageRepository(client)
+	domain.CacheInvalidations = domain.NewCacheInvalidations(client)
+	repo := domain.MessageRepo.ForTenant("team-a")
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	same := domain.Message{Id: 1, Title: "same", Body: "body", CreatedAt: created}
+	assert.Nil(t, repo.Save(&same))
+	assert.Nil(t, repo.Save(&domain.Message{Id: 2, Title: "old title", Body: "body", CreatedAt: created}))
+	assert.Nil(t, repo.Save(&domain.Message{Id: 4, Title: "extra", Body: "body", CreatedAt: created}))
+	assert.Nil(t, repo.Save(&domain.Message{Id: 5, Title: "deleted", Body: "body", CreatedAt: created}))
+	assert.Nil(t, repo.SoftDelete(5))
+	deletedAt := created.Add(time.Hour)
+	source := sliceSource(
+		same,
+		domain.Message{Id: 2, Title: "new title", Body: "body", CreatedAt: created},
+		domain.Message{Id: 3, Title: "missing", Body: "body", CreatedAt: created},
+		domain.Message{Id: 6, Title: "deleted upstream", Body: "body", CreatedAt: created, DeletedAt: &deletedAt},
+	)
+
+	var drift []domain.MessageDrift
+	summary, err := ReconcileService.Reconcile("team-a", source, time.Time{}, nil, func(d domain.MessageDrift) { drift = append(drift, d) })
+
+	assert.Nil(t, err)
+	assert.Equal(t, domain.ReconcileSummary{Checked: 4, Missing: 1, Extra: 1, Mismatched: 1}, *summary)
+	assert.False(t, summary.InSync())
+	assert.Equal(t, []domain.MessageDrift{
+		{Tenant: "team-a", MessageId: 2, Kind: domain.DriftMismatched, Fields: []string{"title"}},
+		{Tenant: "team-a", MessageId: 3, Kind: domain.DriftMissing},
+		{Tenant: "team-a", MessageId: 4, Kind: domain.DriftExtra},
+	}, drift)
+
+	var applied []string
+	summary, err = ReconcileService.Reconcile("team-a", source, time.Time{}, repairWith(&applied), func(d domain.MessageDrift) { assert.True(t, d.Repaired) })
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, summary.Repaired)
+	assert.Equal(t, []string{"updated 2", "updated 3", "deleted 4"}, applied)
+	assert.True(t, summary.InSync())
+	updated, _ := repo.Get(2)
+	assert.Equal(t, "new title", updated.Title)
+	assert.False(t, server.Exists("tenant:team-a:message:4"))
+
+	summary, _ = ReconcileService.Reconcile("team-a", source, time.Time{}, nil, func(domain.MessageDrift) {})
+	assert.Equal(t, domain.ReconcileSummary{Checked: 3}, *summary)
+}
+
+func TestReconcile_Leaves_Messages_Changed_After_The_Source(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	domain.MessageRepo = domain.NewMessageRepository(client)
+	domain.HistoryRepo = domain.NewHistoryRepository(client, 10, 0)
+	repo := domain.MessageRepo.ForTenant("team-a")
+	asOf := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	before, after := asOf.Add(-time.Hour), asOf.Add(time.Hour)
+	assert.Nil(t, repo.Save(&domain.Message{Id: 1, Title: "edited since", Body: "body", CreatedAt: before}))
+	assert.Nil(t, domain.HistoryRepo.Append("team-a", 1, &domain.MessageVersion{Event: domain.EventUpdated, AppliedAt: after}))
+	assert.Nil(t, repo.Save(&domain.Message{Id: 2, Title: "created since", Body: "body", CreatedAt: after}))
+	assert.Nil(t, repo.Save(&domain.Message{Id: 3, Title: "stale", Body: "body", CreatedAt: before}))
+	assert.Nil(t, domain.HistoryRepo.Append("team-a", 3, &domain.MessageVersion{Event: domain.EventCreated, AppliedAt: before}))
+	source := sliceSource(
+		domain.Message{Id: 1, Title: "as of", Body: "body", CreatedAt: before},
+		domain.Message{Id: 3, Title: "as of", Body: "body", CreatedAt: before},
+	)
+
+	var applied []string
+	summary, err := ReconcileService.Reconcile("team-a", source, asOf, repairWith(&applied), func(domain.MessageDrift) {})
+
+	assert.Nil(t, err)
+	assert.Equal(t, domain.ReconcileSummary{Checked: 3, Mismatched: 1, Newer: 2, Repaired: 1}, *summary)
+	assert.Equal(t, []string{"updated 3"}, applied)
+	kept, _ := repo.Get(1)
+	assert.Equal(t, "edited since", kept.Title)
+	assert.True(t, server.Exists("tenant:team-a:message:2"))
+}
@@ -0,0 +1,21 @@
+package services
+
+import (
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+var (
+	StatsService statsServiceInterface = &statsService{}
+)
+
+type statsService struct{}
+
+type statsServiceInterface interface {
+	GetStats(string, string, time.Time, time.Time) (*domain.MessageStats, error_utils.MessageErr)
+}
+
+func (s *statsService) GetStats(tenant, bucket string, from, to time.Time) (*domain.MessageStats, error_utils.MessageErr) {
+	return domain.StatsRepo.Get(tenant, bucket, from, to)
+}
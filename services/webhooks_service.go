@@ -0,0 +1,374 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+)
+
+const (
+	// retryLease is how long a claimed retry is kept from other replicas.
+	// It outlasts an attempt, which the HTTP client times out.
+	retryLease     = time.Minute
+	retryBatchSize = 100
+
+	// deliveryWorkers is how many first attempts run at once, and
+	// deliveryQueueSize how many more wait for a worker.
+	deliveryWorkers   = 16
+	deliveryQueueSize = 1000
+)
+
+var (
+	WebhooksService webhooksServiceInterface = &webhooksService{
+		httpClient:  newWebhookClient(false),
+		maxAttempts: 5,
+		backoff:     time.Second,
+		workers:     deliveryWorkers,
+		queueSize:   deliveryQueueSize,
+	}
+)
+
+type webhooksServiceInterface interface {
+	GetWebhooks() ([]domain.Webhook, error_utils.MessageErr)
+	CreateWebhook(*domain.Webhook) (*domain.Webhook, error_utils.MessageErr)
+	DeleteWebhook(string) error_utils.MessageErr
+	GetDeliveries(string) ([]domain.WebhookDelivery, error_utils.MessageErr)
+	Dispatch(domain.MessageChange)
+	RetryDue() int
+	Wait()
+	Initialize(bool)
+}
+
+// webhooksService delivers every applied change to the matching
+// subscriptions. Failed deliveries are retried with exponential backoff and
+// every attempt is written to the subscription's delivery log. Retries wait
+// in Redis until RetryDue, which every replica runs periodically, attempts
+// them, so a restart does not lose them. First attempts are made by a fixed
+// number of workers. Subscriptions may not reach internal addresses unless
+// allowInternal.
+type webhooksService struct {
+	httpClient    *http.Client
+	maxAttempts   int
+	backoff       time.Duration
+	allowInternal bool
+	workers       int
+	queueSize     int
+
+	// queue feeds first attempts to the workers, which start with the first
+	// Dispatch; dispatched tracks the attempts queued.
+	queue      chan firstAttempt
+	startQueue sync.Once
+	dispatched sync.WaitGroup
+}
+
+// firstAttempt is a delivery Dispatch queued for the workers.
+type firstAttempt struct {
+	webhook domain.Webhook
+	retry   *domain.WebhookRetry
+}
+
+// Initialize sets whether subscriptions may reach internal addresses:
+// loopback, private and link-local ones.
+func (s *webhooksService) Initialize(allowInternal bool) {
+	s.allowInternal = allowInternal
+	s.httpClient = newWebhookClient(allowInternal)
+}
+
+func (s *webhooksService) GetWebhooks() ([]domain.Webhook, error_utils.MessageErr) {
+	webhooks, err := domain.WebhookRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+// CreateWebhook stores a new subscription. The signing secret is generated
+// when not supplied and is only ever returned here.
+func (s *webhooksService) CreateWebhook(webhook *domain.Webhook) (*domain.Webhook, error_utils.MessageErr) {
+	if err := webhook.Validate(); err != nil {
+		return nil, err
+	}
+	if !s.allowInternal && internalHost(webhook.Url) {
+		return nil, error_utils.NewUnprocessibleEntityError("Webhook url must not point to an internal address").WithCode(error_utils.CodeInvalidWebhook)
+	}
+	webhook.Id = randomHex(8)
+	if webhook.Secret == "" {
+		webhook.Secret = randomHex(32)
+	}
+	webhook.CreatedAt = time.Now().UTC()
+	if err := domain.WebhookRepo.Save(webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (s *webhooksService) DeleteWebhook(webhookId string) error_utils.MessageErr {
+	return domain.WebhookRepo.Delete(webhookId)
+}
+
+func (s *webhooksService) GetDeliveries(webhookId string) ([]domain.WebhookDelivery, error_utils.MessageErr) {
+	if _, err := domain.WebhookRepo.Get(webhookId); err != nil {
+		return nil, err
+	}
+	return domain.WebhookRepo.GetDeliveries(webhookId)
+}
+
+// Dispatch fans a change out to the subscriptions without blocking the
+// caller. Deliveries that find the queue full are stored as retries due now,
+// for RetryDue to attempt; those that cannot be stored either are dropped.
+func (s *webhooksService) Dispatch(change domain.MessageChange) {
+	webhooks, err := domain.WebhookRepo.GetAll()
+	if err != nil {
+		log.Printf("Failed to load webhooks: %s", err.Message())
+		return
+	}
+	body, marshalErr := json.Marshal(change)
+	if marshalErr != nil {
+		log.Printf("Failed to marshal change: %s", marshalErr)
+		return
+	}
+	s.startQueue.Do(s.startWorkers)
+	var deferred, dropped int
+	for _, webhook := range webhooks {
+		if webhook.Accepts(&change) {
+			retry := &domain.WebhookRetry{
+				Id:        randomHex(8),
+				WebhookId: webhook.Id,
+				ChangeId:  change.Id,
+				Event:     change.Event,
+				Body:      body,
+				Attempt:   1,
+			}
+			s.dispatched.Add(1)
+			select {
+			case s.queue <- firstAttempt{webhook: webhook, retry: retry}:
+			default:
+				s.dispatched.Done()
+				if err := domain.WebhookRepo.ScheduleRetry(retry, time.Now()); err != nil {
+					log.Printf("Failed to store webhook %s delivery of change %s: %s", webhook.Id, change.Id, err.Message())
+					dropped++
+				} else {
+					deferred++
+				}
+			}
+		}
+	}
+	if deferred > 0 || dropped > 0 {
+		log.Printf("Webhook delivery queue full: %d deliveries of change %s stored for retry, %d dropped", deferred, change.Id, dropped)
+	}
+}
+
+func (s *webhooksService) startWorkers() {
+	workers, queueSize := s.workers, s.queueSize
+	if workers <= 0 {
+		workers = deliveryWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = deliveryQueueSize
+	}
+	s.queue = make(chan firstAttempt, queueSize)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for attempt := range s.queue {
+				s.deliver(attempt.webhook, attempt.retry, false)
+				s.dispatched.Done()
+			}
+		}()
+	}
+}
+
+// Wait waits for the first attempts Dispatch queued, so a process that is
+// about to exit has delivered its changes or stored their retries for the
+// service's replicas.
+func (s *webhooksService) Wait() {
+	s.dispatched.Wait()
+}
+
+// RetryDue attempts the pending retries that are due, whichever replica
+// scheduled them, concurrently, and returns how many it claimed.
+func (s *webhooksService) RetryDue() int {
+	retries, err := domain.WebhookRepo.ClaimRetries(time.Now(), retryLease, retryBatchSize)
+	if err != nil {
+		log.Printf("Failed to claim webhook retries: %s", err.Message())
+		return 0
+	}
+	var wg sync.WaitGroup
+	for i := range retries {
+		retry := &retries[i]
+		webhook, err := domain.WebhookRepo.Get(retry.WebhookId)
+		if errors.Is(err, error_utils.ErrNotFound) {
+			s.complete(retry)
+			continue
+		} else if err != nil {
+			log.Printf("Failed to load webhook %s: %s", retry.WebhookId, err.Message())
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.deliver(*webhook, retry, true)
+		}()
+	}
+	wg.Wait()
+	return len(retries)
+}
+
+// deliver makes one attempt of a delivery and schedules the next one when it
+// fails, until maxAttempts. stored tells whether retry was read from the
+// retries stored in Redis, which then have to forget it once it is done.
+func (s *webhooksService) deliver(webhook domain.Webhook, retry *domain.WebhookRetry, stored bool) {
+	delivery := &domain.WebhookDelivery{
+		Id:          randomHex(8),
+		WebhookId:   webhook.Id,
+		ChangeId:    retry.ChangeId,
+		Event:       retry.Event,
+		Attempt:     retry.Attempt,
+		AttemptedAt: time.Now().UTC(),
+	}
+
+	statusCode, err := s.post(webhook, retry.Event, delivery.Id, retry.Body)
+	delivery.StatusCode = statusCode
+	if err != nil {
+		delivery.Error = err.Error()
+	} else {
+		delivery.Success = true
+	}
+	if logErr := domain.WebhookRepo.LogDelivery(delivery); logErr != nil {
+		log.Printf("Failed to log webhook delivery: %s", logErr.Message())
+	}
+
+	if delivery.Success || retry.Attempt >= s.maxAttempts {
+		if !delivery.Success {
+			log.Printf("Giving up on webhook %s for change %s after %d attempts", webhook.Id, retry.ChangeId, retry.Attempt)
+		}
+		if stored {
+			s.complete(retry)
+		}
+		return
+	}
+	next := *retry
+	next.Attempt++
+	if err := domain.WebhookRepo.ScheduleRetry(&next, time.Now().Add(s.backoff<<(retry.Attempt-1))); err != nil {
+		log.Printf("Failed to schedule webhook %s retry for change %s: %s", webhook.Id, retry.ChangeId, err.Message())
+	}
+}
+
+// complete forgets a stored retry.
+func (s *webhooksService) complete(retry *domain.WebhookRetry) {
+	if err := domain.WebhookRepo.CompleteRetry(retry.Id); err != nil {
+		log.Printf("Failed to remove webhook retry %s: %s", retry.Id, err.Message())
+	}
+}
+
+func (s *webhooksService) post(webhook domain.Webhook, event, deliveryId string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", webhook.Id)
+	req.Header.Set("X-Webhook-Event", event)
+	req.Header.Set("X-Webhook-Delivery", deliveryId)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+Sign(webhook.Secret, timestamp, body))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// newWebhookClient makes the client deliveries are posted with. Unless
+// allowInternal, it refuses to connect to an internal address whatever a
+// url's host resolves to, redirects included, and ignores proxy settings,
+// through which it could not tell.
+func newWebhookClient(allowInternal bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowInternal {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: refuseInternal}
+		transport.DialContext = dialer.DialContext
+		transport.Proxy = nil
+	}
+	return &http.Client{Timeout: 5 * time.Second, Transport: transport}
+}
+
+// refuseInternal is the dialer's check of the address it is about to
+// connect to.
+func refuseInternal(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || internalAddress(ip) {
+		return fmt.Errorf("webhook address %s is internal", host)
+	}
+	return nil
+}
+
+// internalHost tells whether the host of rawUrl is localhost or an internal
+// address, so such a subscription is refused up front; names resolving to
+// one are caught when delivering.
+func internalHost(rawUrl string) bool {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && internalAddress(ip)
+}
+
+func internalAddress(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+// Sign computes the hex HMAC-SHA256 of "<timestamp>.<body>" that receivers
+// compare against the X-Webhook-Signature header.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+type webhookRepoMock struct {
+	mu         sync.Mutex
+	webhooks   []domain.Webhook
+	deliveries []domain.WebhookDelivery
+	retries    map[string]domain.WebhookRetry
+	retryAt    map[string]time.Time
+}
+
+func (m *webhookRepoMock) Get(id string) (*domain.Webhook, error_utils.MessageErr) {
+	for _, webhook := range m.webhooks {
+		if webhook.Id == id {
+			return &webhook, nil
+		}
+	}
+	return nil, error_utils.NewNotFoundError("webhook not found")
+}
+func (m *webhookRepoMock) GetAll() ([]domain.Webhook, error_utils.MessageErr) {
+	return append([]domain.Webhook(nil), m.webhooks...), nil
+}
+func (m *webhookRepoMock) Save(webhook *domain.Webhook) error_utils.MessageErr {
+	m.webhooks = append(m.webhooks, *webhook)
+	return nil
+}
+func (m *webhookRepoMock) Delete(string) error_utils.MessageErr {
+	return nil
+}
+func (m *webhookRepoMock) LogDelivery(delivery *domain.WebhookDelivery) error_utils.MessageErr {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries = append(m.deliveries, *delivery)
+	return nil
+}
+func (m *webhookRepoMock) GetDeliveries(string) ([]domain.WebhookDelivery, error_utils.MessageErr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]domain.WebhookDelivery(nil), m.deliveries...), nil
+}
+func (m *webhookRepoMock) ScheduleRetry(retry *domain.WebhookRetry, at time.Time) error_utils.MessageErr {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.retries == nil {
+		m.retries = make(map[string]domain.WebhookRetry)
+		m.retryAt = make(map[string]time.Time)
+	}
+	m.retries[retry.Id] = *retry
+	m.retryAt[retry.Id] = at
+	return nil
+}
+func (m *webhookRepoMock) ClaimRetries(now time.Time, lease time.Duration, limit int) ([]domain.WebhookRetry, error_utils.MessageErr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []domain.WebhookRetry
+	for id, at := range m.retryAt {
+		if !at.After(now) && len(due) < limit {
+			due = append(due, m.retries[id])
+			m.retryAt[id] = now.Add(lease)
+		}
+	}
+	return due, nil
+}
+func (m *webhookRepoMock) CompleteRetry(id string) error_utils.MessageErr {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.retries, id)
+	delete(m.retryAt, id)
+	return nil
+}
+func (m *webhookRepoMock) pendingRetries() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.retries)
+}
+func (m *webhookRepoMock) Initialize(*redis.Client) {}
+
+func TestWebhooksService_CreateWebhook(t *testing.T) {
+	repo := &webhookRepoMock{}
+	domain.WebhookRepo = repo
+	service := &webhooksService{}
+
+	created, err := service.CreateWebhook(&domain.Webhook{Url: "http://example.com/hook"})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, created.Id)
+	assert.Len(t, created.Secret, 64)
+
+	webhooks, err := service.GetWebhooks()
+	assert.Nil(t, err)
+	assert.Len(t, webhooks, 1)
+	assert.Empty(t, webhooks[0].Secret)
+}
+
+func TestWebhooksService_CreateWebhook_Invalid(t *testing.T) {
+	domain.WebhookRepo = &webhookRepoMock{}
+	service := &webhooksService{}
+
+	created, err := service.CreateWebhook(&domain.Webhook{Url: "not a url"})
+	assert.Nil(t, created)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+}
+
+func TestWebhooksService_CreateWebhook_Internal(t *testing.T) {
+	domain.WebhookRepo = &webhookRepoMock{}
+	service := &webhooksService{}
+
+	for _, url := range []string{"http://localhost:8080/hook", "http://127.0.0.1/hook", "http://10.0.0.5/hook", "http://169.254.169.254/latest", "http://[::1]/hook"} {
+		created, err := service.CreateWebhook(&domain.Webhook{Url: url})
+		assert.Nil(t, created, url)
+		assert.Equal(t, "Webhook url must not point to an internal address", err.Message(), url)
+	}
+
+	service.Initialize(true)
+	_, err := service.CreateWebhook(&domain.Webhook{Url: "http://localhost:8080/hook"})
+	assert.Nil(t, err)
+}
+
+func TestWebhookClient_Refuses_Internal_Addresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, err := newWebhookClient(false).Get(server.URL)
+	assert.ErrorContains(t, err, "is internal")
+
+	resp, err := newWebhookClient(true).Get(server.URL)
+	assert.Nil(t, err)
+	resp.Body.Close()
+}
+
+func TestWebhooksService_Dispatch_Signed_With_Retry(t *testing.T) {
+	var mu sync.Mutex
+	var calls int
+	received := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+		received <- r
+	}))
+	defer server.Close()
+
+	repo := &webhookRepoMock{webhooks: []domain.Webhook{
+		{Id: "all", Url: server.URL, Secret: "s3cret"},
+		{Id: "deletes", Url: server.URL, Events: []string{"deleted"}},
+	}}
+	domain.WebhookRepo = repo
+	service := &webhooksService{httpClient: server.Client(), maxAttempts: 3, backoff: time.Millisecond}
+
+	service.Dispatch(domain.MessageChange{Id: "7-0", Event: "created", MessageId: 1})
+	assert.Eventually(t, func() bool {
+		return repo.pendingRetries() == 1
+	}, time.Second, 5*time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	assert.EqualValues(t, 1, service.RetryDue())
+
+	select {
+	case r := <-received:
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get(TimestampHeader)
+		assert.EqualValues(t, "sha256="+Sign("s3cret", timestamp, body), r.Header.Get(SignatureHeader))
+		assert.EqualValues(t, "created", r.Header.Get("X-Webhook-Event"))
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook was not retried")
+	}
+
+	assert.Eventually(t, func() bool {
+		deliveries, _ := repo.GetDeliveries("all")
+		return len(deliveries) == 2
+	}, time.Second, 5*time.Millisecond)
+	deliveries, _ := repo.GetDeliveries("all")
+	assert.False(t, deliveries[0].Success)
+	assert.EqualValues(t, http.StatusInternalServerError, deliveries[0].StatusCode)
+	assert.True(t, deliveries[1].Success)
+	assert.EqualValues(t, 2, deliveries[1].Attempt)
+	assert.EqualValues(t, 0, repo.pendingRetries())
+}
+
+func TestWebhooksService_RetryDue_Gives_Up(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	repo := &webhookRepoMock{webhooks: []domain.Webhook{{Id: "all", Url: server.URL}}}
+	domain.WebhookRepo = repo
+	repo.ScheduleRetry(&domain.WebhookRetry{Id: "r1", WebhookId: "all", ChangeId: "7-0", Event: "created", Body: []byte(`{}`), Attempt: 2}, time.Now())
+	repo.ScheduleRetry(&domain.WebhookRetry{Id: "r2", WebhookId: "gone", ChangeId: "7-0", Event: "created", Body: []byte(`{}`), Attempt: 2}, time.Now())
+	service := &webhooksService{httpClient: server.Client(), maxAttempts: 2, backoff: time.Millisecond}
+
+	assert.EqualValues(t, 2, service.RetryDue())
+
+	assert.EqualValues(t, 0, repo.pendingRetries())
+	deliveries, _ := repo.GetDeliveries("all")
+	assert.Len(t, deliveries, 1)
+	assert.False(t, deliveries[0].Success)
+}
+
+func TestWebhooksService_Dispatch_Stores_Overflow(t *testing.T) {
+	started, release := make(chan struct{}, 3), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	defer server.Close()
+
+	repo := &webhookRepoMock{webhooks: []domain.Webhook{{Id: "all", Url: server.URL}}}
+	domain.WebhookRepo = repo
+	service := &webhooksService{httpClient: server.Client(), maxAttempts: 3, backoff: time.Millisecond, workers: 1, queueSize: 1}
+
+	service.Dispatch(domain.MessageChange{Id: "1-0", Event: "created", MessageId: 1})
+	<-started
+	service.Dispatch(domain.MessageChange{Id: "2-0", Event: "created", MessageId: 2})
+	service.Dispatch(domain.MessageChange{Id: "3-0", Event: "created", MessageId: 3})
+	assert.EqualValues(t, 1, repo.pendingRetries())
+
+	close(release)
+	service.Wait()
+	assert.EqualValues(t, 1, service.RetryDue())
+
+	deliveries, _ := repo.GetDeliveries("all")
+	assert.Len(t, deliveries, 3)
+	for _, delivery := range deliveries {
+		assert.True(t, delivery.Success)
+		assert.EqualValues(t, 1, delivery.Attempt)
+	}
+	assert.EqualValues(t, 0, repo.pendingRetries())
+}
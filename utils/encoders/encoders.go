@@ -0,0 +1,149 @@
+package encoders
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultMediaType is used when the client does not ask for anything in
+// particular.
+const DefaultMediaType = "application/json"
+
+// Encoder writes response bodies in one media type.
+type Encoder interface {
+	ContentType() string
+	Encode(w io.Writer, v interface{}) error
+}
+
+// StreamEncoder is implemented by encoders that can write a list one element
+// at a time, so a listing does not have to be held in memory.
+type StreamEncoder interface {
+	Encoder
+	NewStream(w io.Writer) Stream
+}
+
+// Stream writes the elements of a list. Close finishes the list and must be
+// called once every element has been written.
+type Stream interface {
+	Write(v interface{}) error
+	Close() error
+}
+
+var (
+	mu         sync.RWMutex
+	registered = map[string]Encoder{}
+	// order keeps registration order, so wildcards pick the encoder that was
+	// registered first.
+	order []string
+)
+
+func init() {
+	Register("application/json", jsonEncoder{})
+	Register("application/x-ndjson", ndjsonEncoder{})
+	Register("text/csv", csvEncoder{})
+	Register("application/msgpack", msgpackEncoder{})
+	Register("application/x-msgpack", msgpackEncoder{})
+}
+
+// Register makes encoder available for mediaType, replacing any encoder
+// registered for it before.
+func Register(mediaType string, encoder Encoder) {
+	mediaType = strings.ToLower(mediaType)
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := registered[mediaType]; !ok {
+		order = append(order, mediaType)
+	}
+	registered[mediaType] = encoder
+}
+
+// MediaTypes lists the registered media types in registration order.
+func MediaTypes() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]string(nil), order...)
+}
+
+type acceptRange struct {
+	mediaType string
+	quality   float64
+}
+
+// Negotiate picks the encoder for an Accept header, honouring quality values
+// and wildcards. An empty header gets the default encoder; a header nothing
+// registered satisfies gets false.
+func Negotiate(accept string) (Encoder, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if strings.TrimSpace(accept) == "" {
+		return registered[DefaultMediaType], true
+	}
+	for _, r := range parseAccept(accept) {
+		if encoder, ok := match(r.mediaType); ok {
+			return encoder, true
+		}
+	}
+	return nil, false
+}
+
+// Accepts reports whether the Accept header names mediaType itself with a
+// quality above zero. Wildcards do not count, so formats that change the
+// shape of a response stay opt-in.
+func Accepts(accept, mediaType string) bool {
+	mediaType = strings.ToLower(mediaType)
+	for _, r := range parseAccept(accept) {
+		if r.mediaType == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+func match(mediaType string) (Encoder, bool) {
+	switch {
+	case mediaType == "*/*":
+		return registered[DefaultMediaType], true
+	case strings.HasSuffix(mediaType, "/*"):
+		prefix := strings.TrimSuffix(mediaType, "*")
+		for _, candidate := range order {
+			if strings.HasPrefix(candidate, prefix) {
+				return registered[candidate], true
+			}
+		}
+		return nil, false
+	default:
+		encoder, ok := registered[mediaType]
+		return encoder, ok
+	}
+}
+
+// parseAccept returns the acceptable ranges of an Accept header, most
+// preferred first. Ranges with a quality of zero are dropped.
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		r := acceptRange{mediaType: strings.ToLower(strings.TrimSpace(params[0])), quality: 1}
+		if r.mediaType == "" {
+			continue
+		}
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					r.quality = q
+				}
+			}
+		}
+		if r.quality > 0 {
+			ranges = append(ranges, r)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+	return ranges
+}
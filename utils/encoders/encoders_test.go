@@ -0,0 +1,79 @@
+package encoders
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+	"testing"
+)
+
+type row struct {
+	Name string `json:"name"`
+}
+
+func (r row) CSVHeader() []string { return []string{"name"} }
+func (r row) CSVRecord() []string { return []string{r.Name} }
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]string{
+		"":                                     "application/json; charset=utf-8",
+		"*/*":                                  "application/json; charset=utf-8",
+		"application/*":                        "application/json; charset=utf-8",
+		"text/*":                               "text/csv; charset=utf-8",
+		"application/x-ndjson":                 "application/x-ndjson",
+		"TEXT/CSV":                             "text/csv; charset=utf-8",
+		"application/x-msgpack":                "application/msgpack",
+		"text/html, text/csv;q=0.5, */*;q=0.1": "text/csv; charset=utf-8",
+		"application/json;q=0.2, text/csv":     "text/csv; charset=utf-8",
+	}
+	for accept, contentType := range cases {
+		encoder, ok := Negotiate(accept)
+		assert.True(t, ok, accept)
+		assert.Equal(t, contentType, encoder.ContentType(), accept)
+	}
+
+	_, ok := Negotiate("text/html")
+	assert.False(t, ok)
+	_, ok = Negotiate("application/json;q=0")
+	assert.False(t, ok)
+}
+
+func TestStreams(t *testing.T) {
+	var out bytes.Buffer
+	stream := jsonEncoder{}.NewStream(&out)
+	assert.Nil(t, stream.Close())
+	assert.Equal(t, "[]", out.String())
+
+	out.Reset()
+	stream = jsonEncoder{}.NewStream(&out)
+	assert.Nil(t, stream.Write(row{Name: "a"}))
+	assert.Nil(t, stream.Write(row{Name: "b"}))
+	assert.Nil(t, stream.Close())
+	assert.Equal(t, `[{"name":"a"},{"name":"b"}]`, out.String())
+
+	out.Reset()
+	stream = ndjsonEncoder{}.NewStream(&out)
+	assert.Nil(t, stream.Write(row{Name: "a"}))
+	assert.Nil(t, stream.Write(row{Name: "b"}))
+	assert.Nil(t, stream.Close())
+	assert.Equal(t, "{\"name\":\"a\"}\n{\"name\":\"b\"}\n", out.String())
+
+	out.Reset()
+	stream = csvEncoder{}.NewStream(&out)
+	assert.Nil(t, stream.Write(row{Name: "a"}))
+	assert.Nil(t, stream.Write(row{Name: "b, c"}))
+	assert.Nil(t, stream.Close())
+	assert.Equal(t, "name\na\n\"b, c\"\n", out.String())
+
+	assert.Equal(t, errNotRecord, csvEncoder{}.Encode(&out, "not a record"))
+}
+
+func TestMsgpackEncoder(t *testing.T) {
+	var out bytes.Buffer
+	assert.Nil(t, msgpackEncoder{}.Encode(&out, row{Name: "a"}))
+
+	var decoded row
+	var handle codec.MsgpackHandle
+	assert.Nil(t, codec.NewDecoderBytes(out.Bytes(), &handle).Decode(&decoded))
+	assert.Equal(t, "a", decoded.Name)
+}
@@ -0,0 +1,178 @@
+package encoders
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/ugorji/go/codec"
+	"io"
+	"net/http"
+)
+
+// Record is implemented by values that can be written as CSV rows.
+type Record interface {
+	CSVHeader() []string
+	CSVRecord() []string
+}
+
+var errNotRecord = errors.New("value cannot be written as csv")
+
+// flush pushes what has been written so far to the client when w is a
+// response writer, so streamed rows are not held back in its buffer.
+func flush(w io.Writer) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (jsonEncoder) Encode(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// NewStream writes a JSON array element by element. It does not flush after
+// each element like the line-based formats: a JSON array is of no use to the
+// client until it is complete.
+func (jsonEncoder) NewStream(w io.Writer) Stream {
+	return &jsonStream{w: w}
+}
+
+type jsonStream struct {
+	w       io.Writer
+	started bool
+}
+
+func (s *jsonStream) Write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	separator := []byte{','}
+	if !s.started {
+		separator[0] = '['
+		s.started = true
+	}
+	if _, err := s.w.Write(separator); err != nil {
+		return err
+	}
+	_, err = s.w.Write(data)
+	return err
+}
+
+func (s *jsonStream) Close() error {
+	end := "]"
+	if !s.started {
+		end = "[]"
+	}
+	_, err := io.WriteString(s.w, end)
+	return err
+}
+
+// ndjsonEncoder writes one JSON document per line.
+type ndjsonEncoder struct{}
+
+func (ndjsonEncoder) ContentType() string {
+	return "application/x-ndjson"
+}
+
+func (ndjsonEncoder) Encode(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func (ndjsonEncoder) NewStream(w io.Writer) Stream {
+	return &ndjsonStream{w: w}
+}
+
+type ndjsonStream struct {
+	w io.Writer
+}
+
+func (s *ndjsonStream) Write(v interface{}) error {
+	if err := (ndjsonEncoder{}).Encode(s.w, v); err != nil {
+		return err
+	}
+	flush(s.w)
+	return nil
+}
+
+func (s *ndjsonStream) Close() error {
+	return nil
+}
+
+// csvEncoder writes Records with a header row taken from the first one.
+type csvEncoder struct{}
+
+func (csvEncoder) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+func (e csvEncoder) Encode(w io.Writer, v interface{}) error {
+	stream := e.NewStream(w)
+	if err := stream.Write(v); err != nil {
+		return err
+	}
+	return stream.Close()
+}
+
+func (csvEncoder) NewStream(w io.Writer) Stream {
+	return &csvStream{w: w, csv: csv.NewWriter(w)}
+}
+
+type csvStream struct {
+	w       io.Writer
+	csv     *csv.Writer
+	started bool
+}
+
+func (s *csvStream) Write(v interface{}) error {
+	record, ok := v.(Record)
+	if !ok {
+		return errNotRecord
+	}
+	if !s.started {
+		if err := s.csv.Write(record.CSVHeader()); err != nil {
+			return err
+		}
+		s.started = true
+	}
+	if err := s.csv.Write(record.CSVRecord()); err != nil {
+		return err
+	}
+	s.csv.Flush()
+	if err := s.csv.Error(); err != nil {
+		return err
+	}
+	flush(s.w)
+	return nil
+}
+
+func (s *csvStream) Close() error {
+	s.csv.Flush()
+	return s.csv.Error()
+}
+
+type msgpackEncoder struct{}
+
+func (msgpackEncoder) ContentType() string {
+	return "application/msgpack"
+}
+
+func (msgpackEncoder) Encode(w io.Writer, v interface{}) error {
+	var handle codec.MsgpackHandle
+	return codec.NewEncoder(w, &handle).Encode(v)
+}
@@ -1,23 +1,86 @@
 package error_formats
 
 import (
-	"fmt"
-	"github.com/go-sql-driver/mysql"
-	"strings"
+	"context"
+	"errors"
+	"net"
+	"sync"
 	"testing-project/utils/error_utils"
 )
 
-func ParseError(err error) error_utils.MessageErr {
-	sqlErr, ok := err.(*mysql.MySQLError)
-	if !ok {
-		if strings.Contains(err.Error(), "no rows in result set") {
-			return error_utils.NewNotFoundError("no record matching given id")
+// Translator maps the errors of one backend to MessageErr, classifying them
+// by kind and code. It returns nil for errors it does not recognize.
+// Translators need not keep the cause; Translate adds it.
+type Translator func(err error) error_utils.MessageErr
+
+var (
+	mu          sync.RWMutex
+	translators = map[string]Translator{}
+	// order keeps registration order, which is the order translators are
+	// tried in.
+	order []string
+)
+
+func init() {
+	Register("context", translateContext)
+	Register("net", translateNet)
+}
+
+// Register adds the translator of a backend, replacing any translator
+// registered under the same name before. Backends register theirs from an
+// init function.
+func Register(name string, translator Translator) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := translators[name]; !ok {
+		order = append(order, name)
+	}
+	translators[name] = translator
+}
+
+// Translate maps err, returned by a backend while doing operation (such as
+// "redis get"), to a MessageErr that keeps err as its cause and operation as
+// its detail. Errors no translator recognizes are internal errors with the
+// message "<operation> error". A MessageErr anywhere in the chain is
+// returned as it is.
+func Translate(err error, operation string) error_utils.MessageErr {
+	if err == nil {
+		return nil
+	}
+	var messageErr error_utils.MessageErr
+	if errors.As(err, &messageErr) {
+		return messageErr
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, name := range order {
+		if translated := translators[name](err); translated != nil {
+			return translated.WithDetail(operation).Wrap(err)
 		}
-		return error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save message: %s", err.Error()))
 	}
-	switch sqlErr.Number {
-	case 1062:
-		return error_utils.NewInternalServerError("title already taken")
+	return error_utils.NewInternalServerError(operation + " error").Wrap(err)
+}
+
+func translateContext(err error) error_utils.MessageErr {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return error_utils.NewServiceUnavailableError("request canceled").WithCode(error_utils.CodeRequestCanceled)
+	case errors.Is(err, context.DeadlineExceeded):
+		return error_utils.NewGatewayTimeoutError("backend timed out").WithCode(error_utils.CodeBackendTimeout)
+	}
+	return nil
+}
+
+// translateNet covers the connection failures every network backend shares.
+func translateNet(err error) error_utils.MessageErr {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return error_utils.NewGatewayTimeoutError("backend timed out").WithCode(error_utils.CodeBackendTimeout)
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return error_utils.NewServiceUnavailableError("backend unavailable").WithCode(error_utils.CodeBackendUnavailable)
 	}
-	return error_utils.NewInternalServerError(fmt.Sprintf("error when processing request: %s", err.Error()))
+	return nil
 }
@@ -0,0 +1,64 @@
+package error_formats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+func TestTranslate_Context(t *testing.T) {
+	canceled := Translate(fmt.Errorf("dial: %w", context.Canceled), "redis get")
+	timedOut := Translate(context.DeadlineExceeded, "redis get")
+
+	assert.Equal(t, error_utils.CodeRequestCanceled, canceled.Code())
+	assert.True(t, errors.Is(canceled, context.Canceled))
+	assert.True(t, errors.Is(timedOut, error_utils.ErrTimeout))
+	assert.Equal(t, error_utils.CodeBackendTimeout, timedOut.Code())
+	assert.Equal(t, "redis get: context deadline exceeded", timedOut.Detail())
+}
+
+func TestTranslate_Net(t *testing.T) {
+	_, dialErr := net.DialTimeout("tcp", "127.0.0.1:1", time.Second)
+	refused := Translate(dialErr, "redis get")
+
+	assert.True(t, errors.Is(refused, error_utils.ErrUnavailable))
+	assert.Equal(t, error_utils.CodeBackendUnavailable, refused.Code())
+}
+
+func TestTranslate_Unknown_Error(t *testing.T) {
+	cause := errors.New("unexpected reply")
+	err := Translate(cause, "redis save")
+
+	assert.Equal(t, "redis save error", err.Message())
+	assert.True(t, errors.Is(err, error_utils.ErrInternal))
+	assert.True(t, errors.Is(err, cause))
+	assert.Nil(t, Translate(nil, "redis save"))
+}
+
+func TestTranslate_Keeps_MessageErr(t *testing.T) {
+	original := error_utils.NewNotFoundError("message not found")
+
+	assert.Equal(t, original, Translate(fmt.Errorf("wrapped: %w", original), "redis get"))
+}
+
+var errThrottled = errors.New("throttled")
+
+func TestRegister(t *testing.T) {
+	Register("test", func(err error) error_utils.MessageErr {
+		if errors.Is(err, errThrottled) {
+			return error_utils.NewTooManyRequestsError("backend throttled")
+		}
+		return nil
+	})
+	defer Register("test", func(error) error_utils.MessageErr { return nil })
+
+	err := Translate(errThrottled, "redis get")
+
+	assert.Equal(t, "backend throttled", err.Message())
+	assert.Equal(t, "redis get: throttled", err.Detail())
+}
@@ -0,0 +1,68 @@
+package error_utils
+
+// Stable error codes. Clients branch on these, so existing codes must never
+// change meaning or be removed.
+const (
+	CodeBadRequest         = "BAD_REQUEST"
+	CodeUnauthorized       = "UNAUTHORIZED"
+	CodeForbidden          = "FORBIDDEN"
+	CodeNotFound           = "NOT_FOUND"
+	CodeNotAcceptable      = "NOT_ACCEPTABLE"
+	CodeConflict           = "CONFLICT"
+	CodeInvalidRequest     = "INVALID_REQUEST"
+	CodeRateLimited        = "RATE_LIMITED"
+	CodeInternalError      = "INTERNAL_ERROR"
+	CodeServiceUnavailable = "SERVICE_UNAVAILABLE"
+	CodeTimeout            = "TIMEOUT"
+
+	CodeMessageNotFound        = "MESSAGE_NOT_FOUND"
+	CodeNoMessagesFound        = "NO_MESSAGES_FOUND"
+	CodeInvalidMessageId       = "INVALID_MESSAGE_ID"
+	CodeInvalidFilter          = "INVALID_FILTER"
+	CodeInvalidIncludeDeleted  = "INVALID_INCLUDE_DELETED"
+	CodeVersionNotFound        = "VERSION_NOT_FOUND"
+	CodeInvalidVersion         = "INVALID_VERSION"
+	CodeInvalidStatsQuery      = "INVALID_STATS_QUERY"
+	CodeInvalidTenant          = "INVALID_TENANT"
+	CodeTenantNotAllowed       = "TENANT_NOT_ALLOWED"
+	CodeTenantQuotaExceeded    = "TENANT_QUOTA_EXCEEDED"
+	CodeUpstreamUnavailable    = "UPSTREAM_UNAVAILABLE"
+	CodeWebhookNotFound        = "WEBHOOK_NOT_FOUND"
+	CodeInvalidWebhook         = "INVALID_WEBHOOK"
+	CodeSpecificationViolation = "SPECIFICATION_VIOLATION"
+	CodeMigrationDisabled      = "MIGRATION_DISABLED"
+	CodeInvalidReadSource      = "INVALID_READ_SOURCE"
+	CodeBackfillRunning        = "BACKFILL_RUNNING"
+	CodeMigrationNotVerified   = "MIGRATION_NOT_VERIFIED"
+	CodeConsumerNotRunning     = "CONSUMER_NOT_RUNNING"
+	CodeConsumerDisconnected   = "CONSUMER_DISCONNECTED"
+	CodeInvalidDeadLetterLimit = "INVALID_DEAD_LETTER_LIMIT"
+	CodeInvalidEvent           = "INVALID_EVENT"
+	CodeUnknownEventType       = "UNKNOWN_EVENT_TYPE"
+	CodeInvalidLastEventId     = "INVALID_LAST_EVENT_ID"
+
+	CodeRequestCanceled    = "REQUEST_CANCELED"
+	CodeBackendTimeout     = "BACKEND_TIMEOUT"
+	CodeBackendUnavailable = "BACKEND_UNAVAILABLE"
+	CodeBackendReadOnly    = "BACKEND_READ_ONLY"
+	CodeBackendLoading     = "BACKEND_LOADING"
+	CodeBackendMoved       = "BACKEND_MOVED"
+	CodeBrokerUnavailable  = "BROKER_UNAVAILABLE"
+	CodeBrokerError        = "BROKER_ERROR"
+)
+
+// defaultCodes are the codes of errors that were not given a specific one,
+// by their legacy error name.
+var defaultCodes = map[string]string{
+	"bad_request":         CodeBadRequest,
+	"unauthorized":        CodeUnauthorized,
+	"forbidden":           CodeForbidden,
+	"not_found":           CodeNotFound,
+	"not_acceptable":      CodeNotAcceptable,
+	"conflict":            CodeConflict,
+	"invalid_request":     CodeInvalidRequest,
+	"too_many_requests":   CodeRateLimited,
+	"server_error":        CodeInternalError,
+	"service_unavailable": CodeServiceUnavailable,
+	"timeout":             CodeTimeout,
+}
@@ -2,19 +2,76 @@ package error_utils
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
+// Sentinels for the kinds of failure. Every MessageErr matches the one of
+// its kind with errors.Is, so callers branch on the kind instead of the
+// status:
+//
+//	if errors.Is(err, error_utils.ErrNotFound) { ... }
+var (
+	ErrBadRequest    = errors.New("bad request")
+	ErrUnauthorized  = errors.New("unauthorized")
+	ErrForbidden     = errors.New("forbidden")
+	ErrNotFound      = errors.New("not found")
+	ErrNotAcceptable = errors.New("not acceptable")
+	ErrConflict      = errors.New("conflict")
+	ErrInvalid       = errors.New("invalid request")
+	ErrRateLimited   = errors.New("too many requests")
+	ErrInternal      = errors.New("internal error")
+	ErrUnavailable   = errors.New("unavailable")
+	ErrTimeout       = errors.New("timeout")
+)
+
+// kinds maps the legacy error names to their sentinels, so decoded errors
+// match too.
+var kinds = map[string]error{
+	"bad_request":         ErrBadRequest,
+	"unauthorized":        ErrUnauthorized,
+	"forbidden":           ErrForbidden,
+	"not_found":           ErrNotFound,
+	"not_acceptable":      ErrNotAcceptable,
+	"conflict":            ErrConflict,
+	"invalid_request":     ErrInvalid,
+	"too_many_requests":   ErrRateLimited,
+	"server_error":        ErrInternal,
+	"service_unavailable": ErrUnavailable,
+	"timeout":             ErrTimeout,
+}
+
 type MessageErr interface {
 	Message() string
 	Status() int
 	Error() string
+	// Code is the stable machine-readable code of the error, one of the
+	// Code* constants.
+	Code() string
+	// WithCode returns the error with a more specific code.
+	WithCode(string) MessageErr
+	// Detail is what went wrong internally: the detail given with
+	// WithDetail followed by the cause. It is meant for logs and is never
+	// sent to clients.
+	Detail() string
+	// WithDetail returns the error with an internal detail.
+	WithDetail(string) MessageErr
+	// Wrap returns the error with cause as the next link of its chain.
+	Wrap(cause error) MessageErr
+	// Unwrap returns the cause, if any.
+	Unwrap() error
 }
 
+// messageErr serializes in the legacy {message, status, error} format; the
+// code only shows up in problem responses, and the detail and cause only in
+// logs.
 type messageErr struct {
 	ErrMessage string `json:"message"`
 	ErrStatus  int    `json:"status"`
 	ErrError   string `json:"error"`
+	ErrCode    string `json:"-"`
+	ErrDetail  string `json:"-"`
+	ErrCause   error  `json:"-"`
 }
 
 func (e *messageErr) Error() string {
@@ -29,6 +86,54 @@ func (e *messageErr) Status() int {
 	return e.ErrStatus
 }
 
+func (e *messageErr) Code() string {
+	if e.ErrCode != "" {
+		return e.ErrCode
+	}
+	if code, ok := defaultCodes[e.ErrError]; ok {
+		return code
+	}
+	return CodeInternalError
+}
+
+func (e *messageErr) WithCode(code string) MessageErr {
+	coded := *e
+	coded.ErrCode = code
+	return &coded
+}
+
+func (e *messageErr) Detail() string {
+	switch {
+	case e.ErrCause == nil:
+		return e.ErrDetail
+	case e.ErrDetail == "":
+		return e.ErrCause.Error()
+	}
+	return e.ErrDetail + ": " + e.ErrCause.Error()
+}
+
+func (e *messageErr) WithDetail(detail string) MessageErr {
+	detailed := *e
+	detailed.ErrDetail = detail
+	return &detailed
+}
+
+func (e *messageErr) Wrap(cause error) MessageErr {
+	wrapped := *e
+	wrapped.ErrCause = cause
+	return &wrapped
+}
+
+func (e *messageErr) Unwrap() error {
+	return e.ErrCause
+}
+
+// Is matches the sentinel of the error's kind.
+func (e *messageErr) Is(target error) bool {
+	kind, ok := kinds[e.ErrError]
+	return ok && kind == target
+}
+
 func NewNotFoundError(message string) MessageErr {
 	return &messageErr{
 		ErrMessage: message,
@@ -44,6 +149,47 @@ func NewBadRequestError(message string) MessageErr {
 		ErrError:   "bad_request",
 	}
 }
+
+func NewUnauthorizedError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusUnauthorized,
+		ErrError:   "unauthorized",
+	}
+}
+
+func NewForbiddenError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusForbidden,
+		ErrError:   "forbidden",
+	}
+}
+
+func NewNotAcceptableError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusNotAcceptable,
+		ErrError:   "not_acceptable",
+	}
+}
+
+func NewConflictError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusConflict,
+		ErrError:   "conflict",
+	}
+}
+
+func NewTooManyRequestsError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusTooManyRequests,
+		ErrError:   "too_many_requests",
+	}
+}
+
 func NewUnprocessibleEntityError(message string) MessageErr {
 	return &messageErr{
 		ErrMessage: message,
@@ -67,3 +213,19 @@ func NewInternalServerError(message string) MessageErr {
 		ErrError:   "server_error",
 	}
 }
+
+func NewServiceUnavailableError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusServiceUnavailable,
+		ErrError:   "service_unavailable",
+	}
+}
+
+func NewGatewayTimeoutError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusGatewayTimeout,
+		ErrError:   "timeout",
+	}
+}
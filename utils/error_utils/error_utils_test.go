@@ -0,0 +1,44 @@
+package error_utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string { return "i/o timeout" }
+
+func TestMessageErr_Cause_Chain(t *testing.T) {
+	cause := fmt.Errorf("read: %w", timeoutErr{})
+	err := NewInternalServerError("redis get error").WithDetail("GET message:1").Wrap(cause)
+
+	assert.True(t, errors.Is(err, ErrInternal))
+	assert.False(t, errors.Is(err, ErrNotFound))
+	assert.True(t, errors.Is(err, cause))
+	var timeout timeoutErr
+	assert.True(t, errors.As(err, &timeout))
+	assert.Equal(t, "GET message:1: read: i/o timeout", err.Detail())
+}
+
+func TestMessageErr_Detail_Not_Serialized(t *testing.T) {
+	err := NewServiceUnavailableError("upstream unavailable").WithDetail("secret host 10.0.0.7").Wrap(errors.New("dial tcp"))
+
+	body, _ := json.Marshal(err)
+	assert.JSONEq(t, `{"message":"upstream unavailable","status":503,"error":"service_unavailable"}`, string(body))
+	problem, _ := json.Marshal(NewProblem(err, "/messages/1", "req"))
+	assert.NotContains(t, string(problem), "10.0.0.7")
+	assert.NotContains(t, string(problem), "dial tcp")
+}
+
+func TestMessageErr_Kinds_Survive_Decoding(t *testing.T) {
+	decoded, err := NewApiErrFromBytes([]byte(`{"message":"message not found","status":404,"error":"not_found"}`))
+
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(decoded, ErrNotFound))
+	assert.True(t, errors.Is(fmt.Errorf("lookup: %w", decoded), ErrNotFound))
+	assert.True(t, errors.Is(NewUnprocessibleEntityError("bad title"), ErrInvalid))
+}
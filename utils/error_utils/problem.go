@@ -0,0 +1,40 @@
+package error_utils
+
+import (
+	"net/http"
+	"strings"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. Code and RequestId are
+// extension members.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestId string `json:"request_id,omitempty"`
+}
+
+// NewProblem describes err as a problem that occurred at instance, the path
+// of the request.
+func NewProblem(err MessageErr, instance, requestId string) *Problem {
+	return &Problem{
+		Type:      ProblemType(err.Code()),
+		Title:     http.StatusText(err.Status()),
+		Status:    err.Status(),
+		Detail:    err.Message(),
+		Instance:  instance,
+		Code:      err.Code(),
+		RequestId: requestId,
+	}
+}
+
+// ProblemType is the problem type URI of an error code, for example
+// "urn:problem-type:message-not-found" for MESSAGE_NOT_FOUND.
+func ProblemType(code string) string {
+	return "urn:problem-type:" + strings.ReplaceAll(strings.ToLower(code), "_", "-")
+}